@@ -0,0 +1,105 @@
+package main
+
+import "C"
+import (
+	"encoding/json"
+
+	"decred.org/dcrwallet/v5/wallet/udb"
+)
+
+// accountNumber returns the number of the account with the provided name. The
+// default account is used if the name is empty.
+func (w *wallet) accountNumber(name string) (uint32, error) {
+	if name == "" {
+		return udb.DefaultAccountNum, nil
+	}
+	return w.MainWallet().AccountNumber(w.ctx, name)
+}
+
+//export createAccount
+func createAccount(cName, cAccountName, cPass *C.char) *C.char {
+	w, ok := loadedWallet(cName)
+	if !ok {
+		return errCResponse("wallet with name %q is not loaded", goString(cName))
+	}
+
+	acct, err := w.CreateAccount(w.ctx, goString(cAccountName), []byte(goString(cPass)))
+	if err != nil {
+		return errCResponse("w.CreateAccount error: %v", err)
+	}
+
+	return successCResponse("%d", acct)
+}
+
+//export renameAccount
+func renameAccount(cName, cAccountName, cNewName *C.char) *C.char {
+	w, ok := loadedWallet(cName)
+	if !ok {
+		return errCResponse("wallet with name %q is not loaded", goString(cName))
+	}
+
+	acct, err := w.MainWallet().AccountNumber(w.ctx, goString(cAccountName))
+	if err != nil {
+		return errCResponse("unknown account %q: %v", goString(cAccountName), err)
+	}
+
+	if err := w.RenameAccount(w.ctx, acct, goString(cNewName)); err != nil {
+		return errCResponse("w.RenameAccount error: %v", err)
+	}
+
+	return successCResponse("account renamed to %q", goString(cNewName))
+}
+
+//export listAccounts
+func listAccounts(cName *C.char) *C.char {
+	w, ok := loadedWallet(cName)
+	if !ok {
+		return errCResponse("wallet with name %q is not loaded", goString(cName))
+	}
+
+	const confs = 1
+	accts, err := w.ListAccounts(w.ctx, confs)
+	if err != nil {
+		return errCResponse("w.ListAccounts error: %v", err)
+	}
+
+	res := make([]*AccountRes, 0, len(accts))
+	for _, acct := range accts {
+		// Hide the imported account unless it has funds.
+		if acct.Number == udb.ImportedAddrAccount && acct.Balances.Total == 0 {
+			continue
+		}
+		res = append(res, &AccountRes{
+			Number:      acct.Number,
+			Name:        acct.Name,
+			Confirmed:   int64(acct.Balances.Spendable),
+			Unconfirmed: int64(acct.Balances.Total) - int64(acct.Balances.Spendable),
+		})
+	}
+
+	b, err := json.Marshal(res)
+	if err != nil {
+		return errCResponse("unable to marshal accounts: %v", err)
+	}
+
+	return successCResponse("%s", b)
+}
+
+//export accountPubkey
+func accountPubkey(cName, cAccountName *C.char) *C.char {
+	w, ok := loadedWallet(cName)
+	if !ok {
+		return errCResponse("wallet with name %q is not loaded", goString(cName))
+	}
+
+	acctName := goString(cAccountName)
+	if acctName == "" {
+		acctName = defaultAccount
+	}
+	xpub, err := w.AccountPubkey(w.ctx, acctName)
+	if err != nil {
+		return errCResponse("unable to get account pubkey: %v", err)
+	}
+
+	return successCResponse("%s", xpub)
+}
@@ -7,18 +7,22 @@ import (
 	"strconv"
 
 	dcrwallet "decred.org/dcrwallet/v5/wallet"
-	"decred.org/dcrwallet/v5/wallet/udb"
 	"github.com/decred/dcrd/txscript/v4/stdaddr"
 	"github.com/decred/libwallet/dcr"
 )
 
 //export currentReceiveAddress
-func currentReceiveAddress(cName *C.char) *C.char {
+func currentReceiveAddress(cName, cAccountName *C.char) *C.char {
 	w, ok := loadedWallet(cName)
 	if !ok {
 		return errCResponse("wallet with name %q is not loaded", goString(cName))
 	}
 
+	acct, err := w.accountNumber(goString(cAccountName))
+	if err != nil {
+		return errCResponse("unknown account %q: %v", goString(cAccountName), err)
+	}
+
 	if !w.allowUnsyncedAddrs {
 		synced, _ := w.IsSynced(w.ctx)
 		if !synced {
@@ -26,7 +30,7 @@ func currentReceiveAddress(cName *C.char) *C.char {
 		}
 	}
 
-	addr, err := w.CurrentAddress(udb.DefaultAccountNum)
+	addr, err := w.CurrentAddress(acct)
 	if err != nil {
 		return errCResponse("w.CurrentAddress error: %v", err)
 	}
@@ -35,12 +39,17 @@ func currentReceiveAddress(cName *C.char) *C.char {
 }
 
 //export newExternalAddress
-func newExternalAddress(cName *C.char) *C.char {
+func newExternalAddress(cName, cAccountName *C.char) *C.char {
 	w, ok := loadedWallet(cName)
 	if !ok {
 		return errCResponse("wallet with name %q is not loaded", goString(cName))
 	}
 
+	acct, err := w.accountNumber(goString(cAccountName))
+	if err != nil {
+		return errCResponse("unknown account %q: %v", goString(cAccountName), err)
+	}
+
 	if !w.allowUnsyncedAddrs {
 		synced, _ := w.IsSynced(w.ctx)
 		if !synced {
@@ -48,14 +57,13 @@ func newExternalAddress(cName *C.char) *C.char {
 		}
 	}
 
-	_, err := w.NewExternalAddress(w.ctx, udb.DefaultAccountNum)
-	if err != nil {
+	if _, err := w.NewExternalAddress(w.ctx, acct); err != nil {
 		return errCResponse("w.NewExternalAddress error: %v", err)
 	}
 
 	// NewExternalAddress will take the current address before increasing
 	// the index. Get the current address after increasing the index.
-	addr, err := w.CurrentAddress(udb.DefaultAccountNum)
+	addr, err := w.CurrentAddress(acct)
 	if err != nil {
 		return errCResponse("w.CurrentAddress error: %v", err)
 	}
@@ -85,11 +93,7 @@ func signMessage(cName, cMessage, cAddress, cPassword *C.char) *C.char {
 		return errCResponse("invalid address type: must be P2PK or P2PKH")
 	}
 
-	if err := w.MainWallet().Unlock(w.ctx, []byte(goString(cPassword)), nil); err != nil {
-		return errCResponse("cannot unlock wallet: %v", err)
-	}
-
-	sig, err := w.MainWallet().SignMessage(w.ctx, goString(cMessage), addr)
+	sig, err := w.SignMessage(w.ctx, goString(cMessage), addr, []byte(goString(cPassword)))
 	if err != nil {
 		return errCResponse("unable to sign message: %v", err)
 	}
@@ -135,12 +139,17 @@ func verifyMessage(cName, cMessage, cAddress, cSig *C.char) *C.char {
 }
 
 //export addresses
-func addresses(cName, cNUsed, cNUnused *C.char) *C.char {
+func addresses(cName, cAccountName, cNUsed, cNUnused *C.char) *C.char {
 	w, ok := loadedWallet(cName)
 	if !ok {
 		return errCResponse("wallet with name %q is not loaded", goString(cName))
 	}
 
+	acct, err := w.accountNumber(goString(cAccountName))
+	if err != nil {
+		return errCResponse("unknown account %q: %v", goString(cAccountName), err)
+	}
+
 	nUsed, err := strconv.ParseUint(goString(cNUsed), 10, 32)
 	if err != nil {
 		return errCResponse("number of used addresses is not a uint32: %v", err)
@@ -151,9 +160,9 @@ func addresses(cName, cNUsed, cNUnused *C.char) *C.char {
 		return errCResponse("number of unused addresses is not a uint32: %v", err)
 	}
 
-	used, unused, index, err := w.DefaultAccountAddresses(w.ctx, uint32(nUsed), uint32(nUnused))
+	used, unused, index, err := w.AccountAddresses(w.ctx, acct, uint32(nUsed), uint32(nUnused))
 	if err != nil {
-		return errCResponse("w.DefaultAccountAddresses error: %v", err)
+		return errCResponse("w.AccountAddresses error: %v", err)
 	}
 
 	res := &AddressesRes{
@@ -0,0 +1,36 @@
+package main
+
+import "C"
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/decred/libwallet/dcr"
+)
+
+//export nextEvents
+func nextEvents(cName, cMax, cTimeoutMs *C.char) *C.char {
+	w, ok := loadedWallet(cName)
+	if !ok {
+		return errCResponse("wallet with name %q is not loaded", goString(cName))
+	}
+	max, err := strconv.ParseUint(goString(cMax), 10, 32)
+	if err != nil {
+		return errCResponse("max is not an uint32: %v", err)
+	}
+	timeoutMs, err := strconv.ParseUint(goString(cTimeoutMs), 10, 32)
+	if err != nil {
+		return errCResponse("timeout is not an uint32: %v", err)
+	}
+
+	events := w.NextEvents(w.ctx, int(max), time.Duration(timeoutMs)*time.Millisecond)
+	if events == nil {
+		events = []*dcr.Event{}
+	}
+	b, err := json.Marshal(events)
+	if err != nil {
+		return errCResponse("unable to marshal events: %v", err)
+	}
+	return successCResponse("%s", b)
+}
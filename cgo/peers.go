@@ -0,0 +1,68 @@
+package main
+
+import "C"
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/decred/libwallet/dcr"
+)
+
+//export peers
+func peers(cName *C.char) *C.char {
+	w, ok := loadedWallet(cName)
+	if !ok {
+		return errCResponse("wallet with name %q is not loaded", goString(cName))
+	}
+
+	peers := w.Peers()
+	if peers == nil {
+		peers = []*dcr.PeerInfo{}
+	}
+	b, err := json.Marshal(peers)
+	if err != nil {
+		return errCResponse("unable to marshal peers: %v", err)
+	}
+	return successCResponse("%s", b)
+}
+
+//export addPeer
+func addPeer(cName, cAddr *C.char) *C.char {
+	w, ok := loadedWallet(cName)
+	if !ok {
+		return errCResponse("wallet with name %q is not loaded", goString(cName))
+	}
+	if err := w.AddPeer(goString(cAddr)); err != nil {
+		return errCResponse("w.AddPeer error: %v", err)
+	}
+	return successCResponse("peer added")
+}
+
+//export disconnectPeer
+func disconnectPeer(cName, cAddr *C.char) *C.char {
+	w, ok := loadedWallet(cName)
+	if !ok {
+		return errCResponse("wallet with name %q is not loaded", goString(cName))
+	}
+	if err := w.DisconnectPeer(goString(cAddr)); err != nil {
+		return errCResponse("w.DisconnectPeer error: %v", err)
+	}
+	return successCResponse("peer disconnected")
+}
+
+//export banPeer
+func banPeer(cName, cAddr, cDurationSecs *C.char) *C.char {
+	w, ok := loadedWallet(cName)
+	if !ok {
+		return errCResponse("wallet with name %q is not loaded", goString(cName))
+	}
+	durationSecs, err := strconv.ParseUint(goString(cDurationSecs), 10, 32)
+	if err != nil {
+		return errCResponse("duration is not an uint32: %v", err)
+	}
+	if err := w.BanPeer(goString(cAddr), time.Duration(durationSecs)*time.Second); err != nil {
+		return errCResponse("w.BanPeer error: %v", err)
+	}
+	return successCResponse("peer banned")
+}
@@ -3,11 +3,14 @@ package main
 import "C"
 import (
 	"encoding/json"
+	"errors"
 	"strconv"
 	"strings"
+	"time"
 
-	"decred.org/dcrwallet/v5/spv"
 	dcrwallet "decred.org/dcrwallet/v5/wallet"
+	"decred.org/dcrwallet/v5/wallet/udb"
+	"github.com/decred/libwallet/dcr"
 )
 
 //export syncWallet
@@ -22,106 +25,7 @@ func syncWallet(cName, cPeers *C.char) *C.char {
 			peers = append(peers, p)
 		}
 	}
-	ntfns := &spv.Notifications{
-		Synced: func(sync bool) {
-			w.syncStatusMtx.Lock()
-			w.syncStatusCode = SSCComplete
-			w.syncStatusMtx.Unlock()
-			w.log.Debug("Sync completed.")
-		},
-		PeerConnected: func(peerCount int32, addr string) {
-			w.syncStatusMtx.Lock()
-			w.numPeers = int(peerCount)
-			w.syncStatusMtx.Unlock()
-			w.log.Debugf("Connected to peer at %s. %d total peers.", addr, peerCount)
-		},
-		PeerDisconnected: func(peerCount int32, addr string) {
-			w.syncStatusMtx.Lock()
-			w.numPeers = int(peerCount)
-			w.syncStatusMtx.Unlock()
-			w.log.Debugf("Disconnected from peer at %s. %d total peers.", addr, peerCount)
-		},
-		FetchMissingCFiltersStarted: func() {
-			w.syncStatusMtx.Lock()
-			if w.rescanning {
-				w.syncStatusMtx.Unlock()
-				return
-			}
-			w.syncStatusCode = SSCFetchingCFilters
-			w.syncStatusMtx.Unlock()
-			w.log.Debug("Fetching missing cfilters started.")
-		},
-		FetchMissingCFiltersProgress: func(startCFiltersHeight, endCFiltersHeight int32) {
-			w.syncStatusMtx.Lock()
-			w.cfiltersHeight = int(endCFiltersHeight)
-			w.syncStatusMtx.Unlock()
-			w.log.Debugf("Fetching cfilters from %d to %d.", startCFiltersHeight, endCFiltersHeight)
-		},
-		FetchMissingCFiltersFinished: func() {
-			w.syncStatusMtx.Lock()
-			w.cfiltersHeight = w.targetHeight
-			w.syncStatusMtx.Unlock()
-			w.log.Debug("Finished fetching missing cfilters.")
-		},
-		FetchHeadersStarted: func() {
-			w.syncStatusMtx.Lock()
-			if w.rescanning {
-				w.syncStatusMtx.Unlock()
-				return
-			}
-			w.syncStatusCode = SSCFetchingHeaders
-			w.syncStatusMtx.Unlock()
-			w.log.Debug("Fetching headers started.")
-		},
-		FetchHeadersProgress: func(lastHeaderHeight int32, lastHeaderTime int64) {
-			w.syncStatusMtx.Lock()
-			w.headersHeight = int(lastHeaderHeight)
-			w.syncStatusMtx.Unlock()
-			w.log.Debugf("Fetching headers to %d.", lastHeaderHeight)
-		},
-		FetchHeadersFinished: func() {
-			w.syncStatusMtx.Lock()
-			w.headersHeight = w.targetHeight
-			w.syncStatusMtx.Unlock()
-			w.log.Debug("Fetching headers finished.")
-		},
-		DiscoverAddressesStarted: func() {
-			w.syncStatusMtx.Lock()
-			if w.rescanning {
-				w.syncStatusMtx.Unlock()
-				return
-			}
-			w.syncStatusCode = SSCDiscoveringAddrs
-			w.syncStatusMtx.Unlock()
-			w.log.Debug("Discover addresses started.")
-		},
-		DiscoverAddressesFinished: func() {
-			w.log.Debug("Discover addresses finished.")
-		},
-		RescanStarted: func() {
-			w.syncStatusMtx.Lock()
-			if w.rescanning {
-				w.syncStatusMtx.Unlock()
-				return
-			}
-			w.syncStatusCode = SSCRescanning
-			w.syncStatusMtx.Unlock()
-			w.log.Debug("Rescan started.")
-		},
-		RescanProgress: func(rescannedThrough int32) {
-			w.syncStatusMtx.Lock()
-			w.rescanHeight = int(rescannedThrough)
-			w.syncStatusMtx.Unlock()
-			w.log.Debugf("Rescanned through block %d.", rescannedThrough)
-		},
-		RescanFinished: func() {
-			w.syncStatusMtx.Lock()
-			w.rescanHeight = w.targetHeight
-			w.syncStatusMtx.Unlock()
-			w.log.Debug("Rescan finished.")
-		},
-	}
-	if err := w.StartSync(w.ctx, ntfns, peers...); err != nil {
+	if err := w.StartSync(w.ctx, nil, peers...); err != nil {
 		return errCResponse("%v", err)
 	}
 	return successCResponse("sync started")
@@ -134,35 +38,26 @@ func syncWalletStatus(cName *C.char) *C.char {
 		return errCResponse("wallet with name %q does not exist", goString(cName))
 	}
 
-	w.syncStatusMtx.RLock()
-	var ssc, cfh, hh, rh, np = w.syncStatusCode, w.cfiltersHeight, w.headersHeight, w.rescanHeight, w.numPeers
-	w.syncStatusMtx.RUnlock()
-
-	// Sometimes it appears we miss a notification during start up. This is
-	// a bandaid to put us as synced in that case.
-	//
-	// TODO: Figure out why we would miss a notification.
-	synced, targetHeight := w.IsSynced(w.ctx)
-	w.syncStatusMtx.Lock()
-	if ssc != SSCComplete && synced && !w.rescanning {
-		ssc = SSCComplete
-		w.syncStatusCode = ssc
-	}
-	w.syncStatusMtx.Unlock()
-
+	p := w.SyncProgress()
 	ss := &SyncStatusRes{
-		SyncStatusCode: int(ssc),
-		SyncStatus:     ssc.String(),
-		TargetHeight:   int(targetHeight),
-		NumPeers:       np,
-	}
-	switch ssc {
-	case SSCFetchingCFilters:
-		ss.CFiltersHeight = cfh
-	case SSCFetchingHeaders:
-		ss.HeadersHeight = hh
-	case SSCRescanning:
-		ss.RescanHeight = rh
+		SyncStatusCode: int(p.Stage),
+		SyncStatus:     p.Stage.String(),
+		TargetHeight:   int(p.TargetHeight),
+		NumPeers:       int(p.NumPeers),
+		Percent:        p.Percent,
+		ETASecs:        int64(p.ETA.Seconds()),
+		Failures:       p.Failures,
+	}
+	switch p.Stage {
+	case dcr.SyncStageFetchingCFilters:
+		ss.CFiltersHeight = int(p.CFiltersHeight)
+	case dcr.SyncStageFetchingHeaders:
+		ss.HeadersHeight = int(p.HeadersHeight)
+	case dcr.SyncStageRescanning:
+		ss.RescanHeight = int(p.RescanHeight)
+	}
+	if p.LastErr != nil {
+		ss.LastErr = p.LastErr.Error()
 	}
 	b, err := json.Marshal(ss)
 	if err != nil {
@@ -186,47 +81,72 @@ func rescanFromHeight(cName, cHeight *C.char) *C.char {
 	if !synced {
 		return errCResponseWithCode(ErrCodeNotSynced, "rescanFromHeight requested on an unsynced wallet")
 	}
-	w.syncStatusMtx.Lock()
-	if w.rescanning {
-		w.syncStatusMtx.Unlock()
-		return errCResponse("wallet %q already rescanning", name)
+	if err := w.startRescan(int32(height)); err != nil {
+		return errCResponse("wallet %q: %v", name, err)
 	}
-	w.syncStatusCode = SSCRescanning
-	w.rescanning = true
-	w.rescanHeight = int(height)
-	w.syncStatusMtx.Unlock()
+	return successCResponse("rescan from height %d for wallet %q started", height, name)
+}
+
+// startRescan rescans the wallet from height in the background.
+func (w *wallet) startRescan(height int32) error {
+	prog, err := w.StartRescan(w.ctx, height)
+	if err != nil {
+		return err
+	}
+	w.readRescanProgress(prog)
+	return nil
+}
+
+// readRescanProgress logs the errors of a background rescan until it ends.
+func (w *wallet) readRescanProgress(prog <-chan dcrwallet.RescanProgress) {
 	w.Add(1)
 	go func() {
-		defer func() {
-			w.syncStatusMtx.Lock()
-			w.syncStatusCode = SSCComplete
-			w.rescanning = false
-			w.syncStatusMtx.Unlock()
-			w.Done()
-		}()
-		prog := make(chan dcrwallet.RescanProgress)
-		go func() {
-			w.RescanProgressFromHeight(w.ctx, int32(height), prog)
-		}()
-		for {
-			select {
-			case p, open := <-prog:
-				if !open {
-					return
-				}
-				if p.Err != nil {
-					log.Errorf("rescan wallet %q error: %v", name, err)
-					return
-				}
-				w.syncStatusMtx.Lock()
-				w.rescanHeight = int(p.ScannedThrough)
-				w.syncStatusMtx.Unlock()
-			case <-w.ctx.Done():
-				return
+		defer w.Done()
+		// The rescan ends when the wallet context is canceled, so read
+		// until the channel is closed.
+		for p := range prog {
+			if p.Err != nil {
+				w.log.Errorf("rescan error: %v", p.Err)
 			}
 		}
 	}()
-	return successCResponse("rescan from height %d for wallet %q started", height, name)
+}
+
+//export setBirthState
+func setBirthState(cName, cReq *C.char) *C.char {
+	w, ok := loadedWallet(cName)
+	if !ok {
+		return errCResponse("wallet with name %q is not loaded", goString(cName))
+	}
+	var req SetBirthStateReq
+	if err := json.Unmarshal([]byte(goString(cReq)), &req); err != nil {
+		return errCResponse("malformed set birth state request: %v", err)
+	}
+	bs := &udb.BirthdayState{
+		Time:        time.Unix(req.Time, 0).Add(time.Hour * -24),
+		SetFromTime: true,
+	}
+	if req.Height != nil {
+		bs = &udb.BirthdayState{
+			Height:        *req.Height,
+			SetFromHeight: true,
+		}
+	}
+	rescanHeight, prog, err := w.SetBirthState(w.ctx, bs)
+	if errors.Is(err, dcr.ErrNotSynced) {
+		return errCResponseWithCode(ErrCodeNotSynced, "setBirthState requires a synced wallet: %v", err)
+	}
+	if err != nil {
+		return errCResponse("unable to set birth state: %v", err)
+	}
+	if prog != nil {
+		w.readRescanProgress(prog)
+	}
+	b, err := json.Marshal(&SetBirthStateRes{RescanHeight: rescanHeight})
+	if err != nil {
+		return errCResponse("unable to marshal set birth state result: %v", err)
+	}
+	return successCResponse("%s", b)
 }
 
 //export birthState
@@ -4,9 +4,9 @@ import "C"
 import (
 	"encoding/hex"
 	"encoding/json"
-	"math"
 	"strconv"
 
+	wallettypes "decred.org/dcrwallet/v5/rpc/jsonrpc/types"
 	dcrwallet "decred.org/dcrwallet/v5/wallet"
 	"github.com/decred/dcrd/chaincfg/chainhash"
 	"github.com/decred/dcrd/txscript/v4/stdaddr"
@@ -27,6 +27,11 @@ func createTransaction(cName, cCreateTxJSONReq *C.char) *C.char {
 		return errCResponse("malformed sign send request: %v", err)
 	}
 
+	acct, err := w.accountNumber(req.Account)
+	if err != nil {
+		return errCResponse("unknown account %q: %v", req.Account, err)
+	}
+
 	outputs := make([]*dcr.Output, len(req.Outputs))
 	for i, out := range req.Outputs {
 		o := &dcr.Output{
@@ -54,14 +59,8 @@ func createTransaction(cName, cCreateTxJSONReq *C.char) *C.char {
 		ignoreInputs[i] = o
 	}
 
-	if req.Sign {
-		if err := w.MainWallet().Unlock(w.ctx, []byte(req.Password), nil); err != nil {
-			return errCResponse("cannot unlock wallet: %v", err)
-		}
-		defer w.MainWallet().Lock()
-	}
-
-	txBytes, txhash, fee, err := w.CreateTransaction(w.ctx, outputs, inputs, ignoreInputs, uint64(req.FeeRate), req.SendAll, req.Sign)
+	txBytes, txhash, fee, err := w.CreateTransaction(w.ctx, acct, outputs, inputs, ignoreInputs,
+		uint64(req.FeeRate), req.SendAll, req.Sign, []byte(req.Password))
 	if err != nil {
 		return errCResponse("unable to sign send transaction: %v", err)
 	}
@@ -108,12 +107,16 @@ func abandonTransaction(cName, cTxID *C.char) *C.char {
 }
 
 //export listUnspents
-func listUnspents(cName *C.char) *C.char {
+func listUnspents(cName, cAccountName *C.char) *C.char {
 	w, exists := loadedWallet(cName)
 	if !exists {
 		return errCResponse("wallet with name %q does not exist", goString(cName))
 	}
-	res, err := w.MainWallet().ListUnspent(w.ctx, 1, math.MaxInt32, nil, defaultAccount)
+	acct, err := w.accountNumber(goString(cAccountName))
+	if err != nil {
+		return errCResponse("unknown account %q: %v", goString(cAccountName), err)
+	}
+	res, err := w.AccountUnspents(w.ctx, acct, 1)
 	if err != nil {
 		return errCResponse("unable to get unspents: %v", err)
 	}
@@ -165,7 +168,7 @@ func estimateFee(cName, cNBlocks *C.char) *C.char {
 }
 
 //export listTransactions
-func listTransactions(cName, cFrom, cCount *C.char) *C.char {
+func listTransactions(cName, cAccountName, cFrom, cCount *C.char) *C.char {
 	w, exists := loadedWallet(cName)
 	if !exists {
 		return errCResponse("wallet with name %q does not exist", goString(cName))
@@ -178,7 +181,18 @@ func listTransactions(cName, cFrom, cCount *C.char) *C.char {
 	if err != nil {
 		return errCResponse("count is not an int: %v", err)
 	}
-	res, err := w.MainWallet().ListTransactions(w.ctx, int(from), int(count))
+	var res []wallettypes.ListTransactionsResult
+	// Transactions for all accounts are listed if no account is specified.
+	if accountName := goString(cAccountName); accountName == "" {
+		res, err = w.MainWallet().ListTransactions(w.ctx, int(from), int(count))
+	} else {
+		var acct uint32
+		acct, err = w.accountNumber(accountName)
+		if err != nil {
+			return errCResponse("unknown account %q: %v", accountName, err)
+		}
+		res, err = w.AccountTransactions(w.ctx, acct, int(from), int(count))
+	}
 	if err != nil {
 		return errCResponse("unable to get transactions: %v", err)
 	}
@@ -6,12 +6,16 @@ import (
 	"fmt"
 
 	wallettypes "decred.org/dcrwallet/v5/rpc/jsonrpc/types"
+	"github.com/decred/libwallet/dcr"
 )
 
 const (
 	// ErrCodeNotSynced is returned when the wallet must be synced to perform an
 	// action but is not.
 	ErrCodeNotSynced = 1
+	// ErrCodeWalletDataNotFound is returned when loading a wallet whose
+	// database exists but whose wallet data file is missing.
+	ErrCodeWalletDataNotFound = 2
 )
 
 // CResponse is used for all returns when using the cgo libwallet. Payload only
@@ -68,22 +72,6 @@ func successCResponse(val string, args ...any) *C.char {
 	return cString(string(b))
 }
 
-type SyncStatusCode int
-
-const (
-	SSCNotStarted SyncStatusCode = iota
-	SSCFetchingCFilters
-	SSCFetchingHeaders
-	SSCDiscoveringAddrs
-	SSCRescanning
-	SSCComplete
-)
-
-func (ssc SyncStatusCode) String() string {
-	return [...]string{"not started", "fetching cfilters", "fetching headers",
-		"discovering addresses", "rescanning", "sync complete"}[ssc]
-}
-
 type SyncStatusRes struct {
 	SyncStatusCode int    `json:"syncstatuscode"`
 	SyncStatus     string `json:"syncstatus"`
@@ -92,6 +80,14 @@ type SyncStatusRes struct {
 	CFiltersHeight int    `json:"cfiltersheight,omitempty"`
 	HeadersHeight  int    `json:"headersheight,omitempty"`
 	RescanHeight   int    `json:"rescanheight,omitempty"`
+	// Overall progress from 0 to 100.
+	Percent float64 `json:"percent"`
+	// Estimated seconds until sync completes, zero if unknown.
+	ETASecs int64 `json:"etasecs"`
+	// The error that last ended sync, if any.
+	LastErr string `json:"lasterr,omitempty"`
+	// The number of consecutive failed sync attempts.
+	Failures int `json:"failures"`
 }
 
 type Input struct {
@@ -105,6 +101,9 @@ type Output struct {
 }
 
 type CreateTxReq struct {
+	// Account is the name of the account to spend from. The default account
+	// is used if empty.
+	Account      string   `json:"account"`
 	Outputs      []Output `json:"outputs"`
 	Inputs       []Input  `json:"inputs"`
 	IgnoreInputs []Input  `json:"ignoreinputs"`
@@ -150,29 +149,126 @@ type BirthdayState struct {
 	SetFromTime   bool   `json:"setfromtime"`
 }
 
+type SetBirthStateReq struct {
+	// The birthday block height. Used instead of time if set.
+	Height *uint32 `json:"height"`
+	// The unix birthday.
+	Time int64 `json:"time"`
+}
+
+type SetBirthStateRes struct {
+	// The height a rescan was started from, or -1 if no rescan was
+	// needed.
+	RescanHeight int32 `json:"rescanheight"`
+}
+
+type LockStateRes struct {
+	Locked bool `json:"locked"`
+	// Whether the wallet was unlocked with unlockWallet and is not
+	// relocked after signing.
+	Session bool `json:"session"`
+	// The unix time the session ends, or zero if it only ends with
+	// lockWallet.
+	Until int64 `json:"until"`
+}
+
+type AccountRes struct {
+	Number      uint32 `json:"number"`
+	Name        string `json:"name"`
+	Confirmed   int64  `json:"confirmed"`
+	Unconfirmed int64  `json:"unconfirmed"`
+}
+
 type AddressesRes struct {
 	Used   []string `json:"used"`
 	Unused []string `json:"unused"`
 	Index  uint32   `json:"index"`
 }
 
+type MnemonicRes struct {
+	// The detected seed type. 0 is 15 words, 1 is 12 words, 2 is 24 words
+	// and 3 is 33 words.
+	SeedType int `json:"seedtype"`
+	// The unix birthday encoded in the mnemonic, if any.
+	Birthday *int64 `json:"birthday,omitempty"`
+	// The detected language of the words, such as english or japanese.
+	Language string `json:"language"`
+}
+
+type SeedStateRes struct {
+	// Whether the encrypted seed is stored with the wallet.
+	HasSeed bool `json:"hasseed"`
+	// Whether the user verified their seed backup.
+	BackedUp bool `json:"backedup"`
+}
+
+type ExportSeedSharesReq struct {
+	Pass string `json:"pass"`
+	// Threshold is the number of shares needed to recover the wallet.
+	Threshold int `json:"threshold"`
+	// Count is the number of shares to create.
+	Count int `json:"count"`
+}
+
+type VerifySeedSharesReq struct {
+	// SLIP-0039 share mnemonics, at least the threshold of them.
+	Shares []string `json:"shares"`
+	// The SLIP-0039 passphrase of the shares. Empty for exported shares.
+	SharesPass string `json:"sharespass"`
+	Pass       string `json:"pass"`
+}
+
+type DeleteWalletReq struct {
+	Name    string `json:"name"`
+	DataDir string `json:"datadir"`
+	// Keep the encrypted seed so that the wallet can be restored later
+	// with uselocalseed.
+	KeepWalletData bool `json:"keepwalletdata"`
+}
+
 type Config struct {
 	Name string `json:"name"`
 	// Allow getting unused addresses when not synced.
 	AllowUnsyncedAddrs bool   `json:"unsyncedaddrs"`
 	Net                string `json:"net"`
 	DataDir            string `json:"datadir"`
-	// Only needed during creation.
+	// Only needed during creation. The unix birthday is zero if unknown.
 	Birthday int64  `json:"birthday"`
 	Pass     string `json:"pass"`
 	Mnemonic string `json:"mnemonic"`
 	SeedPass string `json:"seedpass"`
+	// The birthday block height of a recovered or watching only wallet.
+	// Used instead of the birthday if set.
+	BirthdayHeight *uint32 `json:"birthdayheight"`
+	// Estimate the birthday of a recovered wallet whose mnemonic has none
+	// after the first sync instead of scanning from the birthday.
+	EstimateBirthday bool `json:"estimatebirthday"`
+	// SLIP-0039 share mnemonics to recover the wallet from instead of a
+	// mnemonic. The seedpass is the SLIP-0039 passphrase.
+	SeedShares []string `json:"seedshares"`
+	// Optional user entropy, such as dice rolls, mixed with system
+	// randomness when creating a new seed.
+	Entropy *dcr.UserEntropy `json:"entropy"`
+	// Optional language of the mnemonic of a new wallet, such as spanish
+	// or chinese_simplified. If set, the wallet gets a 24 word mnemonic in
+	// the language instead of the default 15 word English mnemonic. The
+	// language of a recovered mnemonic is detected.
+	Language string `json:"language"`
 	// If the wallet existed before but the db was deleted to reduce
 	// storage, restore from the local encrypted seed using the provided
 	// password. Also works for watching only wallets with no password.
 	UseLocalSeed bool `json:"uselocalseed"`
 	// Only needed during watching only creation.
 	PubKey string `json:"pubkey"`
+	// Only needed to adopt a wallet whose database has a custom public
+	// passphrase.
+	PubPass string `json:"pubpass"`
+	// Optional wallet settings. They are saved on creation and the set
+	// fields are applied over the saved settings by loadWallet.
+	WalletConfig *dcr.WalletConfig `json:"config"`
+	// Optional SOCKS5 proxy, such as Tor, for peer connections and HTTP
+	// requests. It is not saved and must be provided every time.
+	Proxy *dcr.ProxyConfig `json:"proxy"`
 }
 
 type AddrFromExtKey struct {
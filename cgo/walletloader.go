@@ -4,11 +4,13 @@ import "C"
 import (
 	"context"
 	"encoding/json"
-	"strings"
+	"errors"
+	"os"
+	"strconv"
 	"sync"
 	"time"
 
-	dexmnemonic "decred.org/dcrdex/client/mnemonic"
+	dcrwallet "decred.org/dcrwallet/v5/wallet"
 	"github.com/decred/libwallet/dcr"
 	"github.com/decred/libwallet/mnemonic"
 	"github.com/decred/slog"
@@ -24,10 +26,7 @@ type wallet struct {
 	ctx       context.Context
 	cancelCtx context.CancelFunc
 
-	syncStatusMtx                                                       sync.RWMutex
-	syncStatusCode                                                      SyncStatusCode
-	targetHeight, cfiltersHeight, headersHeight, rescanHeight, numPeers int
-	rescanning, allowUnsyncedAddrs                                      bool
+	allowUnsyncedAddrs bool
 }
 
 //export createWallet
@@ -52,46 +51,52 @@ func createWallet(cConfig *C.char) *C.char {
 	logger := logBackend.SubLogger(name)
 	params := dcr.CreateWalletParams{
 		OpenWalletParams: dcr.OpenWalletParams{
-			Net:      cfg.Net,
-			DataDir:  cfg.DataDir,
-			DbDriver: "bdb", // use badgerdb for mobile!
-			Logger:   logger,
+			Net:          cfg.Net,
+			DataDir:      cfg.DataDir,
+			DbDriver:     "bdb", // use badgerdb for mobile!
+			Logger:       logger,
+			WalletConfig: cfg.WalletConfig,
+			Proxy:        cfg.Proxy,
 		},
-		Pass: []byte(cfg.Pass),
+		Pass:    []byte(cfg.Pass),
+		Entropy: cfg.Entropy,
+	}
+	if cfg.Language != "" {
+		lang, err := mnemonic.ParseLanguage(cfg.Language)
+		if err != nil {
+			return errCResponse("%v", err)
+		}
+		params.Language = &lang
 	}
 
 	var recoveryConfig *dcr.RecoveryCfg
 	if cfg.Mnemonic != "" {
-		var (
-			seed     []byte
-			birthday time.Time
-			seedType dcr.SeedType
-			err      error
-		)
-		nWords := len(strings.Fields(cfg.Mnemonic))
-		switch nWords {
-		case 15:
-			seed, birthday, err = dexmnemonic.DecodeMnemonic(cfg.Mnemonic)
-			seedType = dcr.STFifteenWords
-		case 12:
-			seed, err = mnemonic.DecodeMnemonic(cfg.Mnemonic)
-			birthday = time.Unix(cfg.Birthday, 0)
-			seedType = dcr.STTwelveWords
-		case 24:
-			seed, err = mnemonic.DecodeMnemonic(cfg.Mnemonic)
-			birthday = time.Unix(cfg.Birthday, 0)
-			seedType = dcr.STTwentyFourWords
-		default:
-			return errCResponse("unknown mnemonic format. expected 12, 15, or 24 words, got %d", nWords)
-		}
+		seed, seedType, lang, mnemonicBirthday, err := dcr.ParseMnemonic(cfg.Mnemonic)
 		if err != nil {
-			return errCResponse("unable to decode wallet mnemonic: %v", err)
+			return errCResponse("%v", err)
+		}
+		birthday := configBirthday(&cfg)
+		if mnemonicBirthday != nil {
+			birthday = *mnemonicBirthday
+		}
+		recoveryConfig = &dcr.RecoveryCfg{
+			Seed:           seed,
+			SeedPass:       []byte(cfg.SeedPass),
+			SeedType:       seedType,
+			Language:       lang,
+			Birthday:       birthday,
+			BirthdayHeight: cfg.BirthdayHeight,
+			// Fifteen word mnemonics have a birthday.
+			EstimateBirthday: cfg.EstimateBirthday && mnemonicBirthday == nil,
 		}
+	}
+	if len(cfg.SeedShares) != 0 {
 		recoveryConfig = &dcr.RecoveryCfg{
-			Seed:     seed,
-			SeedPass: []byte(cfg.SeedPass),
-			SeedType: seedType,
-			Birthday: birthday,
+			SeedShares:       cfg.SeedShares,
+			SeedPass:         []byte(cfg.SeedPass),
+			Birthday:         configBirthday(&cfg),
+			BirthdayHeight:   cfg.BirthdayHeight,
+			EstimateBirthday: cfg.EstimateBirthday,
 		}
 	}
 	if cfg.UseLocalSeed {
@@ -140,11 +145,15 @@ func createWatchOnlyWallet(cConfig *C.char) *C.char {
 	logger := logBackend.SubLogger(name)
 	params := dcr.CreateWalletParams{
 		OpenWalletParams: dcr.OpenWalletParams{
-			Net:      cfg.Net,
-			DataDir:  cfg.DataDir,
-			DbDriver: "bdb",
-			Logger:   logger,
+			Net:          cfg.Net,
+			DataDir:      cfg.DataDir,
+			DbDriver:     "bdb",
+			Logger:       logger,
+			WalletConfig: cfg.WalletConfig,
+			Proxy:        cfg.Proxy,
 		},
+		Birthday:       configBirthday(&cfg),
+		BirthdayHeight: cfg.BirthdayHeight,
 	}
 
 	walletCtx, cancel := context.WithCancel(mainCtx)
@@ -186,10 +195,12 @@ func loadWallet(cConfig *C.char) *C.char {
 
 	logger := logBackend.SubLogger(name)
 	params := dcr.OpenWalletParams{
-		Net:      cfg.Net,
-		DataDir:  cfg.DataDir,
-		DbDriver: "bdb", // use badgerdb for mobile!
-		Logger:   logger,
+		Net:          cfg.Net,
+		DataDir:      cfg.DataDir,
+		DbDriver:     "bdb", // use badgerdb for mobile!
+		Logger:       logger,
+		WalletConfig: cfg.WalletConfig,
+		Proxy:        cfg.Proxy,
 	}
 
 	walletCtx, cancel := context.WithCancel(mainCtx)
@@ -197,6 +208,9 @@ func loadWallet(cConfig *C.char) *C.char {
 	w, err := dcr.LoadWallet(walletCtx, params)
 	if err != nil {
 		cancel()
+		if errors.Is(err, dcr.ErrWalletDataNotFound) {
+			return errCResponseWithCode(ErrCodeWalletDataNotFound, "%v", err)
+		}
 		return errCResponse("%v", err)
 	}
 
@@ -215,6 +229,132 @@ func loadWallet(cConfig *C.char) *C.char {
 	return successCResponse("wallet %q loaded", name)
 }
 
+//export adoptWallet
+func adoptWallet(cConfig *C.char) *C.char {
+	walletsMtx.Lock()
+	defer walletsMtx.Unlock()
+	if !initialized {
+		return errCResponse("libwallet is not initialized")
+	}
+
+	configJSON := goString(cConfig)
+	var cfg Config
+	if err := json.Unmarshal([]byte(configJSON), &cfg); err != nil {
+		return errCResponse("malformed config: %v", err)
+	}
+
+	name := cfg.Name
+	if _, exists := wallets[name]; exists {
+		return errCResponse("wallet already exists with name: %q", name)
+	}
+
+	logger := logBackend.SubLogger(name)
+	params := dcr.AdoptWalletParams{
+		OpenWalletParams: dcr.OpenWalletParams{
+			Net:          cfg.Net,
+			DataDir:      cfg.DataDir,
+			DbDriver:     "bdb", // use badgerdb for mobile!
+			Logger:       logger,
+			WalletConfig: cfg.WalletConfig,
+			Proxy:        cfg.Proxy,
+		},
+		PubPass: []byte(cfg.PubPass),
+	}
+
+	walletCtx, cancel := context.WithCancel(mainCtx)
+
+	w, err := dcr.AdoptWallet(walletCtx, params)
+	if err != nil {
+		cancel()
+		return errCResponse("%v", err)
+	}
+
+	wallets[name] = &wallet{
+		Wallet:             w,
+		log:                logger,
+		ctx:                walletCtx,
+		cancelCtx:          cancel,
+		allowUnsyncedAddrs: cfg.AllowUnsyncedAddrs,
+	}
+	return successCResponse("wallet %q adopted", name)
+}
+
+//export validateMnemonic
+func validateMnemonic(cMnemonic *C.char) *C.char {
+	_, seedType, lang, birthday, err := dcr.ParseMnemonic(goString(cMnemonic))
+	if err != nil {
+		return errCResponse("%v", err)
+	}
+
+	res := &MnemonicRes{
+		SeedType: int(seedType),
+		Language: lang.String(),
+	}
+	if birthday != nil {
+		unix := birthday.Unix()
+		res.Birthday = &unix
+	}
+	b, err := json.Marshal(res)
+	if err != nil {
+		return errCResponse("unable to marshal mnemonic result: %v", err)
+	}
+
+	return successCResponse("%s", b)
+}
+
+const (
+	// maxWordSuggestionDistance is the most edits between a word and its
+	// suggestions.
+	maxWordSuggestionDistance = 2
+	// maxMnemonicRepairs is the most candidate phrases returned by
+	// repairMnemonic.
+	maxMnemonicRepairs = 20
+)
+
+//export completeMnemonicWord
+func completeMnemonicWord(cPrefix *C.char) *C.char {
+	return wordsCResponse(mnemonic.CompleteWord(goString(cPrefix)))
+}
+
+//export suggestMnemonicWords
+func suggestMnemonicWords(cWord *C.char) *C.char {
+	return wordsCResponse(mnemonic.SuggestWords(goString(cWord), maxWordSuggestionDistance))
+}
+
+//export repairMnemonic
+func repairMnemonic(cMnemonic *C.char) *C.char {
+	// Repair any mnemonic format that uses the word list.
+	candidates, err := mnemonic.RepairMnemonicFunc(goString(cMnemonic), func(m string) error {
+		_, _, _, _, err := dcr.ParseMnemonic(m)
+		return err
+	}, maxMnemonicRepairs)
+	if err != nil {
+		return errCResponse("unable to repair mnemonic: %v", err)
+	}
+	return wordsCResponse(candidates)
+}
+
+// configBirthday returns the config birthday. A zero time is returned if the
+// birthday is unknown so that the wallet scans from the genesis block.
+func configBirthday(cfg *Config) time.Time {
+	if cfg.Birthday == 0 {
+		return time.Time{}
+	}
+	return time.Unix(cfg.Birthday, 0)
+}
+
+// wordsCResponse returns words as a json array.
+func wordsCResponse(words []string) *C.char {
+	if words == nil {
+		words = []string{}
+	}
+	b, err := json.Marshal(words)
+	if err != nil {
+		return errCResponse("unable to marshal words: %v", err)
+	}
+	return successCResponse("%s", b)
+}
+
 //export walletSeed
 func walletSeed(cName, cPass *C.char) *C.char {
 	w, ok := loadedWallet(cName)
@@ -230,17 +370,133 @@ func walletSeed(cName, cPass *C.char) *C.char {
 	return successCResponse("%s", seed)
 }
 
+//export verifySeed
+func verifySeed(cName, cMnemonic, cPass *C.char) *C.char {
+	w, ok := loadedWallet(cName)
+	if !ok {
+		return errCResponse("wallet with name %q not loaded", goString(cName))
+	}
+
+	if err := w.VerifySeed(goString(cMnemonic), []byte(goString(cPass))); err != nil {
+		return errCResponse("w.VerifySeed error: %v", err)
+	}
+
+	return successCResponse("seed verified")
+}
+
+//export verifySeedShares
+func verifySeedShares(cName, cReq *C.char) *C.char {
+	w, ok := loadedWallet(cName)
+	if !ok {
+		return errCResponse("wallet with name %q not loaded", goString(cName))
+	}
+
+	var req VerifySeedSharesReq
+	if err := json.Unmarshal([]byte(goString(cReq)), &req); err != nil {
+		return errCResponse("malformed verify seed shares request: %v", err)
+	}
+	if err := w.VerifySeedShares(req.Shares, []byte(req.SharesPass), []byte(req.Pass)); err != nil {
+		return errCResponse("w.VerifySeedShares error: %v", err)
+	}
+
+	return successCResponse("seed shares verified")
+}
+
+//export seedState
+func seedState(cName *C.char) *C.char {
+	w, ok := loadedWallet(cName)
+	if !ok {
+		return errCResponse("wallet with name %q not loaded", goString(cName))
+	}
+
+	res := &SeedStateRes{
+		HasSeed:  w.HasStoredSeed(),
+		BackedUp: w.SeedBackedUp(),
+	}
+	b, err := json.Marshal(res)
+	if err != nil {
+		return errCResponse("unable to marshal seed state: %v", err)
+	}
+
+	return successCResponse("%s", b)
+}
+
+//export exportSeedShares
+func exportSeedShares(cName, cReq *C.char) *C.char {
+	w, ok := loadedWallet(cName)
+	if !ok {
+		return errCResponse("wallet with name %q not loaded", goString(cName))
+	}
+
+	var req ExportSeedSharesReq
+	if err := json.Unmarshal([]byte(goString(cReq)), &req); err != nil {
+		return errCResponse("malformed export seed shares request: %v", err)
+	}
+	shares, err := w.ExportSeedShares([]byte(req.Pass), req.Threshold, req.Count)
+	if err != nil {
+		return errCResponse("w.ExportSeedShares error: %v", err)
+	}
+	return wordsCResponse(shares)
+}
+
+//export entropyAudit
+func entropyAudit(cName *C.char) *C.char {
+	w, ok := loadedWallet(cName)
+	if !ok {
+		return errCResponse("wallet with name %q not loaded", goString(cName))
+	}
+
+	audit := w.EntropyAudit()
+	if audit == nil {
+		return successCResponse(emptyJsonObject)
+	}
+	b, err := json.Marshal(audit)
+	if err != nil {
+		return errCResponse("unable to marshal entropy audit: %v", err)
+	}
+	return successCResponse("%s", b)
+}
+
+//export deleteStoredSeed
+func deleteStoredSeed(cName, cPass *C.char) *C.char {
+	w, ok := loadedWallet(cName)
+	if !ok {
+		return errCResponse("wallet with name %q not loaded", goString(cName))
+	}
+
+	if err := w.DeleteStoredSeed([]byte(goString(cPass))); err != nil {
+		return errCResponse("w.DeleteStoredSeed error: %v", err)
+	}
+
+	return successCResponse("stored seed deleted")
+}
+
 //export walletBalance
-func walletBalance(cName *C.char) *C.char {
+func walletBalance(cName, cAccountName *C.char) *C.char {
 	w, ok := loadedWallet(cName)
 	if !ok {
 		return errCResponse("wallet with name %q not loaded", goString(cName))
 	}
 
 	const confs = 1
-	bals, err := w.AccountBalances(w.ctx, confs)
-	if err != nil {
-		return errCResponse("w.AccountBalances error: %v", err)
+	var bals []dcrwallet.Balances
+	// The balances of all accounts are summed if no account is specified.
+	if accountName := goString(cAccountName); accountName == "" {
+		var err error
+		bals, err = w.AccountBalances(w.ctx, confs)
+		if err != nil {
+			return errCResponse("w.AccountBalances error: %v", err)
+		}
+	} else {
+		acct, err := w.accountNumber(accountName)
+		if err != nil {
+			return errCResponse("unknown account %q: %v", accountName, err)
+		}
+		bal, err := w.AccountBalance(w.ctx, acct, confs)
+		if err != nil {
+			return errCResponse("w.AccountBalance error: %v", err)
+		}
+		bals = append(bals, bal)
 	}
 
 	balMap := map[string]int64{
@@ -279,6 +535,29 @@ func closeWallet(cName *C.char) *C.char {
 	return successCResponse("wallet %q shutdown", name)
 }
 
+//export deleteWallet
+func deleteWallet(cDeleteReq *C.char) *C.char {
+	walletsMtx.Lock()
+	defer walletsMtx.Unlock()
+	if !initialized {
+		return errCResponse("libwallet is not initialized")
+	}
+
+	var req DeleteWalletReq
+	if err := json.Unmarshal([]byte(goString(cDeleteReq)), &req); err != nil {
+		return errCResponse("malformed delete wallet request: %v", err)
+	}
+	if _, loaded := wallets[req.Name]; loaded {
+		return errCResponse("wallet with name %q is loaded and must be closed first", req.Name)
+	}
+
+	opts := &dcr.DeleteWalletOpts{KeepWalletData: req.KeepWalletData}
+	if err := dcr.DeleteWallet(req.DataDir, opts); err != nil {
+		return errCResponse("dcr.DeleteWallet error: %v", err)
+	}
+	return successCResponse("wallet %q deleted", req.Name)
+}
+
 //export changePassphrase
 func changePassphrase(cName, cOldPass, cNewPass *C.char) *C.char {
 	w, ok := loadedWallet(cName)
@@ -301,3 +580,104 @@ func changePassphrase(cName, cOldPass, cNewPass *C.char) *C.char {
 
 	return successCResponse("passphrase changed")
 }
+
+//export unlockWallet
+func unlockWallet(cName, cPass, cTimeoutSecs *C.char) *C.char {
+	w, ok := loadedWallet(cName)
+	if !ok {
+		return errCResponse("wallet with name %q not loaded", goString(cName))
+	}
+	timeoutSecs, err := strconv.ParseUint(goString(cTimeoutSecs), 10, 32)
+	if err != nil {
+		return errCResponse("timeout is not an uint32: %v", err)
+	}
+
+	timeout := time.Duration(timeoutSecs) * time.Second
+	if err := w.UnlockFor(w.ctx, []byte(goString(cPass)), timeout); err != nil {
+		return errCResponse("cannot unlock wallet: %v", err)
+	}
+	if timeout == 0 {
+		return successCResponse("wallet unlocked until locked")
+	}
+	return successCResponse("wallet unlocked for %v", timeout)
+}
+
+//export lockWallet
+func lockWallet(cName *C.char) *C.char {
+	w, ok := loadedWallet(cName)
+	if !ok {
+		return errCResponse("wallet with name %q not loaded", goString(cName))
+	}
+	w.Lock()
+	return successCResponse("wallet locked")
+}
+
+//export isLocked
+func isLocked(cName *C.char) *C.char {
+	w, ok := loadedWallet(cName)
+	if !ok {
+		return errCResponse("wallet with name %q not loaded", goString(cName))
+	}
+	state := w.LockState()
+	res := &LockStateRes{
+		Locked:  state.Locked,
+		Session: state.Session,
+	}
+	if !state.Until.IsZero() {
+		res.Until = state.Until.Unix()
+	}
+	b, err := json.Marshal(res)
+	if err != nil {
+		return errCResponse("unable to marshal lock state: %v", err)
+	}
+	return successCResponse("%s", b)
+}
+
+//export exportBackup
+func exportBackup(cName, cBackupPath, cPass *C.char) *C.char {
+	w, ok := loadedWallet(cName)
+	if !ok {
+		return errCResponse("wallet with name %q not loaded", goString(cName))
+	}
+
+	if err := w.ExportBackupFile(goString(cBackupPath), []byte(goString(cPass))); err != nil {
+		return errCResponse("w.ExportBackupFile error: %v", err)
+	}
+
+	return successCResponse("backup written to %q", goString(cBackupPath))
+}
+
+//export restoreBackup
+func restoreBackup(cBackupPath, cDataDir, cNet, cPass *C.char) *C.char {
+	f, err := os.Open(goString(cBackupPath))
+	if err != nil {
+		return errCResponse("unable to open backup file: %v", err)
+	}
+	defer f.Close()
+
+	if err := dcr.RestoreBackup(f, goString(cDataDir), goString(cNet), []byte(goString(cPass))); err != nil {
+		return errCResponse("dcr.RestoreBackup error: %v", err)
+	}
+
+	return successCResponse("backup restored to %q", goString(cDataDir))
+}
+
+//export upgradeWatchOnlyWallet
+func upgradeWatchOnlyWallet(cName, cMnemonic, cSeedPass, cWalletPass *C.char) *C.char {
+	w, ok := loadedWallet(cName)
+	if !ok {
+		return errCResponse("wallet with name %q not loaded", goString(cName))
+	}
+
+	seed, seedType, lang, _, err := dcr.ParseMnemonic(goString(cMnemonic))
+	if err != nil {
+		return errCResponse("%v", err)
+	}
+
+	err = w.UpgradeWatchOnly(w.ctx, seed, []byte(goString(cSeedPass)), seedType, lang, []byte(goString(cWalletPass)))
+	if err != nil {
+		return errCResponse("w.UpgradeWatchOnly error: %v", err)
+	}
+
+	return successCResponse("wallet %q upgraded", goString(cName))
+}
@@ -0,0 +1,142 @@
+package dcr
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	wallettypes "decred.org/dcrwallet/v5/rpc/jsonrpc/types"
+	"decred.org/dcrwallet/v5/wallet"
+	"decred.org/dcrwallet/v5/wallet/udb"
+)
+
+// Account describes a wallet account and its balances.
+type Account struct {
+	Number   uint32
+	Name     string
+	Balances wallet.Balances
+}
+
+// CreateAccount creates the next BIP0044 account with the provided name and
+// returns its account number. Unless the wallet is watching only, it is
+// unlocked with pass and locked again after unless an unlock session is
+// active, in which case pass may be empty.
+func (w *Wallet) CreateAccount(ctx context.Context, name string, pass []byte) (uint32, error) {
+	if name == "" {
+		return 0, fmt.Errorf("account name cannot be empty")
+	}
+	if w.isUpgradedWatchOnly() {
+		return 0, fmt.Errorf("%w, so new accounts cannot be created", errSeedSignerAccount)
+	}
+	if !w.mainWallet.WatchingOnly() {
+		done, err := w.UnlockForSigning(ctx, pass)
+		if err != nil {
+			return 0, fmt.Errorf("cannot unlock wallet: %w", err)
+		}
+		defer done()
+	}
+	return w.mainWallet.NextAccount(ctx, name)
+}
+
+// RenameAccount changes the name of the account with the provided number.
+func (w *Wallet) RenameAccount(ctx context.Context, account uint32, newName string) error {
+	if newName == "" {
+		return fmt.Errorf("account name cannot be empty")
+	}
+	if account == udb.ImportedAddrAccount {
+		return fmt.Errorf("the imported account cannot be renamed")
+	}
+	return w.mainWallet.RenameAccount(ctx, account, newName)
+}
+
+// ListAccounts returns every account in the wallet along with its balances
+// calculated with the provided number of required confirmations.
+func (w *Wallet) ListAccounts(ctx context.Context, confs int32) ([]*Account, error) {
+	res, err := w.mainWallet.Accounts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	accts := make([]*Account, 0, len(res.Accounts))
+	for _, a := range res.Accounts {
+		bal, err := w.mainWallet.AccountBalance(ctx, a.AccountNumber, confs)
+		if err != nil {
+			return nil, err
+		}
+		accts = append(accts, &Account{
+			Number:   a.AccountNumber,
+			Name:     a.AccountName,
+			Balances: bal,
+		})
+	}
+	return accts, nil
+}
+
+// AccountUnspents returns the unspent outputs belonging to the account with at
+// least minConf confirmations.
+func (w *Wallet) AccountUnspents(ctx context.Context, account uint32, minConf int32) ([]*wallettypes.ListUnspentResult, error) {
+	name, err := w.mainWallet.AccountName(ctx, account)
+	if err != nil {
+		return nil, err
+	}
+	return w.mainWallet.ListUnspent(ctx, minConf, math.MaxInt32, nil, name)
+}
+
+// AccountTransactions returns the transaction history of an account sorted from
+// old to new. Like the wallet's ListTransactions, from is the number of most
+// recent transactions to skip and count is the maximum number of transactions
+// to return. A single transaction may produce more than one result.
+func (w *Wallet) AccountTransactions(ctx context.Context, account uint32, from, count int) ([]wallettypes.ListTransactionsResult, error) {
+	if from < 0 || count < 0 {
+		return nil, fmt.Errorf("from and count cannot be negative")
+	}
+	name, err := w.mainWallet.AccountName(ctx, account)
+	if err != nil {
+		return nil, err
+	}
+	all, err := w.mainWallet.ListAllTransactions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return accountTransactions(all, name, from, count), nil
+}
+
+// accountTransactions returns the results of the account with name from all,
+// which is sorted from old to new, skipping the from most recent transactions
+// and returning at most count transactions.
+func accountTransactions(all []wallettypes.ListTransactionsResult, name string, from, count int) []wallettypes.ListTransactionsResult {
+	// Collect the account's results from newest to oldest.
+	acctResults := make([]wallettypes.ListTransactionsResult, 0)
+	for i := len(all) - 1; i >= 0; i-- {
+		if all[i].Account == name {
+			acctResults = append(acctResults, all[i])
+		}
+	}
+
+	// Skip and count whole transactions rather than individual results.
+	var (
+		start, end = len(acctResults), len(acctResults)
+		lastTxID   string
+		nTxs       int
+	)
+	for i, res := range acctResults {
+		if res.TxID == lastTxID {
+			continue
+		}
+		lastTxID = res.TxID
+		nTxs++
+		if nTxs == from+1 {
+			start = i
+		}
+		if nTxs == from+count+1 {
+			end = i
+			break
+		}
+	}
+	txs := acctResults[start:end]
+
+	// Reverse the list so that it is sorted from old to new.
+	for i, j := 0, len(txs)-1; i < j; i, j = i+1, j-1 {
+		txs[i], txs[j] = txs[j], txs[i]
+	}
+	return txs
+}
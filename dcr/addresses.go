@@ -12,6 +12,7 @@ import (
 	walleterrors "decred.org/dcrwallet/v5/errors"
 	wallettypes "decred.org/dcrwallet/v5/rpc/jsonrpc/types"
 	"decred.org/dcrwallet/v5/wallet"
+	"decred.org/dcrwallet/v5/wallet/udb"
 	"github.com/decred/base58"
 	"github.com/decred/dcrd/chaincfg/v3"
 	"github.com/decred/dcrd/crypto/blake256"
@@ -28,7 +29,23 @@ import (
 // returned if nUnused is zero. All used addresses are returned if nUsed is
 // zero. index is the first unused index.
 func (w *Wallet) DefaultAccountAddresses(ctx context.Context, nUsed, nUnused uint32) (used, unused []string, index uint32, err error) {
-	xpub, err := hdkeychain.NewKeyFromString(w.metaData.DefaultAccountXPub, w.chainParams)
+	return w.AccountAddresses(ctx, udb.DefaultAccountNum, nUsed, nUnused)
+}
+
+// AccountAddresses returns external addresses for the account. Returns used
+// and unused addresses up to nUsed and nUnused. No unused addresses are
+// returned if nUnused is zero. All used addresses are returned if nUsed is
+// zero. index is the first unused index.
+func (w *Wallet) AccountAddresses(ctx context.Context, accountNum, nUsed, nUnused uint32) (used, unused []string, index uint32, err error) {
+	var xpub *hdkeychain.ExtendedKey
+	if accountNum == udb.DefaultAccountNum {
+		w.seedMtx.Lock()
+		defaultXPub := w.metaData.DefaultAccountXPub
+		w.seedMtx.Unlock()
+		xpub, err = hdkeychain.NewKeyFromString(defaultXPub, w.chainParams)
+	} else {
+		xpub, err = w.mainWallet.AccountXpub(ctx, accountNum)
+	}
 	if err != nil {
 		return nil, nil, 0, err
 	}
@@ -36,7 +53,6 @@ func (w *Wallet) DefaultAccountAddresses(ctx context.Context, nUsed, nUnused uin
 	if err != nil {
 		return nil, nil, 0, err
 	}
-	const accountNum = 0
 	endExt, _, err := w.mainWallet.BIP0044BranchNextIndexes(ctx, accountNum)
 	if err != nil {
 		return nil, nil, 0, err
@@ -0,0 +1,157 @@
+package dcr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	walleterrors "decred.org/dcrwallet/v5/errors"
+	"decred.org/dcrwallet/v5/wallet"
+)
+
+// AdoptWallet opens a wallet database created by dcrwallet or Decrediton that
+// has no wallet data file and creates the wallet data from the database's
+// default account xpub, coin type and birth state. The seed is not stored, so
+// it cannot be shown and the wallet is restored from its mnemonic if the
+// database is lost. A database with a public passphrase is opened with
+// params.PubPass and the passphrase is removed once the wallet data is saved,
+// as libwallet wallets have none. The wallet is returned open.
+func AdoptWallet(ctx context.Context, params AdoptWalletParams) (*Wallet, error) {
+	if exists, err := WalletExistsAt(params.DataDir); err != nil {
+		return nil, err
+	} else if !exists {
+		return nil, fmt.Errorf("wallet at %q doesn't exist", params.DataDir)
+	}
+
+	chainParams, err := ParseChainParams(params.Net)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing chain params: %w", err)
+	}
+
+	if err := params.WalletConfig.validate(); err != nil {
+		return nil, fmt.Errorf("invalid wallet config: %w", err)
+	}
+
+	if err := params.Proxy.validate(); err != nil {
+		return nil, fmt.Errorf("invalid proxy config: %w", err)
+	}
+
+	if _, err := retrieveWalletData(params.DataDir, params.Logger); err == nil {
+		return nil, errors.New("wallet data already exists")
+	} else if !errors.Is(err, ErrWalletDataNotFound) {
+		return nil, err
+	}
+
+	openDir, err := markWalletOpen(params.DataDir)
+	if err != nil {
+		return nil, err
+	}
+	db, err := wallet.OpenDB(params.DbDriver, filepath.Join(params.DataDir, walletDbName))
+	if err != nil {
+		markWalletClosed(openDir)
+		return nil, fmt.Errorf("wallet.OpenDB error: %w", err)
+	}
+	bailOnWallet := true // changed to false if there are no errors below
+	defer func() {
+		if bailOnWallet {
+			if err := db.Close(); err != nil {
+				params.Logger.Errorf("Failed to close wallet database after AdoptWallet error: %v", err)
+			}
+			markWalletClosed(openDir)
+		}
+	}()
+
+	walletCfg := newWalletConfig(db, chainParams, params.WalletConfig)
+	w, err := wallet.Open(ctx, walletCfg)
+	if errors.Is(err, walleterrors.Passphrase) {
+		// dcrwallet uses a default public passphrase unless another is
+		// chosen.
+		walletCfg.PubPassphrase = params.PubPass
+		if len(walletCfg.PubPassphrase) == 0 {
+			walletCfg.PubPassphrase = []byte(wallet.InsecurePubPassphrase)
+		}
+		w, err = wallet.Open(ctx, walletCfg)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("wallet.Open error: %w", err)
+	}
+
+	xpub, err := w.AccountXpub(ctx, 0)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get default account xpub: %w", err)
+	}
+	coinType, err := w.CoinType(ctx)
+	if err != nil && !errors.Is(err, walleterrors.WatchingOnly) {
+		return nil, fmt.Errorf("unable to get coin type: %w", err)
+	}
+	birthday, err := adoptedBirthday(ctx, w)
+	if err != nil {
+		return nil, err
+	}
+
+	// dcrwallet and Decrediton seeds are 33 word mnemonics.
+	wd, err := newWalletData(nil, nil, xpub.String(), birthday, nil, STThirtyThreeWords, params.WalletConfig)
+	if err != nil {
+		return nil, err
+	}
+	wd.CoinType = coinType
+	if err := saveWalletData(wd, params.DataDir); err != nil {
+		return nil, fmt.Errorf("saveWalletData error: %v", err)
+	}
+
+	// Only remove the public passphrase now that the wallet data is saved.
+	// If that fails, the wallet data is removed so that the database is
+	// left as it was found and adopting can be retried.
+	if len(walletCfg.PubPassphrase) != 0 {
+		if err := w.ChangePublicPassphrase(ctx, walletCfg.PubPassphrase, nil); err != nil {
+			for _, name := range []string{walletDataFileName, walletDataBackupFileName} {
+				_ = os.Remove(filepath.Join(params.DataDir, name))
+			}
+			return nil, fmt.Errorf("unable to remove public passphrase: %w", err)
+		}
+	}
+
+	bailOnWallet = false
+	events := newEventQueue(defaultEventQueueSize)
+	wal := &Wallet{
+		dir:         params.DataDir,
+		dbDriver:    params.DbDriver,
+		chainParams: chainParams,
+		log:         params.Logger,
+		openDir:     openDir,
+		metaData:    wd,
+		db:          db,
+		mainWallet:  w,
+		syncHelper:  &syncHelper{log: params.Logger},
+		progress:    syncProgress{events: events},
+		events:      events,
+		proxy:       params.Proxy,
+	}
+	wal.startTxNotifications()
+	return wal, nil
+}
+
+// adoptedBirthday returns the birthday of a wallet from its birth state, or the
+// genesis block time if it has none.
+func adoptedBirthday(ctx context.Context, w *wallet.Wallet) (time.Time, error) {
+	birthday := w.ChainParams().GenesisBlock.Header.Timestamp
+	bs, err := w.BirthState(ctx)
+	if err != nil {
+		return birthday, fmt.Errorf("unable to get birth state: %w", err)
+	}
+	switch {
+	case bs == nil || bs.SetFromHeight:
+	case bs.SetFromTime:
+		birthday = bs.Time
+	default:
+		header, err := w.BlockHeader(ctx, &bs.Hash)
+		if err != nil {
+			return birthday, fmt.Errorf("unable to get birthday block: %w", err)
+		}
+		birthday = header.Timestamp
+	}
+	return birthday, nil
+}
@@ -0,0 +1,592 @@
+package dcr
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kevinburke/nacl"
+	"github.com/kevinburke/nacl/secretbox"
+)
+
+// A backup bundle has the following layout:
+//
+//	magic (4) | version (1) | envelope header | nonce prefix (16) | chunks
+//
+// The envelope header holds the kdf settings and salt used to derive the key
+// from the pass, as for EncryptData. The payload is encrypted in chunks of up
+// to backupChunkSize bytes so that it can be streamed, each stored as the
+// length of the sealed chunk (4) followed by the sealed chunk. The nonce of a
+// chunk is the nonce prefix, the index of the chunk (7) and a byte set to one
+// for the last chunk, so chunks cannot be reordered or dropped unnoticed.
+//
+// The payload is a tar archive that starts with a manifest holding the network
+// and the checksum of every file, followed by the files themselves.
+const (
+	backupVersion          = 2
+	backupManifestFileName = "manifest.json"
+	backupChunkSize        = 64 * 1024
+	backupNoncePrefixSize  = 16
+	backupMaxManifestSize  = 1 << 20
+)
+
+var backupMagic = []byte{'l', 'w', 'b', 'k'}
+
+// ErrInvalidBackup is returned when a backup bundle is malformed or corrupt.
+var ErrInvalidBackup = errors.New("invalid backup")
+
+type backupManifest struct {
+	Version int           `json:"version"`
+	Net     string        `json:"net"`
+	Created int64         `json:"created"`
+	Files   []*backupFile `json:"files"`
+}
+
+type backupFile struct {
+	Name      string `json:"name"`
+	Size      int64  `json:"size"`
+	SHA256Hex string `json:"sha256hex"`
+}
+
+// backupEntry is a file to archive in a backup.
+type backupEntry struct {
+	file *backupFile
+	open func() (io.Reader, error)
+}
+
+// newBackupEntry returns an entry that archives b as name.
+func newBackupEntry(name string, b []byte) *backupEntry {
+	sum := sha256.Sum256(b)
+	return &backupEntry{
+		file: &backupFile{
+			Name:      name,
+			Size:      int64(len(b)),
+			SHA256Hex: hex.EncodeToString(sum[:]),
+		},
+		open: func() (io.Reader, error) {
+			return bytes.NewReader(b), nil
+		},
+	}
+}
+
+// backupSkipFile returns true for files in the data directory that are not
+// part of a backup.
+func backupSkipFile(name string) bool {
+	return name == walletDbName || name == walletDataBackupFileName ||
+		strings.Contains(name, ".tmp") ||
+		strings.HasSuffix(name, ".log")
+}
+
+// validBackupFileName returns true if name is a plain file name. Anything
+// else could be written outside of the data directory.
+func validBackupFileName(name string) bool {
+	return name != "" && name == filepath.Base(name) && name != "." && name != ".." &&
+		name != backupManifestFileName
+}
+
+// ExportBackup writes an encrypted backup of the wallet to bw. The backup holds
+// a consistent snapshot of the wallet database, the wallet data and any other
+// wallet files in the data directory. The wallet must be open. The backup can
+// be restored with RestoreBackup and the same pass.
+func (w *Wallet) ExportBackup(bw io.Writer, pass []byte) error {
+	if w.db == nil {
+		return errors.New("wallet is not open")
+	}
+	if len(pass) == 0 {
+		return errors.New("backup pass cannot be empty")
+	}
+	copier, ok := w.db.(interface{ Copy(io.Writer) error })
+	if !ok {
+		return errors.New("wallet database does not support copying")
+	}
+
+	// List the directory before the database copy is created in it.
+	dirEntries, err := os.ReadDir(w.dir)
+	if err != nil {
+		return fmt.Errorf("unable to read wallet directory: %v", err)
+	}
+
+	// Copy the database to a temporary file rather than to memory as it
+	// may be large.
+	dbFile, err := os.CreateTemp(w.dir, walletDbName+".backup*.tmp")
+	if err != nil {
+		return fmt.Errorf("unable to copy wallet database: %v", err)
+	}
+	defer func() {
+		dbFile.Close()
+		os.Remove(dbFile.Name())
+	}()
+	dbHash := sha256.New()
+	if err := copier.Copy(io.MultiWriter(dbFile, dbHash)); err != nil {
+		return fmt.Errorf("unable to copy wallet database: %v", err)
+	}
+	fi, err := dbFile.Stat()
+	if err != nil {
+		return fmt.Errorf("unable to copy wallet database: %v", err)
+	}
+	entries := []*backupEntry{{
+		file: &backupFile{
+			Name:      walletDbName,
+			Size:      fi.Size(),
+			SHA256Hex: hex.EncodeToString(dbHash.Sum(nil)),
+		},
+		open: func() (io.Reader, error) {
+			_, err := dbFile.Seek(0, io.SeekStart)
+			return dbFile, err
+		},
+	}}
+
+	// Hold the seed lock so the wallet data is not changed while reading.
+	w.seedMtx.Lock()
+	wd, err := json.MarshalIndent(w.metaData, "", " ")
+	w.seedMtx.Unlock()
+	if err != nil {
+		return fmt.Errorf("unable to marshal wallet data: %v", err)
+	}
+	entries = append(entries, newBackupEntry(walletDataFileName, wd))
+
+	for _, entry := range dirEntries {
+		name := entry.Name()
+		if !entry.Type().IsRegular() || backupSkipFile(name) || name == walletDataFileName {
+			continue
+		}
+		b, err := os.ReadFile(filepath.Join(w.dir, name))
+		if err != nil {
+			return fmt.Errorf("unable to read %s: %v", name, err)
+		}
+		entries = append(entries, newBackupEntry(name, b))
+	}
+
+	return writeBackup(bw, pass, w.chainParams.Name, entries)
+}
+
+// ExportBackupFile writes an encrypted backup of the wallet to the file at fp,
+// which is only replaced once the backup is complete. See ExportBackup.
+func (w *Wallet) ExportBackupFile(fp string, pass []byte) error {
+	return writeFileAtomicFunc(fp, func(bw io.Writer) error {
+		return w.ExportBackup(bw, pass)
+	})
+}
+
+// writeBackup writes a backup bundle of the entries for net to bw.
+func writeBackup(bw io.Writer, pass []byte, net string, entries []*backupEntry) error {
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].file.Name < entries[j].file.Name
+	})
+	manifest := &backupManifest{
+		Version: backupVersion,
+		Net:     net,
+		Created: time.Now().Unix(),
+	}
+	for _, e := range entries {
+		manifest.Files = append(manifest.Files, e.file)
+	}
+	manifestJSON, err := json.Marshal(manifest)
+	if err != nil {
+		return fmt.Errorf("unable to marshal backup manifest: %v", err)
+	}
+
+	kp, err := newKDFParams(KDFArgon2id)
+	if err != nil {
+		return err
+	}
+	key, err := kp.deriveKey(pass)
+	if err != nil {
+		return err
+	}
+	prefix := make([]byte, backupNoncePrefixSize)
+	if _, err := rand.Read(prefix); err != nil {
+		return fmt.Errorf("unable to generate nonce: %v", err)
+	}
+	header := append(append([]byte{}, backupMagic...), backupVersion)
+	header = append(header, kp.envelopeHeader()...)
+	header = append(header, prefix...)
+	if _, err := bw.Write(header); err != nil {
+		return fmt.Errorf("unable to write backup: %v", err)
+	}
+
+	sealer := &backupSealer{w: bw, key: key, prefix: prefix}
+	tw := tar.NewWriter(sealer)
+	writeFile := func(f *backupFile, r io.Reader) error {
+		hdr := &tar.Header{
+			Name:    f.Name,
+			Mode:    0600,
+			Size:    f.Size,
+			ModTime: time.Unix(manifest.Created, 0),
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		_, err := io.Copy(tw, r)
+		return err
+	}
+	manifestFile := &backupFile{Name: backupManifestFileName, Size: int64(len(manifestJSON))}
+	if err := writeFile(manifestFile, bytes.NewReader(manifestJSON)); err != nil {
+		return fmt.Errorf("unable to write backup manifest: %v", err)
+	}
+	for _, e := range entries {
+		r, err := e.open()
+		if err != nil {
+			return fmt.Errorf("unable to read %s: %v", e.file.Name, err)
+		}
+		if err := writeFile(e.file, r); err != nil {
+			return fmt.Errorf("unable to write %s to backup: %v", e.file.Name, err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("unable to write backup: %v", err)
+	}
+	if err := sealer.Close(); err != nil {
+		return fmt.Errorf("unable to write backup: %v", err)
+	}
+	return nil
+}
+
+// backupNonce returns the nonce of the chunk at index.
+func backupNonce(prefix []byte, index uint64, last bool) nacl.Nonce {
+	nonce := new([nacl.NonceSize]byte)
+	copy(nonce[:], prefix)
+	binary.BigEndian.PutUint64(nonce[backupNoncePrefixSize:], index<<8)
+	if last {
+		nonce[nacl.NonceSize-1] = 1
+	}
+	return nonce
+}
+
+// backupSealer encrypts the data written to it in chunks. Close writes the last
+// chunk.
+type backupSealer struct {
+	w      io.Writer
+	key    nacl.Key
+	prefix []byte
+	index  uint64
+	buf    []byte
+}
+
+func (s *backupSealer) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		// A full chunk is only sealed once there is more data because
+		// the last chunk is sealed with a different nonce.
+		if len(s.buf) == backupChunkSize {
+			if err := s.seal(false); err != nil {
+				return 0, err
+			}
+		}
+		k := min(backupChunkSize-len(s.buf), len(p))
+		s.buf = append(s.buf, p[:k]...)
+		p = p[k:]
+	}
+	return n, nil
+}
+
+func (s *backupSealer) Close() error {
+	return s.seal(true)
+}
+
+func (s *backupSealer) seal(last bool) error {
+	sealed := secretbox.Seal(nil, s.buf, backupNonce(s.prefix, s.index, last), s.key)
+	var size [4]byte
+	binary.BigEndian.PutUint32(size[:], uint32(len(sealed)))
+	if _, err := s.w.Write(size[:]); err != nil {
+		return err
+	}
+	if _, err := s.w.Write(sealed); err != nil {
+		return err
+	}
+	s.index++
+	s.buf = s.buf[:0]
+	return nil
+}
+
+// backupOpener decrypts the chunks written by backupSealer. It only returns
+// io.EOF after the last chunk, so a truncated bundle is an error. The first
+// error is kept in err and returned by every later Read.
+type backupOpener struct {
+	r      io.Reader
+	key    nacl.Key
+	prefix []byte
+	index  uint64
+	buf    []byte
+	last   bool
+	err    error
+}
+
+func (o *backupOpener) Read(p []byte) (int, error) {
+	for len(o.buf) == 0 {
+		if o.err != nil {
+			return 0, o.err
+		}
+		if o.last {
+			return 0, io.EOF
+		}
+		o.err = o.open()
+	}
+	n := copy(p, o.buf)
+	o.buf = o.buf[n:]
+	return n, nil
+}
+
+func (o *backupOpener) open() error {
+	readFull := func(b []byte) error {
+		_, err := io.ReadFull(o.r, b)
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return fmt.Errorf("%w: truncated", ErrInvalidBackup)
+		}
+		if err != nil {
+			return fmt.Errorf("unable to read backup: %v", err)
+		}
+		return nil
+	}
+	var size [4]byte
+	if err := readFull(size[:]); err != nil {
+		return err
+	}
+	n := binary.BigEndian.Uint32(size[:])
+	if n < secretbox.Overhead || n > backupChunkSize+secretbox.Overhead {
+		return fmt.Errorf("%w: invalid chunk size %d", ErrInvalidBackup, n)
+	}
+	sealed := make([]byte, n)
+	if err := readFull(sealed); err != nil {
+		return err
+	}
+	for _, last := range []bool{false, true} {
+		b, ok := secretbox.Open(nil, sealed, backupNonce(o.prefix, o.index, last), o.key)
+		if !ok {
+			continue
+		}
+		o.buf, o.last = b, last
+		o.index++
+		if last {
+			var extra [1]byte
+			_, err := io.ReadFull(o.r, extra[:])
+			if err == nil {
+				return fmt.Errorf("%w: data after the last chunk", ErrInvalidBackup)
+			}
+			if !errors.Is(err, io.EOF) {
+				return fmt.Errorf("unable to read backup: %v", err)
+			}
+		}
+		return nil
+	}
+	// A wrong pass cannot be told apart from a corrupt first chunk.
+	if o.index == 0 {
+		return ErrInvalidPassphrase
+	}
+	return fmt.Errorf("%w: chunk %d is corrupt", ErrInvalidBackup, o.index)
+}
+
+// check returns the error of the opener, if any, or else err. Errors reading
+// the archive are caused by the opener if it failed.
+func (o *backupOpener) check(err error) error {
+	if o.err != nil {
+		return o.err
+	}
+	return err
+}
+
+// readBackupManifest reads the manifest at the start of the archive and
+// checks that it lists the wallet database and only plain file names.
+func readBackupManifest(tr *tar.Reader) (*backupManifest, error) {
+	hdr, err := tr.Next()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBackup, err)
+	}
+	if hdr.Typeflag != tar.TypeReg || hdr.Name != backupManifestFileName {
+		return nil, fmt.Errorf("%w: missing manifest", ErrInvalidBackup)
+	}
+	if hdr.Size > backupMaxManifestSize {
+		return nil, fmt.Errorf("%w: manifest is too large", ErrInvalidBackup)
+	}
+	b, err := io.ReadAll(tr)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBackup, err)
+	}
+	manifest := new(backupManifest)
+	if err := json.Unmarshal(b, manifest); err != nil {
+		return nil, fmt.Errorf("%w: malformed manifest: %v", ErrInvalidBackup, err)
+	}
+	names := make(map[string]bool, len(manifest.Files))
+	for _, f := range manifest.Files {
+		if f == nil || !validBackupFileName(f.Name) || names[f.Name] || f.Size < 0 {
+			return nil, fmt.Errorf("%w: malformed manifest", ErrInvalidBackup)
+		}
+		names[f.Name] = true
+	}
+	if !names[walletDbName] {
+		return nil, fmt.Errorf("%w: missing wallet database", ErrInvalidBackup)
+	}
+	return manifest, nil
+}
+
+// extractBackupFile writes the archived file f read from r to a temporary file
+// in dir and returns its path once its checksum is verified.
+func extractBackupFile(r io.Reader, f *backupFile, dir string) (string, error) {
+	tmp, err := os.CreateTemp(dir, f.Name+".restore*.tmp")
+	if err != nil {
+		return "", err
+	}
+	tmpName := tmp.Name()
+	fail := func(err error) (string, error) {
+		tmp.Close()
+		os.Remove(tmpName)
+		return "", err
+	}
+	if err := tmp.Chmod(0600); err != nil {
+		return fail(err)
+	}
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(tmp, h), r); err != nil {
+		return fail(err)
+	}
+	if hex.EncodeToString(h.Sum(nil)) != f.SHA256Hex {
+		return fail(fmt.Errorf("%w: %s checksum mismatch", ErrInvalidBackup, f.Name))
+	}
+	if err := tmp.Sync(); err != nil {
+		return fail(err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpName)
+		return "", err
+	}
+	return tmpName, nil
+}
+
+// RestoreBackup restores a backup created with ExportBackup to dataDir. The
+// backup must be of a wallet for net and a wallet must not already exist in
+// dataDir. The restored wallet can then be loaded with LoadWallet.
+func RestoreBackup(r io.Reader, dataDir, net string, pass []byte) error {
+	chainParams, err := ParseChainParams(net)
+	if err != nil {
+		return fmt.Errorf("error parsing chain params: %w", err)
+	}
+	if exists, err := WalletExistsAt(dataDir); err != nil {
+		return err
+	} else if exists {
+		return fmt.Errorf("wallet at %q already exists", dataDir)
+	}
+
+	var version [5]byte
+	if _, err := io.ReadFull(r, version[:]); err != nil &&
+		!errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return fmt.Errorf("unable to read backup: %v", err)
+	}
+	if !bytes.Equal(version[:len(backupMagic)], backupMagic) {
+		return fmt.Errorf("%w: unknown format", ErrInvalidBackup)
+	}
+	if v := version[len(backupMagic)]; v != backupVersion {
+		return fmt.Errorf("%w: unsupported version %d", ErrInvalidBackup, v)
+	}
+	header := make([]byte, envelopeHeaderSize+backupNoncePrefixSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return fmt.Errorf("%w: truncated", ErrInvalidBackup)
+	}
+	kp, _, ok := parseEnvelope(header)
+	if !ok {
+		return fmt.Errorf("%w: unknown encryption", ErrInvalidBackup)
+	}
+	key, err := kp.deriveKey(pass)
+	if err != nil {
+		return err
+	}
+	opener := &backupOpener{r: r, key: key, prefix: header[envelopeHeaderSize:]}
+	tr := tar.NewReader(opener)
+
+	manifest, err := readBackupManifest(tr)
+	if err != nil {
+		return opener.check(err)
+	}
+	if manifest.Net != chainParams.Name {
+		return fmt.Errorf("%w: backup is for %s, not %s", ErrInvalidBackup, manifest.Net, chainParams.Name)
+	}
+
+	if err := checkCreateDir(dataDir); err != nil {
+		return fmt.Errorf("check wallet data directory error: %w", err)
+	}
+
+	// Extract every file to a temporary file and only move them into
+	// place once all are verified.
+	files := make(map[string]*backupFile, len(manifest.Files))
+	for _, f := range manifest.Files {
+		files[f.Name] = f
+	}
+	tmpFiles := make(map[string]string, len(files))
+	defer func() {
+		for _, tmp := range tmpFiles {
+			os.Remove(tmp) // no-op after a successful rename
+		}
+	}()
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return opener.check(fmt.Errorf("%w: %v", ErrInvalidBackup, err))
+		}
+		f := files[hdr.Name]
+		if hdr.Typeflag != tar.TypeReg || f == nil {
+			return fmt.Errorf("%w: unexpected archive entry %q", ErrInvalidBackup, hdr.Name)
+		}
+		if _, ok := tmpFiles[f.Name]; ok {
+			return fmt.Errorf("%w: duplicate archive entry %q", ErrInvalidBackup, f.Name)
+		}
+		if hdr.Size != f.Size {
+			return fmt.Errorf("%w: %s size mismatch", ErrInvalidBackup, f.Name)
+		}
+		tmp, err := extractBackupFile(tr, f, dataDir)
+		if err != nil {
+			return opener.check(fmt.Errorf("unable to restore %s: %w", f.Name, err))
+		}
+		tmpFiles[f.Name] = tmp
+	}
+	// Read to the end so that the last chunk is authenticated.
+	if _, err := io.Copy(io.Discard, opener); err != nil {
+		return err
+	}
+	for name := range files {
+		if _, ok := tmpFiles[name]; !ok {
+			return fmt.Errorf("%w: missing %s", ErrInvalidBackup, name)
+		}
+	}
+
+	// Move the database last so that a failed restore does not leave
+	// something that looks like a wallet.
+	names := make([]string, 0, len(files))
+	for name := range files {
+		if name != walletDbName {
+			names = append(names, name)
+		}
+	}
+	names = append(names, walletDbName)
+	var restored []string
+	for _, name := range names {
+		fp := filepath.Join(dataDir, name)
+		if err := os.Rename(tmpFiles[name], fp); err != nil {
+			for _, fp := range restored {
+				_ = os.Remove(fp)
+			}
+			return fmt.Errorf("unable to restore %s: %v", name, err)
+		}
+		restored = append(restored, fp)
+	}
+	// Sync the directory so that the renames are persisted. Not supported
+	// on all platforms, so errors are ignored.
+	if d, err := os.Open(dataDir); err == nil {
+		_ = d.Sync()
+		d.Close()
+	}
+	return nil
+}
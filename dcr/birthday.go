@@ -0,0 +1,348 @@
+package dcr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"decred.org/dcrwallet/v5/spv"
+	"decred.org/dcrwallet/v5/wallet"
+	"decred.org/dcrwallet/v5/wallet/udb"
+	"github.com/decred/dcrd/chaincfg/chainhash"
+	"github.com/decred/dcrd/chaincfg/v3"
+	"github.com/decred/dcrd/hdkeychain/v3"
+	"github.com/decred/dcrd/txscript/v4/stdaddr"
+	"github.com/decred/dcrd/wire"
+)
+
+// ErrNotSynced is returned when an operation needs the wallet to be synced.
+var ErrNotSynced = errors.New("wallet is not synced")
+
+// newBirthState returns the birth state of a wallet born at birthday or, if
+// height is not nil, at the block at height. A day is subtracted from the
+// birthday to allow for differences between block and wall clock times. A
+// zero or pre-genesis birthday starts at the genesis block.
+func newBirthState(birthday time.Time, height *uint32, chainParams *chaincfg.Params) *udb.BirthdayState {
+	if height != nil {
+		return &udb.BirthdayState{
+			Height:        *height,
+			SetFromHeight: true,
+		}
+	}
+	if genesis := chainParams.GenesisBlock.Header.Timestamp; birthday.Before(genesis) {
+		return &udb.BirthdayState{SetFromHeight: true}
+	}
+	return &udb.BirthdayState{
+		Time:        birthday.Add(time.Hour * -24),
+		SetFromTime: true,
+	}
+}
+
+// SetBirthState changes the birthday of the wallet to the block at bs.Height
+// if bs.SetFromHeight is set, or to the last block before bs.Time if
+// bs.SetFromTime is set. Birthdays past the wallet's best block are resolved
+// during sync. Blocks before the birthday are not scanned for transactions,
+// so if the birthday moves back in the synced chain, the wallet must be synced
+// and addresses are discovered and the wallet rescanned from the new birthday
+// in the background. The returned height is where the rescan starts and the
+// returned channel, which must be read until it is closed, reports its
+// progress. Otherwise -1 and a nil channel are returned.
+func (w *Wallet) SetBirthState(ctx context.Context, bs *udb.BirthdayState) (int32, <-chan wallet.RescanProgress, error) {
+	if bs == nil || bs.SetFromHeight == bs.SetFromTime {
+		return -1, nil, errors.New("exactly one of set from height or set from time is required")
+	}
+	oldBS, err := w.mainWallet.BirthState(ctx)
+	if err != nil {
+		return -1, nil, fmt.Errorf("unable to get birth state: %w", err)
+	}
+	tipHash, tipHeight := w.mainWallet.MainChainTip(ctx)
+	inChain := bs.SetFromHeight && int32(bs.Height) <= tipHeight
+	if bs.SetFromTime {
+		tip, err := w.mainWallet.BlockHeader(ctx, &tipHash)
+		if err != nil {
+			return -1, nil, fmt.Errorf("unable to get tip header: %w", err)
+		}
+		inChain = tip.Timestamp.After(bs.Time)
+	}
+
+	newBS := *bs
+	if !inChain {
+		// Set when the block is attached during sync. Blocks past the
+		// tip have not been scanned, so no rescan is needed.
+		if err := w.mainWallet.SetBirthState(ctx, &newBS); err != nil {
+			return -1, nil, fmt.Errorf("wallet.SetBirthState error: %w", err)
+		}
+		return -1, nil, nil
+	}
+
+	// A resolved birthday that does not move back needs no rescan.
+	oldResolved := oldBS != nil && !oldBS.SetFromHeight && !oldBS.SetFromTime
+	if synced, _ := w.IsSynced(ctx); !synced {
+		if !oldResolved || bs.SetFromTime || int32(bs.Height) < int32(oldBS.Height) {
+			return -1, nil, fmt.Errorf("birthday is in the synced chain: %w", ErrNotSynced)
+		}
+	}
+	if err := w.mainWallet.SetBirthStateAndScan(ctx, &newBS); err != nil {
+		return -1, nil, fmt.Errorf("wallet.SetBirthStateAndScan error: %w", err)
+	}
+	if oldResolved && newBS.Height >= oldBS.Height {
+		return -1, nil, nil
+	}
+	rescanHeight := int32(newBS.Height)
+	if err := w.progress.startRescan(rescanHeight); err != nil {
+		return -1, nil, fmt.Errorf("birth state set but unable to rescan: %w", err)
+	}
+	p := make(chan wallet.RescanProgress)
+	go func() {
+		if err := w.discoverActiveAddresses(ctx, &newBS.Hash); err != nil {
+			w.progress.endRescan(err)
+			p <- wallet.RescanProgress{Err: err}
+			close(p)
+			return
+		}
+		w.rescan(ctx, rescanHeight, p)
+	}()
+	return rescanHeight, p, nil
+}
+
+// discoverActiveAddresses discovers the addresses used since the block with
+// hash startHash and loads them in the syncer's filters, like the initial sync.
+func (w *Wallet) discoverActiveAddresses(ctx context.Context, startHash *chainhash.Hash) error {
+	w.syncerMtx.RLock()
+	defer w.syncerMtx.RUnlock()
+	if w.syncer == nil {
+		return ErrNotSynced
+	}
+	if err := w.mainWallet.DiscoverActiveAddresses(ctx, w.syncer, startHash, false, w.mainWallet.GapLimit()); err != nil {
+		return fmt.Errorf("address discovery error: %w", err)
+	}
+	return w.mainWallet.LoadActiveDataFilters(ctx, w.syncer, true)
+}
+
+// birthdayAddresses returns the first addresses of the default account for
+// the wallet's current coin type and the SLIP-0044 coin type.
+func (w *Wallet) birthdayAddresses(ctx context.Context) ([]stdaddr.Address, error) {
+	acctKey, err := w.mainWallet.AccountXpub(ctx, 0)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get account xpub: %w", err)
+	}
+	xpubs := []string{acctKey.String()}
+	w.seedMtx.Lock()
+	if xpub := w.metaData.DefaultAccountXPub; xpub != "" && xpub != xpubs[0] {
+		xpubs = append(xpubs, xpub)
+	}
+	w.seedMtx.Unlock()
+	var addrs []stdaddr.Address
+	for _, xpub := range xpubs {
+		key, err := hdkeychain.NewKeyFromString(xpub, w.chainParams)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse account xpub: %w", err)
+		}
+		acctAddrs, err := probeAddresses(key, defaultProbeAddresses, defaultProbeAddresses, w.chainParams)
+		if err != nil {
+			return nil, err
+		}
+		addrs = append(addrs, acctAddrs...)
+	}
+	return addrs, nil
+}
+
+// estimateBirthday finds the earliest block paying the wallet's first
+// addresses by binary searching the committed filters, moves the birthday back to the block before it and rescans from
+// there. The legacy coin type is upgraded if it has no history. The wallet must
+// be synced.
+func (w *Wallet) estimateBirthday(ctx context.Context, syncer *spv.Syncer, ntfns *spv.Notifications) error {
+	addrs, err := w.birthdayAddresses(ctx)
+	if err != nil {
+		return err
+	}
+	// Add to the filters of the synced wallet, which have its addresses.
+	if err := syncer.LoadTxFilter(ctx, false, addrs, nil); err != nil {
+		return err
+	}
+	scripts := make([][]byte, 0, len(addrs))
+	for _, addr := range addrs {
+		_, script := addr.PaymentScript()
+		scripts = append(scripts, script)
+	}
+	firstHash, err := w.firstPayingBlock(ctx, syncer, scripts)
+	if err != nil {
+		return err
+	}
+
+	var birthday int64
+	if firstHash == nil {
+		w.log.Info("No transactions found for the wallet. Keeping the birthday.")
+		bs, err := w.mainWallet.BirthState(ctx)
+		if err != nil {
+			return err
+		}
+		if err := w.upgradeUnusedCoinType(ctx, syncer, &bs.Hash); err != nil {
+			return err
+		}
+		if err := w.mainWallet.LoadActiveDataFilters(ctx, syncer, true); err != nil {
+			return err
+		}
+	} else {
+		header, err := w.mainWallet.BlockHeader(ctx, firstHash)
+		if err != nil {
+			return err
+		}
+		bs := &udb.BirthdayState{Height: header.Height, SetFromHeight: true}
+		if header.Height > 0 {
+			bs.Height--
+		}
+		if err := w.mainWallet.SetBirthStateAndScan(ctx, bs); err != nil {
+			return fmt.Errorf("wallet.SetBirthStateAndScan error: %w", err)
+		}
+		w.log.Infof("Estimated wallet birthday at block %d.", bs.Height)
+
+		// Discover addresses and rescan like the initial sync.
+		if err := w.mainWallet.DiscoverActiveAddresses(ctx, syncer, &bs.Hash, false, w.mainWallet.GapLimit()); err != nil {
+			return fmt.Errorf("address discovery error: %w", err)
+		}
+		if err := w.upgradeUnusedCoinType(ctx, syncer, &bs.Hash); err != nil {
+			return err
+		}
+		if err := w.mainWallet.LoadActiveDataFilters(ctx, syncer, true); err != nil {
+			return err
+		}
+		if err := w.rescanWithNtfns(ctx, syncer, int32(bs.Height), ntfns); err != nil {
+			return err
+		}
+		birthday = header.Timestamp.Unix()
+	}
+
+	if err := w.updateCoinType(ctx); err != nil {
+		return err
+	}
+
+	w.seedMtx.Lock()
+	defer w.seedMtx.Unlock()
+	updatedMetaData := *w.metaData
+	updatedMetaData.EstimateBirthday = false
+	if birthday != 0 {
+		updatedMetaData.Birthday = birthday
+	}
+	if err := saveWalletData(&updatedMetaData, w.dir); err != nil {
+		return err
+	}
+	*w.metaData = updatedMetaData
+	return nil
+}
+
+// firstPayingBlock returns the hash of the earliest main chain block with an
+// output paying one of scripts, or nil if there is none. The committed filters
+// stored by the wallet are binary searched for the first block that may pay
+// scripts, and only that block is fetched to rule out a false positive match.
+// The scripts must be in the syncer's transaction filter.
+func (w *Wallet) firstPayingBlock(ctx context.Context, syncer *spv.Syncer, scripts [][]byte) (*chainhash.Hash, error) {
+	paying := make(map[string]struct{}, len(scripts))
+	for _, script := range scripts {
+		paying[string(script)] = struct{}{}
+	}
+	filtersMatch := func(lo, hi int32) (bool, error) {
+		for h := lo; h <= hi; h++ {
+			bi, err := w.mainWallet.BlockInfo(ctx, wallet.NewBlockIdentifierFromHeight(h))
+			if err != nil {
+				return false, err
+			}
+			key, filter, err := w.mainWallet.CFilterV2(ctx, &bi.Hash)
+			if err != nil {
+				return false, err
+			}
+			if filter.MatchAny(key, scripts) {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+
+	_, tipHeight := w.mainWallet.MainChainTip(ctx)
+	for start := int32(0); start <= tipHeight; {
+		height, err := firstMatch(start, tipHeight, filtersMatch)
+		if err != nil || height < 0 {
+			return nil, err
+		}
+		bi, err := w.mainWallet.BlockInfo(ctx, wallet.NewBlockIdentifierFromHeight(height))
+		if err != nil {
+			return nil, err
+		}
+		var found bool
+		err = syncer.Rescan(ctx, []chainhash.Hash{bi.Hash}, func(_ *chainhash.Hash, txs []*wire.MsgTx) error {
+			for _, tx := range txs {
+				for _, out := range tx.TxOut {
+					if _, ok := paying[string(out.PkScript)]; ok {
+						found = true
+					}
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("rescan error: %w", err)
+		}
+		if found {
+			return &bi.Hash, nil
+		}
+		// A false positive, so search the blocks after it.
+		start = height + 1
+	}
+	return nil, nil
+}
+
+// firstMatch returns the lowest height from lo to hi for which match reports a
+// match, or -1 if there is none. match reports whether any height in a range
+// matches. The range is halved at each step, keeping the lower half if it
+// matches, so each height is matched about once or twice.
+func firstMatch(lo, hi int32, match func(lo, hi int32) (bool, error)) (int32, error) {
+	if lo > hi {
+		return -1, nil
+	}
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		ok, err := match(lo, mid)
+		if err != nil {
+			return -1, err
+		}
+		if ok {
+			hi = mid
+		} else {
+			lo = mid + 1
+		}
+	}
+	ok, err := match(lo, lo)
+	if err != nil || !ok {
+		return -1, err
+	}
+	return lo, nil
+}
+
+// rescanWithNtfns rescans from startHeight, reporting progress to ntfns.
+func (w *Wallet) rescanWithNtfns(ctx context.Context, syncer *spv.Syncer, startHeight int32, ntfns *spv.Notifications) (err error) {
+	if err := w.progress.startRescan(startHeight); err != nil {
+		return err
+	}
+	defer func() {
+		w.progress.endRescan(err)
+	}()
+	if ntfns != nil && ntfns.RescanStarted != nil {
+		ntfns.RescanStarted()
+	}
+	p := make(chan wallet.RescanProgress, 1)
+	go w.mainWallet.RescanProgressFromHeight(ctx, syncer, startHeight, p)
+	for prog := range p {
+		if prog.Err != nil {
+			return fmt.Errorf("rescan error: %w", prog.Err)
+		}
+		w.progress.rescanned(prog.ScannedThrough)
+		if ntfns != nil && ntfns.RescanProgress != nil {
+			ntfns.RescanProgress(prog.ScannedThrough)
+		}
+	}
+	if ntfns != nil && ntfns.RescanFinished != nil {
+		ntfns.RescanFinished()
+	}
+	return nil
+}
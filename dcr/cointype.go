@@ -0,0 +1,78 @@
+package dcr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	walleterrors "decred.org/dcrwallet/v5/errors"
+	"decred.org/dcrwallet/v5/spv"
+	"decred.org/dcrwallet/v5/wallet/udb"
+	"github.com/decred/dcrd/chaincfg/chainhash"
+)
+
+// updateCoinType records the coin type of the main wallet and its default
+// account xpub in the wallet data if they changed, such as when the coin type
+// was upgraded during sync.
+func (w *Wallet) updateCoinType(ctx context.Context) error {
+	coinType, err := w.mainWallet.CoinType(ctx)
+	if errors.Is(err, walleterrors.WatchingOnly) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	xpub, err := w.mainWallet.AccountXpub(ctx, udb.DefaultAccountNum)
+	if err != nil {
+		return err
+	}
+
+	w.seedMtx.Lock()
+	defer w.seedMtx.Unlock()
+	if w.metaData.CoinType == coinType && w.metaData.DefaultAccountXPub == xpub.String() {
+		return nil
+	}
+	updatedMetaData := *w.metaData
+	updatedMetaData.CoinType = coinType
+	updatedMetaData.DefaultAccountXPub = xpub.String()
+	if err := saveWalletData(&updatedMetaData, w.dir); err != nil {
+		return err
+	}
+	*w.metaData = updatedMetaData
+	w.log.Infof("Wallet uses coin type %d.", coinType)
+	return nil
+}
+
+// upgradeUnusedCoinType upgrades a wallet on the legacy coin type to the
+// SLIP0044 coin type if no legacy addresses were used and discovers addresses
+// of the new coin type from startBlock. The legacy coin type is kept if it has
+// history so that funds are not lost.
+func (w *Wallet) upgradeUnusedCoinType(ctx context.Context, syncer *spv.Syncer, startBlock *chainhash.Hash) error {
+	coinType, err := w.mainWallet.CoinType(ctx)
+	if errors.Is(err, walleterrors.WatchingOnly) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if coinType == w.chainParams.SLIP0044CoinType {
+		return nil
+	}
+	extNext, intNext, err := w.mainWallet.BIP0044BranchNextIndexes(ctx, udb.DefaultAccountNum)
+	if err != nil {
+		return err
+	}
+	if extNext != 0 || intNext != 0 {
+		w.log.Warnf("Wallet has history on the legacy coin type %d. Not upgrading the coin type.", coinType)
+		return nil
+	}
+	if err := w.mainWallet.UpgradeToSLIP0044CoinType(ctx); err != nil {
+		return fmt.Errorf("coin type upgrade error: %w", err)
+	}
+	w.log.Infof("Upgraded wallet from legacy coin type %d to SLIP0044 coin type %d.",
+		coinType, w.chainParams.SLIP0044CoinType)
+	if err := w.mainWallet.DiscoverActiveAddresses(ctx, syncer, startBlock, false, w.mainWallet.GapLimit()); err != nil {
+		return fmt.Errorf("address discovery error: %w", err)
+	}
+	return nil
+}
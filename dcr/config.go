@@ -1,8 +1,12 @@
 package dcr
 
 import (
+	"fmt"
+	"time"
+
 	"decred.org/dcrwallet/v5/wallet"
 	"github.com/decred/dcrd/chaincfg/v3"
+	"github.com/decred/dcrd/dcrutil/v4"
 )
 
 const (
@@ -12,10 +16,123 @@ const (
 	defaultAccountGapLimit = 10
 	defaultManualTickets   = false
 	defaultMixSplitLimit   = 10
+
+	defaultSyncRetryMinDelay = time.Second * 5
+	defaultSyncRetryMaxDelay = time.Minute * 5
+	maxSyncRetryDelaySecs    = 60 * 60 * 24
 )
 
-func newWalletConfig(db wallet.DB, chainParams *chaincfg.Params) *wallet.Config {
-	return &wallet.Config{
+// WalletConfig holds the wallet settings that may be customized for each
+// wallet. Zero values are replaced with the defaults.
+type WalletConfig struct {
+	// GapLimit is the number of unused addresses to watch past the last
+	// used address of a branch.
+	GapLimit uint32 `json:"gaplimit,omitempty"`
+	// AccountGapLimit is the number of unused accounts to allow and to look
+	// past during account discovery.
+	AccountGapLimit int `json:"accountgaplimit,omitempty"`
+	// RelayFeePerKb is the transaction relay fee in atoms per kB.
+	RelayFeePerKb int64 `json:"relayfeeperkb,omitempty"`
+	// AllowHighFees allows sending transactions with unusually high fees.
+	// It is a pointer so that a loaded wallet can be set back to false.
+	AllowHighFees *bool `json:"allowhighfees,omitempty"`
+	// MixSplitLimit is the number of parallel transactions that may be used
+	// when splitting outputs to be mixed.
+	MixSplitLimit int `json:"mixsplitlimit,omitempty"`
+	// SyncRetryMinDelaySecs and SyncRetryMaxDelaySecs bound the delay
+	// before sync is retried after it fails. The delay doubles with each
+	// consecutive failure.
+	SyncRetryMinDelaySecs int `json:"syncretrymindelaysecs,omitempty"`
+	SyncRetryMaxDelaySecs int `json:"syncretrymaxdelaysecs,omitempty"`
+	// SyncMaxAttempts is the number of consecutive failed sync attempts
+	// after which sync is stopped. Sync is retried forever if zero.
+	SyncMaxAttempts int `json:"syncmaxattempts,omitempty"`
+}
+
+// validate checks that no settings have invalid values.
+func (cfg *WalletConfig) validate() error {
+	if cfg == nil {
+		return nil
+	}
+	if cfg.AccountGapLimit < 0 {
+		return fmt.Errorf("account gap limit cannot be negative")
+	}
+	if cfg.RelayFeePerKb < 0 {
+		return fmt.Errorf("relay fee cannot be negative")
+	}
+	if cfg.MixSplitLimit < 0 {
+		return fmt.Errorf("mix split limit cannot be negative")
+	}
+	if cfg.SyncRetryMinDelaySecs < 0 || cfg.SyncRetryMaxDelaySecs < 0 {
+		return fmt.Errorf("sync retry delays cannot be negative")
+	}
+	if cfg.SyncRetryMinDelaySecs > maxSyncRetryDelaySecs || cfg.SyncRetryMaxDelaySecs > maxSyncRetryDelaySecs {
+		return fmt.Errorf("sync retry delays cannot be more than %d seconds", maxSyncRetryDelaySecs)
+	}
+	if minDelay, maxDelay := cfg.syncRetryDelays(); maxDelay < minDelay {
+		return fmt.Errorf("sync retry max delay %v is less than the min delay %v", maxDelay, minDelay)
+	}
+	if cfg.SyncMaxAttempts < 0 {
+		return fmt.Errorf("sync max attempts cannot be negative")
+	}
+	return nil
+}
+
+// merge returns a copy of cfg with the set fields of update applied. Zero
+// fields of update leave the value in cfg unchanged.
+func (cfg *WalletConfig) merge(update *WalletConfig) *WalletConfig {
+	var merged WalletConfig
+	if cfg != nil {
+		merged = *cfg
+	}
+	if update == nil {
+		return &merged
+	}
+	if update.GapLimit != 0 {
+		merged.GapLimit = update.GapLimit
+	}
+	if update.AccountGapLimit != 0 {
+		merged.AccountGapLimit = update.AccountGapLimit
+	}
+	if update.RelayFeePerKb != 0 {
+		merged.RelayFeePerKb = update.RelayFeePerKb
+	}
+	if update.AllowHighFees != nil {
+		allowHighFees := *update.AllowHighFees
+		merged.AllowHighFees = &allowHighFees
+	}
+	if update.MixSplitLimit != 0 {
+		merged.MixSplitLimit = update.MixSplitLimit
+	}
+	if update.SyncRetryMinDelaySecs != 0 {
+		merged.SyncRetryMinDelaySecs = update.SyncRetryMinDelaySecs
+	}
+	if update.SyncRetryMaxDelaySecs != 0 {
+		merged.SyncRetryMaxDelaySecs = update.SyncRetryMaxDelaySecs
+	}
+	if update.SyncMaxAttempts != 0 {
+		merged.SyncMaxAttempts = update.SyncMaxAttempts
+	}
+	return &merged
+}
+
+// syncRetryDelays returns the min and max delays before retrying sync.
+func (cfg *WalletConfig) syncRetryDelays() (minDelay, maxDelay time.Duration) {
+	minDelay, maxDelay = defaultSyncRetryMinDelay, defaultSyncRetryMaxDelay
+	if cfg == nil {
+		return minDelay, maxDelay
+	}
+	if cfg.SyncRetryMinDelaySecs != 0 {
+		minDelay = time.Duration(cfg.SyncRetryMinDelaySecs) * time.Second
+	}
+	if cfg.SyncRetryMaxDelaySecs != 0 {
+		maxDelay = time.Duration(cfg.SyncRetryMaxDelaySecs) * time.Second
+	}
+	return minDelay, maxDelay
+}
+
+func newWalletConfig(db wallet.DB, chainParams *chaincfg.Params, cfg *WalletConfig) *wallet.Config {
+	walletCfg := &wallet.Config{
 		DB:              db,
 		GapLimit:        defaultGapLimit,
 		AccountGapLimit: defaultAccountGapLimit,
@@ -25,4 +142,23 @@ func newWalletConfig(db wallet.DB, chainParams *chaincfg.Params) *wallet.Config
 		Params:          chainParams,
 		MixSplitLimit:   defaultMixSplitLimit,
 	}
+	if cfg == nil {
+		return walletCfg
+	}
+	if cfg.GapLimit != 0 {
+		walletCfg.GapLimit = cfg.GapLimit
+	}
+	if cfg.AccountGapLimit != 0 {
+		walletCfg.AccountGapLimit = cfg.AccountGapLimit
+	}
+	if cfg.RelayFeePerKb != 0 {
+		walletCfg.RelayFee = dcrutil.Amount(cfg.RelayFeePerKb)
+	}
+	if cfg.MixSplitLimit != 0 {
+		walletCfg.MixSplitLimit = cfg.MixSplitLimit
+	}
+	if cfg.AllowHighFees != nil {
+		walletCfg.AllowHighFees = *cfg.AllowHighFees
+	}
+	return walletCfg
 }
@@ -1,12 +1,43 @@
 package dcr
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	dexmnemonic "decred.org/dcrdex/client/mnemonic"
+	wallettypes "decred.org/dcrwallet/v5/rpc/jsonrpc/types"
+	"decred.org/dcrwallet/v5/spv"
+	"decred.org/dcrwallet/v5/wallet"
+	"decred.org/dcrwallet/v5/wallet/udb"
+	"decred.org/dcrwallet/v5/walletseed"
 	"github.com/davecgh/go-spew/spew"
+	"github.com/decred/dcrd/chaincfg/chainhash"
 	"github.com/decred/dcrd/chaincfg/v3"
 	"github.com/decred/dcrd/hdkeychain/v3"
+	"github.com/decred/dcrd/txscript/v4/stdaddr"
 	"github.com/decred/dcrd/wire"
+	"github.com/decred/libwallet/mnemonic"
+	"github.com/decred/libwallet/mnemonic/slip39"
+	"github.com/decred/slog"
 )
 
 func TestAddrFromExtendedKey(t *testing.T) {
@@ -211,7 +242,7 @@ func TestDustThreshold(t *testing.T) {
 
 func TestDecryptSeed(t *testing.T) {
 	metaData := new(walletData)
-	w := &Wallet{metaData: metaData}
+	w := &Wallet{dir: t.TempDir(), metaData: metaData, log: slog.Disabled}
 
 	tests := []struct {
 		name, seed, wantMnemonic string
@@ -248,6 +279,3136 @@ func TestDecryptSeed(t *testing.T) {
 			if mnemonic != test.wantMnemonic {
 				t.Fatalf("expected mnemonic %v but got %v", test.wantMnemonic, mnemonic)
 			}
+			// The legacy encrypted seed should have been upgraded.
+			if metaData.EncryptedSeedHex == test.seed {
+				t.Fatal("expected seed encryption to be upgraded")
+			}
+			saved, err := retrieveWalletData(w.dir, slog.Disabled)
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if saved.EncryptedSeedHex != metaData.EncryptedSeedHex {
+				t.Fatal("upgraded seed was not saved")
+			}
+			mnemonic, err = w.DecryptSeed(test.pass)
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if mnemonic != test.wantMnemonic {
+				t.Fatalf("expected mnemonic %v after upgrade but got %v", test.wantMnemonic, mnemonic)
+			}
+		})
+	}
+}
+
+func TestNewWalletConfig(t *testing.T) {
+	allowHighFees := true
+	tests := []struct {
+		name              string
+		cfg               *WalletConfig
+		wantGapLimit      uint32
+		wantRelayFee      int64
+		wantAllowHighFees bool
+		wantErr           bool
+	}{{
+		name:         "nil uses defaults",
+		wantGapLimit: defaultGapLimit,
+		wantRelayFee: defaultRelayFeePerKb,
+	}, {
+		name:         "zero values use defaults",
+		cfg:          &WalletConfig{},
+		wantGapLimit: defaultGapLimit,
+		wantRelayFee: defaultRelayFeePerKb,
+	}, {
+		name:              "custom values",
+		cfg:               &WalletConfig{GapLimit: 1000, RelayFeePerKb: 2e4, AllowHighFees: &allowHighFees},
+		wantGapLimit:      1000,
+		wantRelayFee:      2e4,
+		wantAllowHighFees: true,
+	}, {
+		name:    "negative relay fee",
+		cfg:     &WalletConfig{RelayFeePerKb: -1},
+		wantErr: true,
+	}, {
+		name:    "sync retry max delay below min delay",
+		cfg:     &WalletConfig{SyncRetryMinDelaySecs: 600},
+		wantErr: true,
+	}, {
+		name:    "sync retry delay too long",
+		cfg:     &WalletConfig{SyncRetryMinDelaySecs: 10, SyncRetryMaxDelaySecs: math.MaxInt64 / int(time.Second)},
+		wantErr: true,
+	}, {
+		name:    "negative sync max attempts",
+		cfg:     &WalletConfig{SyncMaxAttempts: -1},
+		wantErr: true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.cfg.validate()
+			if test.wantErr {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			walletCfg := newWalletConfig(nil, chaincfg.MainNetParams(), test.cfg)
+			if walletCfg.GapLimit != test.wantGapLimit {
+				t.Fatalf("expected gap limit %d but got %d", test.wantGapLimit, walletCfg.GapLimit)
+			}
+			if int64(walletCfg.RelayFee) != test.wantRelayFee {
+				t.Fatalf("expected relay fee %d but got %d", test.wantRelayFee, walletCfg.RelayFee)
+			}
+			if walletCfg.AllowHighFees != test.wantAllowHighFees {
+				t.Fatalf("expected allow high fees %v but got %v", test.wantAllowHighFees, walletCfg.AllowHighFees)
+			}
+		})
+	}
+}
+
+func TestLoadWalletConfig(t *testing.T) {
+	ctx := context.Background()
+	yes, no := true, false
+	openParams := OpenWalletParams{
+		Net:      "simnet",
+		DataDir:  t.TempDir(),
+		DbDriver: "bdb",
+		Logger:   slog.Disabled,
+		WalletConfig: &WalletConfig{
+			GapLimit:              200,
+			AllowHighFees:         &yes,
+			SyncRetryMinDelaySecs: 10,
+			SyncMaxAttempts:       3,
+		},
+	}
+	w, err := CreateWallet(ctx, CreateWalletParams{
+		OpenWalletParams: openParams,
+		Pass:             []byte("pass"),
+	}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err := w.CloseWallet(); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		cfg     *WalletConfig
+		want    WalletConfig
+		wantErr bool
+	}{{
+		name: "nil keeps saved settings",
+		want: WalletConfig{GapLimit: 200, AllowHighFees: &yes, SyncRetryMinDelaySecs: 10, SyncMaxAttempts: 3},
+	}, {
+		name: "set fields are merged",
+		cfg:  &WalletConfig{RelayFeePerKb: 2e4, SyncMaxAttempts: 5},
+		want: WalletConfig{GapLimit: 200, RelayFeePerKb: 2e4, AllowHighFees: &yes, SyncRetryMinDelaySecs: 10, SyncMaxAttempts: 5},
+	}, {
+		name: "allow high fees is unset",
+		cfg:  &WalletConfig{AllowHighFees: &no},
+		want: WalletConfig{GapLimit: 200, RelayFeePerKb: 2e4, AllowHighFees: &no, SyncRetryMinDelaySecs: 10, SyncMaxAttempts: 5},
+	}, {
+		name:    "merged settings are invalid",
+		cfg:     &WalletConfig{SyncRetryMaxDelaySecs: 5},
+		wantErr: true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			params := openParams
+			params.WalletConfig = test.cfg
+			w, err := LoadWallet(ctx, params)
+			if test.wantErr {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			wd, err := retrieveWalletData(openParams.DataDir, slog.Disabled)
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			for _, cfg := range []*WalletConfig{w.metaData.Config, wd.Config} {
+				if !reflect.DeepEqual(*cfg, test.want) {
+					t.Fatalf("expected config %+v but got %+v", test.want, *cfg)
+				}
+			}
+		})
+	}
+}
+
+func TestEncryptData(t *testing.T) {
+	data, pass := []byte("data"), []byte("pass")
+	tests := []struct {
+		name string
+		kdf  KDF
+	}{{
+		name: "scrypt",
+		kdf:  KDFScrypt,
+	}, {
+		name: "argon2id",
+		kdf:  KDFArgon2id,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			encrypted, err := encryptDataWithKDF(data, pass, test.kdf)
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if isLegacyData(encrypted) {
+				t.Fatal("expected envelope format")
+			}
+			// The random salt makes every encryption unique.
+			encrypted2, err := encryptDataWithKDF(data, pass, test.kdf)
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if bytes.Equal(encrypted, encrypted2) {
+				t.Fatal("expected different ciphertexts")
+			}
+			decrypted, err := DecryptData(encrypted, pass)
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if !bytes.Equal(decrypted, data) {
+				t.Fatalf("expected %x but got %x", data, decrypted)
+			}
+			if _, err := DecryptData(encrypted, []byte("wrong")); !errors.Is(err, ErrInvalidPassphrase) {
+				t.Fatalf("expected ErrInvalidPassphrase but got %v", err)
+			}
+		})
+	}
+}
+
+func TestDecryptDataKDFLimits(t *testing.T) {
+	data, pass := []byte("data"), []byte("pass")
+	tests := []struct {
+		name    string
+		kdf     KDF
+		params  [3]uint32
+		wantErr bool
+	}{{
+		name:   "ok argon2id limits",
+		kdf:    KDFArgon2id,
+		params: [3]uint32{1, 8, maxArgon2Threads},
+	}, {
+		name:    "argon2id memory",
+		kdf:     KDFArgon2id,
+		params:  [3]uint32{defaultArgon2Time, math.MaxUint32, defaultArgon2Threads},
+		wantErr: true,
+	}, {
+		name:    "argon2id time",
+		kdf:     KDFArgon2id,
+		params:  [3]uint32{maxArgon2Time + 1, defaultArgon2Memory, defaultArgon2Threads},
+		wantErr: true,
+	}, {
+		name:    "argon2id zero threads",
+		kdf:     KDFArgon2id,
+		params:  [3]uint32{defaultArgon2Time, defaultArgon2Memory, 0},
+		wantErr: true,
+	}, {
+		name:    "scrypt N",
+		kdf:     KDFScrypt,
+		params:  [3]uint32{1 << 31, legacyScryptR, legacyScryptP},
+		wantErr: true,
+	}, {
+		name:    "scrypt r",
+		kdf:     KDFScrypt,
+		params:  [3]uint32{legacyScryptN, maxScryptR + 1, 1},
+		wantErr: true,
+	}, {
+		name:    "scrypt r·p",
+		kdf:     KDFScrypt,
+		params:  [3]uint32{legacyScryptN, legacyScryptR, math.MaxUint32},
+		wantErr: true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			encrypted, err := encryptDataWithKDF(data, pass, test.kdf)
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			// Tamper with the kdf params in the header. The key no
+			// longer matches, so decryption fails either way, but
+			// params above the limits must fail before deriving a key.
+			binary.BigEndian.PutUint32(encrypted[6:10], test.params[0])
+			binary.BigEndian.PutUint32(encrypted[10:14], test.params[1])
+			binary.BigEndian.PutUint32(encrypted[14:18], test.params[2])
+			_, err = DecryptData(encrypted, pass)
+			if test.wantErr {
+				if !errors.Is(err, errKDFParams) {
+					t.Fatalf("expected errKDFParams but got %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrInvalidPassphrase) {
+				t.Fatalf("expected ErrInvalidPassphrase but got %v", err)
+			}
+		})
+	}
+}
+
+func TestWalletDataPersistence(t *testing.T) {
+	dir := t.TempDir()
+	fp := filepath.Join(dir, walletDataFileName)
+
+	if err := saveWalletData(&walletData{DefaultAccountXPub: "first"}, dir); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err := saveWalletData(&walletData{DefaultAccountXPub: "second"}, dir); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	fi, err := os.Stat(fp)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if perm := fi.Mode().Perm(); runtime.GOOS != "windows" && perm != 0600 {
+		t.Fatalf("expected permissions 0600 but got %o", perm)
+	}
+	wd, err := retrieveWalletData(dir, slog.Disabled)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if wd.DefaultAccountXPub != "second" || wd.Version != walletDataVersion {
+		t.Fatalf("unexpected wallet data %+v", wd)
+	}
+
+	// A corrupt file is not replaced by the backup.
+	if err := os.WriteFile(fp, []byte("{"), 0600); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if _, err := retrieveWalletData(dir, slog.Disabled); !errors.Is(err, ErrWalletDataCorrupt) {
+		t.Fatalf("expected ErrWalletDataCorrupt but got %v", err)
+	}
+	if b, err := os.ReadFile(fp); err != nil || string(b) != "{" {
+		t.Fatalf("expected the corrupt file to be kept: %v", err)
+	}
+
+	// The backup holds the previous version and is used if the file is
+	// lost. The file is restored from it.
+	if err := os.Remove(fp); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	wd, err = retrieveWalletData(dir, slog.Disabled)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if wd.DefaultAccountXPub != "first" {
+		t.Fatalf("expected backup wallet data but got %+v", wd)
+	}
+	wd, err = readWalletDataFile(fp)
+	if err != nil {
+		t.Fatalf("expected the wallet data file to be restored: %v", err)
+	}
+	if wd.DefaultAccountXPub != "first" {
+		t.Fatalf("expected restored wallet data but got %+v", wd)
+	}
+
+	// Without either file, empty data is only returned if there is no
+	// wallet database.
+	for _, name := range []string{walletDataFileName, walletDataBackupFileName} {
+		if err := os.Remove(filepath.Join(dir, name)); err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+	}
+	if _, err := retrieveWalletData(dir, slog.Disabled); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, walletDbName), nil, 0600); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if _, err := retrieveWalletData(dir, slog.Disabled); !errors.Is(err, ErrWalletDataNotFound) {
+		t.Fatalf("expected ErrWalletDataNotFound but got %v", err)
+	}
+}
+
+func TestMigrateWalletData(t *testing.T) {
+	wd := &walletData{}
+	migrated, err := migrateWalletData(wd)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !migrated || wd.Version != walletDataVersion {
+		t.Fatalf("expected migration to version %d but got version %d", walletDataVersion, wd.Version)
+	}
+	migrated, err = migrateWalletData(wd)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if migrated {
+		t.Fatal("expected no migration")
+	}
+	// Watching only wallets used to save an encrypted empty seed.
+	encEmptySeed, err := encryptDataWithKDF(nil, []byte("pass"), KDFScrypt)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	encSeed, err := encryptDataWithKDF([]byte("seed"), []byte("pass"), KDFScrypt)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	for _, test := range []struct {
+		encSeed  []byte
+		wantSeed bool
+	}{{encEmptySeed, false}, {encEmptySeed[envelopeHeaderSize:], false}, {encSeed, true}} {
+		wd := &walletData{Version: 1, EncryptedSeedHex: hex.EncodeToString(test.encSeed)}
+		if _, err := migrateWalletData(wd); err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		if hasSeed := wd.EncryptedSeedHex != ""; hasSeed != test.wantSeed {
+			t.Fatalf("expected seed %v but got %v", test.wantSeed, hasSeed)
+		}
+	}
+
+	wd.Version = walletDataVersion + 1
+	if _, err := migrateWalletData(wd); err == nil {
+		t.Fatal("expected error for newer version")
+	}
+}
+
+func TestDeleteWallet(t *testing.T) {
+	tests := []struct {
+		name           string
+		keepWalletData bool
+		open           bool
+		wantErr        error
+		wantFiles      []string
+	}{{
+		name:      "delete everything",
+		wantFiles: []string{"other"},
+	}, {
+		name:           "keep wallet data",
+		keepWalletData: true,
+		wantFiles:      []string{"other", walletDataFileName, walletDataBackupFileName},
+	}, {
+		name:      "wallet open",
+		open:      true,
+		wantErr:   ErrWalletOpen,
+		wantFiles: []string{"other", walletDbName, walletDataFileName, walletDataBackupFileName, peersFileName, "dcrwallet.log", "probe-simnet"},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, name := range []string{"other", walletDbName, walletDataFileName, walletDataBackupFileName, peersFileName, "dcrwallet.log"} {
+				if err := os.WriteFile(filepath.Join(dir, name), nil, 0600); err != nil {
+					t.Fatalf("unexpected error %v", err)
+				}
+			}
+			probeDir := probeCacheDir(dir, chaincfg.SimNetParams())
+			if err := checkCreateDir(probeDir); err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if err := os.WriteFile(filepath.Join(probeDir, walletDbName), nil, 0600); err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if test.open {
+				openDir, err := markWalletOpen(dir)
+				if err != nil {
+					t.Fatalf("unexpected error %v", err)
+				}
+				defer markWalletClosed(openDir)
+			}
+			err := DeleteWallet(dir, &DeleteWalletOpts{KeepWalletData: test.keepWalletData})
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("expected error %v but got %v", test.wantErr, err)
+			}
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if len(entries) != len(test.wantFiles) {
+				t.Fatalf("expected %d files but got %d", len(test.wantFiles), len(entries))
+			}
+			for _, name := range test.wantFiles {
+				if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+					t.Fatalf("expected %s to exist: %v", name, err)
+				}
+			}
+		})
+	}
+}
+
+func TestMarkWalletOpen(t *testing.T) {
+	dir := t.TempDir()
+	link := filepath.Join(t.TempDir(), "link")
+	if err := os.Symlink(dir, link); err != nil {
+		t.Skipf("unable to create symlink: %v", err)
+	}
+	t.Chdir(filepath.Dir(dir))
+
+	openDir, err := markWalletOpen(link)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	for _, path := range []string{dir, dir + string(filepath.Separator) + ".", filepath.Base(dir), link} {
+		if _, err := markWalletOpen(path); !errors.Is(err, ErrWalletOpen) {
+			t.Fatalf("expected %s to be open but got %v", path, err)
+		}
+		if err := DeleteWallet(path, nil); !errors.Is(err, ErrWalletOpen) {
+			t.Fatalf("expected deleting %s to fail with %v but got %v", path, ErrWalletOpen, err)
+		}
+	}
+	markWalletClosed(openDir)
+
+	openDir, err = markWalletOpen(filepath.Base(dir))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	markWalletClosed(openDir)
+}
+
+func TestRestoreBackup(t *testing.T) {
+	pass := []byte("pass")
+	files := map[string][]byte{
+		walletDbName:       []byte("db"),
+		walletDataFileName: []byte("{}"),
+		peersFileName:      []byte("[]"),
+	}
+	// large spans several chunks so that a chunk other than the first can
+	// be corrupted.
+	large := bytes.Repeat([]byte{1}, backupChunkSize*3)
+	makeBundle := func(net string, withLarge bool, tamper func([]*backupEntry)) []byte {
+		var entries []*backupEntry
+		for name, b := range files {
+			if withLarge && name == walletDbName {
+				b = large
+			}
+			entries = append(entries, newBackupEntry(name, b))
+		}
+		if tamper != nil {
+			tamper(entries)
+		}
+		var buf bytes.Buffer
+		if err := writeBackup(&buf, pass, net, entries); err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		return buf.Bytes()
+	}
+	bundle := makeBundle("mainnet", false, nil)
+	largeBundle := makeBundle("mainnet", true, nil)
+
+	corrupted := append([]byte{}, largeBundle...)
+	corrupted[len(corrupted)-backupChunkSize] ^= 0xff
+	badChecksum := makeBundle("mainnet", false, func(entries []*backupEntry) {
+		for _, e := range entries {
+			if e.file.Name == peersFileName {
+				e.file.SHA256Hex = hex.EncodeToString(make([]byte, sha256.Size))
+			}
+		}
+	})
+
+	tests := []struct {
+		name    string
+		bundle  []byte
+		net     string
+		pass    []byte
+		wantErr error
+		wantDb  []byte
+	}{{
+		name:   "ok",
+		bundle: bundle,
+		net:    "mainnet",
+		pass:   pass,
+		wantDb: files[walletDbName],
+	}, {
+		name:   "ok large",
+		bundle: largeBundle,
+		net:    "mainnet",
+		pass:   pass,
+		wantDb: large,
+	}, {
+		name:    "wrong pass",
+		bundle:  bundle,
+		net:     "mainnet",
+		pass:    []byte("wrong"),
+		wantErr: ErrInvalidPassphrase,
+	}, {
+		name:    "wrong network",
+		bundle:  bundle,
+		net:     "testnet",
+		pass:    pass,
+		wantErr: ErrInvalidBackup,
+	}, {
+		name:    "corrupted chunk",
+		bundle:  corrupted,
+		net:     "mainnet",
+		pass:    pass,
+		wantErr: ErrInvalidBackup,
+	}, {
+		name:    "truncated",
+		bundle:  largeBundle[:len(largeBundle)-10],
+		net:     "mainnet",
+		pass:    pass,
+		wantErr: ErrInvalidBackup,
+	}, {
+		name:    "checksum mismatch",
+		bundle:  badChecksum,
+		net:     "mainnet",
+		pass:    pass,
+		wantErr: ErrInvalidBackup,
+	}, {
+		name:    "unknown format",
+		bundle:  bundle[len(backupMagic):],
+		net:     "mainnet",
+		pass:    pass,
+		wantErr: ErrInvalidBackup,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := filepath.Join(t.TempDir(), "wallet")
+			err := RestoreBackup(bytes.NewReader(test.bundle), dir, test.net, test.pass)
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("expected error %v but got %v", test.wantErr, err)
+			}
+			if test.wantErr != nil {
+				// Nothing is left behind.
+				entries, err := os.ReadDir(dir)
+				if err != nil && !os.IsNotExist(err) {
+					t.Fatalf("unexpected error %v", err)
+				}
+				if len(entries) != 0 {
+					t.Fatalf("expected an empty data dir but found %d files", len(entries))
+				}
+				return
+			}
+			for name, want := range files {
+				if name == walletDbName {
+					want = test.wantDb
+				}
+				b, err := os.ReadFile(filepath.Join(dir, name))
+				if err != nil {
+					t.Fatalf("unexpected error %v", err)
+				}
+				if !bytes.Equal(b, want) {
+					t.Fatalf("expected %s to hold %d bytes but got %d", name, len(want), len(b))
+				}
+			}
+			// Restoring over an existing wallet is not allowed.
+			if err := RestoreBackup(bytes.NewReader(test.bundle), dir, test.net, test.pass); err == nil {
+				t.Fatal("expected error restoring over existing wallet")
+			}
+		})
+	}
+}
+
+func TestExportBackup(t *testing.T) {
+	ctx := context.Background()
+	pass := []byte("pass")
+	dir := t.TempDir()
+	w, err := CreateWallet(ctx, CreateWalletParams{
+		OpenWalletParams: OpenWalletParams{
+			Net:      "simnet",
+			DataDir:  dir,
+			DbDriver: "bdb",
+			Logger:   slog.Disabled,
+		},
+		Pass: pass,
+	}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer w.CloseWallet()
+	if err := os.WriteFile(filepath.Join(dir, "other"), []byte("other"), 0600); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	backupPath := filepath.Join(t.TempDir(), "wallet.backup")
+	if err := w.ExportBackupFile(backupPath, pass); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	// The database copy is removed.
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	for _, entry := range entries {
+		if strings.Contains(entry.Name(), ".tmp") {
+			t.Fatalf("unexpected file %s left in the data dir", entry.Name())
+		}
+	}
+
+	restore := func(net string) (string, error) {
+		f, err := os.Open(backupPath)
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		defer f.Close()
+		restoreDir := filepath.Join(t.TempDir(), "wallet")
+		return restoreDir, RestoreBackup(f, restoreDir, net, pass)
+	}
+	if _, err := restore("mainnet"); !errors.Is(err, ErrInvalidBackup) {
+		t.Fatalf("expected invalid backup error but got %v", err)
+	}
+	restoreDir, err := restore("simnet")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if b, err := os.ReadFile(filepath.Join(restoreDir, "other")); err != nil || string(b) != "other" {
+		t.Fatalf("other file not restored: %v", err)
+	}
+
+	restored, err := LoadWallet(ctx, OpenWalletParams{
+		Net:      "simnet",
+		DataDir:  restoreDir,
+		DbDriver: "bdb",
+		Logger:   slog.Disabled,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err := restored.OpenWallet(ctx); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer restored.CloseWallet()
+	acctKey, err := restored.mainWallet.AccountXpub(ctx, 0)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if acctKey.String() != w.metaData.DefaultAccountXPub || restored.metaData.DefaultAccountXPub != w.metaData.DefaultAccountXPub {
+		t.Fatal("restored wallet does not match the exported wallet")
+	}
+	if err := restored.Unlock(ctx, pass, nil); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestAccountTransactions(t *testing.T) {
+	res := func(txID, account string) wallettypes.ListTransactionsResult {
+		return wallettypes.ListTransactionsResult{TxID: txID, Account: account}
+	}
+	// Sorted from old to new. tx1 has two results in the default account.
+	all := []wallettypes.ListTransactionsResult{
+		res("tx1", "default"),
+		res("tx1", "default"),
+		res("tx2", "other"),
+		res("tx3", "default"),
+		res("tx4", "default"),
+	}
+
+	tests := []struct {
+		name        string
+		all         []wallettypes.ListTransactionsResult
+		account     string
+		from, count int
+		want        []string
+	}{{
+		name:    "no transactions",
+		account: "default",
+		count:   10,
+	}, {
+		name:    "no account transactions",
+		all:     all,
+		account: "savings",
+		count:   10,
+	}, {
+		name:    "all",
+		all:     all,
+		account: "default",
+		count:   3,
+		want:    []string{"tx1", "tx1", "tx3", "tx4"},
+	}, {
+		name:    "most recent",
+		all:     all,
+		account: "default",
+		count:   2,
+		want:    []string{"tx3", "tx4"},
+	}, {
+		name:    "skip most recent",
+		all:     all,
+		account: "default",
+		from:    1,
+		count:   1,
+		want:    []string{"tx3"},
+	}, {
+		name:    "count past the end",
+		all:     all,
+		account: "default",
+		from:    2,
+		count:   10,
+		want:    []string{"tx1", "tx1"},
+	}, {
+		name:    "from at the end",
+		all:     all,
+		account: "default",
+		from:    3,
+		count:   10,
+	}, {
+		name:    "from out of range",
+		all:     all,
+		account: "default",
+		from:    100,
+		count:   10,
+	}, {
+		name:    "zero count",
+		all:     all,
+		account: "default",
+	}, {
+		name:    "other account",
+		all:     all,
+		account: "other",
+		count:   10,
+		want:    []string{"tx2"},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			txs := accountTransactions(test.all, test.account, test.from, test.count)
+			if len(txs) != len(test.want) {
+				t.Fatalf("expected %d results but got %d", len(test.want), len(txs))
+			}
+			for i, tx := range txs {
+				if tx.TxID != test.want[i] || tx.Account != test.account {
+					t.Fatalf("expected %s in %s at %d but got %s in %s", test.want[i], test.account, i, tx.TxID, tx.Account)
+				}
+			}
+		})
+	}
+}
+
+func TestAccounts(t *testing.T) {
+	ctx := context.Background()
+	pass := []byte("pass")
+	w, err := CreateWallet(ctx, CreateWalletParams{
+		OpenWalletParams: OpenWalletParams{
+			Net:      "simnet",
+			DataDir:  t.TempDir(),
+			DbDriver: "bdb",
+			Logger:   slog.Disabled,
+		},
+		Pass: pass,
+	}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer w.CloseWallet()
+
+	if _, err := w.CreateAccount(ctx, "", pass); err == nil {
+		t.Fatal("expected an error creating an account without a name")
+	}
+	if _, err := w.CreateAccount(ctx, "savings", nil); err == nil {
+		t.Fatal("expected an error creating an account while locked")
+	}
+	acct, err := w.CreateAccount(ctx, "savings", pass)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if acct != 1 {
+		t.Fatalf("expected account 1 but got %d", acct)
+	}
+	if !w.LockState().Locked {
+		t.Fatal("expected the wallet to be locked after creating an account")
+	}
+	if _, err := w.CreateAccount(ctx, "savings", pass); err == nil {
+		t.Fatal("expected an error creating an account with a used name")
+	}
+
+	if err := w.RenameAccount(ctx, acct, ""); err == nil {
+		t.Fatal("expected an error renaming to an empty name")
+	}
+	if err := w.RenameAccount(ctx, udb.ImportedAddrAccount, "mine"); err == nil {
+		t.Fatal("expected an error renaming the imported account")
+	}
+	if err := w.RenameAccount(ctx, acct, "spending"); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	accts, err := w.ListAccounts(ctx, 1)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	names := make(map[uint32]string)
+	for _, a := range accts {
+		names[a.Number] = a.Name
+		if a.Balances.Total != 0 {
+			t.Fatalf("expected no balance in account %d", a.Number)
+		}
+	}
+	if names[udb.DefaultAccountNum] != "default" || names[acct] != "spending" || names[udb.ImportedAddrAccount] != "imported" {
+		t.Fatalf("unexpected accounts %v", names)
+	}
+
+	xpub, err := w.mainWallet.AccountXpub(ctx, acct)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	pubkey, err := w.AccountPubkey(ctx, "spending")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if pubkey != xpub.String() {
+		t.Fatalf("expected pubkey %s but got %s", xpub, pubkey)
+	}
+
+	unspents, err := w.AccountUnspents(ctx, acct, 0)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(unspents) != 0 {
+		t.Fatalf("expected no unspents but got %d", len(unspents))
+	}
+	txs, err := w.AccountTransactions(ctx, acct, 0, 10)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(txs) != 0 {
+		t.Fatalf("expected no transactions but got %d", len(txs))
+	}
+	if _, err := w.AccountTransactions(ctx, acct, -1, 10); err == nil {
+		t.Fatal("expected an error for a negative from")
+	}
+	if _, err := w.AccountTransactions(ctx, 100, 0, 10); err == nil {
+		t.Fatal("expected an error for an unknown account")
+	}
+}
+
+func TestSeedAccountKey(t *testing.T) {
+	chainParams := chaincfg.TestNet3Params()
+	seed := bytes.Repeat([]byte{1}, 16)
+	otherSeed := bytes.Repeat([]byte{2}, 16)
+
+	xpub := func(seed []byte, seedType SeedType, legacy bool) string {
+		t.Helper()
+		tweakedSeed, err := tweakSeed(seed, nil, seedType, mnemonic.English)
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		_, _, acctKeyLegacy, acctKeySLIP0044, err := udb.HDKeysFromSeed(tweakedSeed, chainParams)
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		if legacy {
+			return acctKeyLegacy.Neuter().String()
+		}
+		return acctKeySLIP0044.Neuter().String()
+	}
+
+	tests := []struct {
+		name     string
+		xpub     string
+		seed     []byte
+		seedType SeedType
+		wantErr  error
+	}{{
+		name: "ok",
+		xpub: xpub(seed, STFifteenWords, false),
+		seed: seed,
+	}, {
+		name: "ok legacy coin type",
+		xpub: xpub(seed, STFifteenWords, true),
+		seed: seed,
+	}, {
+		name:     "ok 12 words",
+		xpub:     xpub(seed, STTwelveWords, false),
+		seed:     seed,
+		seedType: STTwelveWords,
+	}, {
+		name:    "wrong seed",
+		xpub:    xpub(seed, STFifteenWords, false),
+		seed:    otherSeed,
+		wantErr: ErrSeedMismatch,
+	}, {
+		name:    "wrong seed type",
+		xpub:    xpub(seed, STTwelveWords, false),
+		seed:    seed,
+		wantErr: ErrSeedMismatch,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := &Wallet{chainParams: chainParams}
+			acctKey, err := w.seedAccountKey(test.seed, nil, test.seedType, mnemonic.English, test.xpub)
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("expected error %v but got %v", test.wantErr, err)
+			}
+			if err == nil && acctKey.Neuter().String() != test.xpub {
+				t.Fatal("returned key does not match the xpub")
+			}
+		})
+	}
+}
+
+func TestSignMessage(t *testing.T) {
+	ctx := context.Background()
+	const msg = "message"
+	pass := []byte("pass")
+	seed := bytes.Repeat([]byte{5}, 32)
+	params := chaincfg.SimNetParams()
+	_, _, _, acctKey, err := udb.HDKeysFromSeed(seed, params)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	_, _, _, otherAcctKey, err := udb.HDKeysFromSeed(bytes.Repeat([]byte{6}, 32), params)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	newParams := func() CreateWalletParams {
+		return CreateWalletParams{
+			OpenWalletParams: OpenWalletParams{
+				Net:      "simnet",
+				DataDir:  t.TempDir(),
+				DbDriver: "bdb",
+				Logger:   slog.Disabled,
+			},
+			Pass: pass,
+		}
+	}
+	checkSig := func(w *Wallet, addr stdaddr.Address, pass []byte) {
+		t.Helper()
+		sig, err := w.SignMessage(ctx, msg, addr, pass)
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		if ok, err := wallet.VerifyMessage(msg, addr, sig, params); err != nil || !ok {
+			t.Fatalf("expected a valid signature but got %v, %v", ok, err)
+		}
+	}
+	checkLocked := func(w *Wallet, want bool) {
+		t.Helper()
+		if locked := w.LockState().Locked; locked != want {
+			t.Fatalf("expected locked %v but got %v", want, locked)
+		}
+	}
+
+	t.Run("wallet", func(t *testing.T) {
+		w, err := CreateWallet(ctx, newParams(), nil)
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		defer w.CloseWallet()
+		addr, err := w.mainWallet.NewExternalAddress(ctx, 0)
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		if _, err := w.SignMessage(ctx, msg, addr, []byte("wrong")); err == nil {
+			t.Fatal("expected an error signing with the wrong pass")
+		}
+		// Signing with the pass locks the wallet again.
+		checkSig(w, addr, pass)
+		checkLocked(w, true)
+		// A session needs no pass and stays unlocked.
+		if err := w.UnlockFor(ctx, pass, 0); err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		checkSig(w, addr, nil)
+		checkLocked(w, false)
+	})
+
+	t.Run("upgraded watching only", func(t *testing.T) {
+		w, err := CreateWatchOnlyWallet(ctx, acctKey.Neuter().String(), newParams(), false)
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		defer w.CloseWallet()
+		addr, err := w.mainWallet.NewExternalAddress(ctx, 0)
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		if _, err := w.SignMessage(ctx, msg, addr, pass); err == nil {
+			t.Fatal("expected an error signing with a watching only wallet")
+		}
+		if err := w.UpgradeWatchOnly(ctx, seed, nil, STThirtyThreeWords, mnemonic.English, pass); err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		if _, err := w.SignMessage(ctx, msg, addr, nil); err == nil {
+			t.Fatal("expected an error signing while locked")
+		}
+		checkSig(w, addr, pass)
+		checkLocked(w, true)
+		if err := w.UnlockFor(ctx, pass, 0); err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		checkSig(w, addr, nil)
+
+		// Other accounts are not controlled by the seed.
+		if err := w.mainWallet.ImportXpubAccount(ctx, "other", otherAcctKey.Neuter()); err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		acct, err := w.mainWallet.AccountNumber(ctx, "other")
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		otherAddr, err := w.mainWallet.NewExternalAddress(ctx, acct)
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		if _, err := w.SignMessage(ctx, msg, otherAddr, nil); !errors.Is(err, errSeedSignerAccount) {
+			t.Fatalf("expected errSeedSignerAccount but got %v", err)
+		}
+		if _, err := w.CreateAccount(ctx, "new", nil); !errors.Is(err, errSeedSignerAccount) {
+			t.Fatalf("expected errSeedSignerAccount but got %v", err)
+		}
+	})
+}
+
+func TestVerifySeed(t *testing.T) {
+	const (
+		encSeedHex = "3bb9a36312986f1bcf50ba8be3e07d158be3222c9354d4c65cc6f57863c3589bdd2fccd9dbf06d082a1abafb1d63707964c1cb4c14e8843abdb1"
+		birthday   = 1740614400
+		words      = "peace option follow minute useful proud orphan zero truck response satisfy shell need chef silly"
+	)
+	pass := []byte("pass")
+	seed, _, err := dexmnemonic.DecodeMnemonic(words)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	earlierWords, err := dexmnemonic.GenerateMnemonic(seed, time.Unix(birthday, 0).AddDate(0, 0, -10))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	laterWords, err := dexmnemonic.GenerateMnemonic(seed, time.Unix(birthday, 0).AddDate(0, 0, 10))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	otherWords, err := dexmnemonic.GenerateMnemonic(bytes.Repeat([]byte{1}, 18), time.Unix(birthday, 0))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		words   string
+		pass    []byte
+		wantErr bool
+	}{{
+		name:  "ok",
+		words: words,
+		pass:  pass,
+	}, {
+		name:  "ok extra whitespace and case",
+		words: "  PEACE option follow minute useful proud orphan zero truck response satisfy shell need chef   silly ",
+		pass:  pass,
+	}, {
+		name:  "ok earlier birthday",
+		words: earlierWords,
+		pass:  pass,
+	}, {
+		name:    "later birthday",
+		words:   laterWords,
+		pass:    pass,
+		wantErr: true,
+	}, {
+		name:    "other seed",
+		words:   otherWords,
+		pass:    pass,
+		wantErr: true,
+	}, {
+		name:    "wrong pass",
+		words:   words,
+		pass:    []byte("wrong"),
+		wantErr: true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := &Wallet{
+				dir:      t.TempDir(),
+				log:      slog.Disabled,
+				metaData: &walletData{EncryptedSeedHex: encSeedHex, Birthday: birthday},
+			}
+			if err := w.DeleteStoredSeed(pass); err == nil {
+				t.Fatal("expected error deleting unverified seed")
+			}
+			err := w.VerifySeed(test.words, test.pass)
+			if test.wantErr {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				if w.SeedBackedUp() {
+					t.Fatal("seed should not be backed up")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if !w.SeedBackedUp() {
+				t.Fatal("seed should be backed up")
+			}
+			if err := w.DeleteStoredSeed([]byte("wrong")); err == nil {
+				t.Fatal("expected error deleting seed with wrong pass")
+			}
+			if err := w.DeleteStoredSeed(pass); err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if w.HasStoredSeed() {
+				t.Fatal("seed should be deleted")
+			}
+			for _, name := range []string{walletDataFileName, walletDataBackupFileName} {
+				b, err := os.ReadFile(filepath.Join(w.dir, name))
+				if err != nil {
+					t.Fatalf("unexpected error %v", err)
+				}
+				if bytes.Contains(b, []byte(encSeedHex)) {
+					t.Fatalf("%s still holds the seed", name)
+				}
+			}
+		})
+	}
+}
+
+func TestVerifySeedShares(t *testing.T) {
+	const fifteenWordsEncSeedHex = "3bb9a36312986f1bcf50ba8be3e07d158be3222c9354d4c65cc6f57863c3589bdd2fccd9dbf06d082a1abafb1d63707964c1cb4c14e8843abdb1"
+	pass := []byte("pass")
+	masterSeed := bytes.Repeat([]byte{7}, 32)
+	sharesEncSeedHex, _, err := encryptSeed(masterSeed, nil, pass)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	split := func(seed, sharesPass []byte) []string {
+		t.Helper()
+		group := slip39.Group{MemberThreshold: 2, MemberCount: 3}
+		shares, err := slip39.SplitSecret(1, []slip39.Group{group}, seed, sharesPass)
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		return shares[0]
+	}
+	userShares := split(masterSeed, []byte("sharespass"))
+	otherShares := split(bytes.Repeat([]byte{8}, 32), nil)
+	fifteenWords := &Wallet{
+		dir:      t.TempDir(),
+		log:      slog.Disabled,
+		metaData: &walletData{EncryptedSeedHex: fifteenWordsEncSeedHex},
+	}
+	fifteenWordsShares, err := fifteenWords.ExportSeedShares(pass, 2, 3)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		encSeedHex string
+		seedType   SeedType
+		shares     []string
+		sharesPass []byte
+		pass       []byte
+		wantErr    bool
+	}{{
+		name:       "ok recovered from shares",
+		encSeedHex: sharesEncSeedHex,
+		seedType:   STSeedShares,
+		shares:     userShares[1:],
+		sharesPass: []byte("sharespass"),
+		pass:       pass,
+	}, {
+		name:       "ok extra whitespace and case",
+		encSeedHex: sharesEncSeedHex,
+		seedType:   STSeedShares,
+		shares:     []string{" " + strings.ToUpper(userShares[0]) + " ", userShares[2]},
+		sharesPass: []byte("sharespass"),
+		pass:       pass,
+	}, {
+		name:       "ok exported from fifteen word seed",
+		encSeedHex: fifteenWordsEncSeedHex,
+		seedType:   STFifteenWords,
+		shares:     fifteenWordsShares[:2],
+		pass:       pass,
+	}, {
+		name:       "wrong shares pass",
+		encSeedHex: sharesEncSeedHex,
+		seedType:   STSeedShares,
+		shares:     userShares[1:],
+		pass:       pass,
+		wantErr:    true,
+	}, {
+		name:       "too few shares",
+		encSeedHex: sharesEncSeedHex,
+		seedType:   STSeedShares,
+		shares:     userShares[:1],
+		sharesPass: []byte("sharespass"),
+		pass:       pass,
+		wantErr:    true,
+	}, {
+		name:       "other seed",
+		encSeedHex: sharesEncSeedHex,
+		seedType:   STSeedShares,
+		shares:     otherShares[:2],
+		pass:       pass,
+		wantErr:    true,
+	}, {
+		name:       "wrong pass",
+		encSeedHex: sharesEncSeedHex,
+		seedType:   STSeedShares,
+		shares:     userShares[1:],
+		sharesPass: []byte("sharespass"),
+		pass:       []byte("wrong"),
+		wantErr:    true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := &Wallet{
+				dir:      t.TempDir(),
+				log:      slog.Disabled,
+				metaData: &walletData{EncryptedSeedHex: test.encSeedHex, SeedType: test.seedType},
+			}
+			err := w.VerifySeedShares(test.shares, test.sharesPass, test.pass)
+			if test.wantErr {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				if w.SeedBackedUp() {
+					t.Fatal("seed should not be backed up")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if !w.SeedBackedUp() {
+				t.Fatal("seed should be backed up")
+			}
+			if err := w.DeleteStoredSeed(pass); err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if w.HasStoredSeed() {
+				t.Fatal("seed should be deleted")
+			}
+		})
+	}
+}
+
+func TestExportSeedShares(t *testing.T) {
+	const encSeedHex = "3bb9a36312986f1bcf50ba8be3e07d158be3222c9354d4c65cc6f57863c3589bdd2fccd9dbf06d082a1abafb1d63707964c1cb4c14e8843abdb1"
+	pass := []byte("pass")
+	w := &Wallet{
+		dir:      t.TempDir(),
+		log:      slog.Disabled,
+		metaData: &walletData{EncryptedSeedHex: encSeedHex},
+	}
+	seed, err := w.storedSeed(pass)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	masterSeed, err := tweakSeed(seed, nil, STFifteenWords, mnemonic.English)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	tests := []struct {
+		name             string
+		pass             []byte
+		threshold, count int
+		wantErr          bool
+	}{{
+		name:      "ok 2 of 3",
+		pass:      pass,
+		threshold: 2,
+		count:     3,
+	}, {
+		name:      "ok 1 of 1",
+		pass:      pass,
+		threshold: 1,
+		count:     1,
+	}, {
+		name:      "wrong pass",
+		pass:      []byte("wrong"),
+		threshold: 2,
+		count:     3,
+		wantErr:   true,
+	}, {
+		name:      "zero threshold",
+		pass:      pass,
+		threshold: 0,
+		count:     3,
+		wantErr:   true,
+	}, {
+		name:      "threshold above count",
+		pass:      pass,
+		threshold: 4,
+		count:     3,
+		wantErr:   true,
+	}, {
+		name:      "multiple shares with threshold 1",
+		pass:      pass,
+		threshold: 1,
+		count:     3,
+		wantErr:   true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			shares, err := w.ExportSeedShares(test.pass, test.threshold, test.count)
+			if test.wantErr {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if len(shares) != test.count {
+				t.Fatalf("expected %d shares but got %d", test.count, len(shares))
+			}
+			got, err := slip39.CombineMnemonics(shares[len(shares)-test.threshold:], nil)
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			// The recovered seed must create the same wallet.
+			tweakedSeed, err := tweakSeed(got, nil, STSeedShares, mnemonic.English)
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if !bytes.Equal(tweakedSeed, masterSeed) {
+				t.Fatalf("expected master seed %x but got %x", masterSeed, tweakedSeed)
+			}
+		})
+	}
+
+	if _, err := tweakSeed(masterSeed, []byte("seedpass"), STSeedShares, mnemonic.English); err == nil {
+		t.Fatal("expected error for seed pass with seed shares")
+	}
+	if _, err := tweakSeed(masterSeed[:8], nil, STSeedShares, mnemonic.English); err == nil {
+		t.Fatal("expected error for short seed")
+	}
+}
+
+func TestGenerateSeed(t *testing.T) {
+	tests := []struct {
+		name     string
+		entropy  *UserEntropy
+		wantData string
+		wantBits float64
+		wantErr  bool
+	}{{
+		name: "no entropy",
+	}, {
+		name:     "dice",
+		entropy:  &UserEntropy{Type: EntropyDice, Data: "1 6, 3\n4 2 5"},
+		wantData: "163425",
+		wantBits: 15.5,
+	}, {
+		name:     "coins",
+		entropy:  &UserEntropy{Type: EntropyCoins, Data: "H t h 1 0"},
+		wantData: "10110",
+		wantBits: 5,
+	}, {
+		name:     "hex",
+		entropy:  &UserEntropy{Type: EntropyHex, Data: "DEAD beef"},
+		wantData: "deadbeef",
+		wantBits: 32,
+	}, {
+		name:    "bad die",
+		entropy: &UserEntropy{Type: EntropyDice, Data: "1270"},
+		wantErr: true,
+	}, {
+		name:    "odd hex",
+		entropy: &UserEntropy{Type: EntropyHex, Data: "abc"},
+		wantErr: true,
+	}, {
+		name:    "empty",
+		entropy: &UserEntropy{Type: EntropyCoins, Data: " , "},
+		wantErr: true,
+	}, {
+		name:    "unknown type",
+		entropy: &UserEntropy{Type: "cards", Data: "ace"},
+		wantErr: true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			seed, audit, err := generateSeed(entropyBytes, test.entropy)
+			if test.wantErr {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if len(seed) != entropyBytes {
+				t.Fatalf("expected %d byte seed but got %d", entropyBytes, len(seed))
+			}
+			if test.entropy == nil {
+				if audit != nil {
+					t.Fatal("expected no audit")
+				}
+				return
+			}
+			wantHash := sha256.Sum256([]byte(test.wantData))
+			if audit.UserSHA256Hex != hex.EncodeToString(wantHash[:]) {
+				t.Fatalf("wrong user entropy hash %s", audit.UserSHA256Hex)
+			}
+			if audit.UserSymbols != len(test.wantData) || audit.UserBits != test.wantBits ||
+				audit.SeedBytes != entropyBytes || audit.SystemBytes != sha256.Size {
+				t.Fatalf("unexpected audit %+v", audit)
+			}
+			// System randomness is always mixed in.
+			seed2, _, err := generateSeed(entropyBytes, test.entropy)
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if bytes.Equal(seed, seed2) {
+				t.Fatal("same seed generated twice from the same user entropy")
+			}
+		})
+	}
+}
+
+func TestThirtyThreeWordSeed(t *testing.T) {
+	seed := bytes.Repeat([]byte{7}, mnemonic.PGPSeedBytes)
+	pass := []byte("pass")
+	encSeedHex, _, err := encryptSeed(seed, nil, pass)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	w := &Wallet{
+		dir:      t.TempDir(),
+		log:      slog.Disabled,
+		metaData: &walletData{EncryptedSeedHex: encSeedHex, SeedType: STThirtyThreeWords},
+	}
+
+	words, err := w.DecryptSeed(pass)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if want := walletseed.EncodeMnemonic(seed); words != want {
+		t.Fatalf("expected mnemonic %q but got %q", want, words)
+	}
+	if err := w.VerifySeed(words, pass); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	// dcrwallet uses the seed without a tweak.
+	tweakedSeed, err := tweakSeed(seed, nil, STThirtyThreeWords, mnemonic.English)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !bytes.Equal(tweakedSeed, seed) {
+		t.Fatal("33 word seed should not be tweaked")
+	}
+	if _, err := tweakSeed(seed, []byte("seedpass"), STThirtyThreeWords, mnemonic.English); err == nil {
+		t.Fatal("expected error for seed pass")
+	}
+	if _, err := tweakSeed(seed[1:], nil, STThirtyThreeWords, mnemonic.English); err == nil {
+		t.Fatal("expected error for short seed")
+	}
+}
+
+func TestParseMnemonic(t *testing.T) {
+	tests := []struct {
+		name         string
+		words        string
+		wantSeedHex  string
+		wantSeedType SeedType
+		wantLang     mnemonic.Language
+		wantBirthday int64
+		wantErr      bool
+	}{{
+		name:         "ok 15",
+		words:        "peace option follow minute useful proud orphan zero truck response satisfy shell need chef silly",
+		wantSeedType: STFifteenWords,
+		wantBirthday: 1740614400,
+	}, {
+		name:         "ok 12",
+		words:        "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about",
+		wantSeedHex:  "00000000000000000000000000000000",
+		wantSeedType: STTwelveWords,
+	}, {
+		name:         "ok 24 upper case",
+		words:        "Zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo VOTE",
+		wantSeedHex:  "ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff",
+		wantSeedType: STTwentyFourWords,
+	}, {
+		name:         "ok 33",
+		words:        walletseed.EncodeMnemonic(make([]byte, 32)),
+		wantSeedHex:  "0000000000000000000000000000000000000000000000000000000000000000",
+		wantSeedType: STThirtyThreeWords,
+	}, {
+		name:    "bad checksum",
+		words:   "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon",
+		wantErr: true,
+	}, {
+		name:    "wrong word count",
+		words:   "abandon abandon abandon",
+		wantErr: true,
+	}, {
+		name:         "ok 12 spanish",
+		words:        "ábaco ábaco ábaco ábaco ábaco ábaco ábaco ábaco ábaco ábaco ábaco abierto",
+		wantSeedHex:  "00000000000000000000000000000000",
+		wantSeedType: STTwelveWords,
+		wantLang:     mnemonic.Spanish,
+	}, {
+		name:         "ok 12 japanese",
+		words:        "あいこくしん　あいこくしん　あいこくしん　あいこくしん　あいこくしん　あいこくしん　あいこくしん　あいこくしん　あいこくしん　あいこくしん　あいこくしん　あおぞら",
+		wantSeedHex:  "00000000000000000000000000000000",
+		wantSeedType: STTwelveWords,
+		wantLang:     mnemonic.Japanese,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			seed, seedType, lang, birthday, err := ParseMnemonic(test.words)
+			if test.wantErr {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if seedType != test.wantSeedType {
+				t.Fatalf("expected seed type %d but got %d", test.wantSeedType, seedType)
+			}
+			if lang != test.wantLang {
+				t.Fatalf("expected language %s but got %s", test.wantLang, lang)
+			}
+			if test.wantSeedHex != "" && hex.EncodeToString(seed) != test.wantSeedHex {
+				t.Fatalf("expected seed %s but got %x", test.wantSeedHex, seed)
+			}
+			if test.wantBirthday == 0 {
+				if birthday != nil {
+					t.Fatalf("expected no birthday but got %v", birthday)
+				}
+				return
+			}
+			if birthday == nil || birthday.Unix() != test.wantBirthday {
+				t.Fatalf("expected birthday %d but got %v", test.wantBirthday, birthday)
+			}
+		})
+	}
+}
+
+func TestNewBirthState(t *testing.T) {
+	params := chaincfg.MainNetParams()
+	genesis := params.GenesisBlock.Header.Timestamp
+	birthday := time.Unix(1700000000, 0)
+	height := uint32(800000)
+
+	tests := []struct {
+		name     string
+		birthday time.Time
+		height   *uint32
+		want     udb.BirthdayState
+	}{{
+		name:     "time",
+		birthday: birthday,
+		want:     udb.BirthdayState{Time: birthday.Add(-24 * time.Hour), SetFromTime: true},
+	}, {
+		name:     "height",
+		birthday: birthday,
+		height:   &height,
+		want:     udb.BirthdayState{Height: height, SetFromHeight: true},
+	}, {
+		name: "unknown birthday",
+		want: udb.BirthdayState{SetFromHeight: true},
+	}, {
+		name:     "epoch birthday",
+		birthday: time.Unix(0, 0),
+		want:     udb.BirthdayState{SetFromHeight: true},
+	}, {
+		name:     "genesis birthday",
+		birthday: genesis,
+		want:     udb.BirthdayState{Time: genesis.Add(-24 * time.Hour), SetFromTime: true},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			bs := newBirthState(test.birthday, test.height, params)
+			if bs.Height != test.want.Height || !bs.Time.Equal(test.want.Time) ||
+				bs.SetFromHeight != test.want.SetFromHeight || bs.SetFromTime != test.want.SetFromTime {
+				t.Fatalf("expected birth state %+v but got %+v", test.want, *bs)
+			}
+		})
+	}
+}
+
+func TestFirstMatch(t *testing.T) {
+	errMatch := errors.New("match error")
+	tests := []struct {
+		name    string
+		lo, hi  int32
+		matches []int32
+		err     error
+		want    int32
+	}{{
+		name: "no match",
+		hi:   100,
+		want: -1,
+	}, {
+		name:    "first height",
+		hi:      100,
+		matches: []int32{0, 50},
+		want:    0,
+	}, {
+		name:    "last height",
+		hi:      100,
+		matches: []int32{100},
+		want:    100,
+	}, {
+		name:    "earliest of several",
+		hi:      1000,
+		matches: []int32{999, 377, 378, 640},
+		want:    377,
+	}, {
+		name:    "before lo",
+		lo:      10,
+		hi:      20,
+		matches: []int32{5, 15},
+		want:    15,
+	}, {
+		name:    "single height",
+		lo:      7,
+		hi:      7,
+		matches: []int32{7},
+		want:    7,
+	}, {
+		name: "empty range",
+		lo:   8,
+		hi:   7,
+		want: -1,
+	}, {
+		name: "match error",
+		hi:   100,
+		err:  errMatch,
+		want: -1,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var matched int32
+			match := func(lo, hi int32) (bool, error) {
+				if test.err != nil {
+					return false, test.err
+				}
+				matched += hi - lo + 1
+				for _, h := range test.matches {
+					if h >= lo && h <= hi {
+						return true, nil
+					}
+				}
+				return false, nil
+			}
+			got, err := firstMatch(test.lo, test.hi, match)
+			if !errors.Is(err, test.err) {
+				t.Fatalf("expected error %v but got %v", test.err, err)
+			}
+			if got != test.want {
+				t.Fatalf("expected height %d but got %d", test.want, got)
+			}
+			// Each height is matched at most about twice.
+			if n := test.hi - test.lo + 1; n > 0 && matched > 2*n+1 {
+				t.Fatalf("expected at most %d heights matched but got %d", 2*n+1, matched)
+			}
+		})
+	}
+}
+
+func TestOpenProbeWallet(t *testing.T) {
+	ctx := context.Background()
+	params := chaincfg.SimNetParams()
+	dataDir := t.TempDir()
+	dir := probeCacheDir(dataDir, params)
+	if err := checkCreateDir(dir); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	openXpub := func() string {
+		t.Helper()
+		w, db, err := openProbeWallet(ctx, dir, params, slog.Disabled)
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		defer db.Close()
+		acctKey, err := w.AccountXpub(ctx, 0)
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		return acctKey.String()
+	}
+
+	xpub := openXpub()
+	if got := openXpub(); got != xpub {
+		t.Fatal("expected the cached probe wallet to be reused")
+	}
+	if err := os.WriteFile(filepath.Join(dir, walletDbName), []byte("corrupt"), 0600); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if got := openXpub(); got == xpub {
+		t.Fatal("expected a corrupt probe wallet to be replaced")
+	}
+
+	if err := RemoveSeedProbeCache(dataDir, "simnet"); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Fatalf("expected the probe cache to be removed but got %v", err)
+	}
+}
+
+func TestProbeTargets(t *testing.T) {
+	params := chaincfg.MainNetParams()
+	seed := bytes.Repeat([]byte{3}, 32)
+	results, addrs, scripts, err := probeTargets(seed, 2, 3, params)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(results) != 2 || results[0].CoinType != params.SLIP0044CoinType || results[1].CoinType != params.LegacyCoinType {
+		t.Fatalf("expected slip0044 and legacy coin type results but got %s", spew.Sdump(results))
+	}
+	if len(addrs) != 10 || len(scripts) != 10 {
+		t.Fatalf("expected 10 addresses and scripts but got %d and %d", len(addrs), len(scripts))
+	}
+
+	// The addresses match the wallet's account addresses.
+	_, _, acctKeyLegacy, acctKeySLIP0044, err := udb.HDKeysFromSeed(seed, params)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	for i, test := range []struct {
+		acctKey *hdkeychain.ExtendedKey
+		path    string
+		res     *SeedProbeResult
+	}{
+		{acctKeySLIP0044, "0/1", results[0]},
+		{acctKeySLIP0044, "1/2", results[0]},
+		{acctKeyLegacy, "0/0", results[1]},
+		{acctKeyLegacy, "1/0", results[1]},
+	} {
+		want, err := AddrFromExtendedKey(test.acctKey.Neuter().String(), test.path, "p2pkh", false)
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		var found bool
+		for _, addr := range addrs {
+			if addr.String() != want {
+				continue
+			}
+			found = true
+			_, script := addr.PaymentScript()
+			if scripts[string(script)] != test.res {
+				t.Fatalf("%d: address %s has the wrong coin type", i, want)
+			}
+		}
+		if !found {
+			t.Fatalf("%d: address %s not probed", i, want)
+		}
+	}
+}
+
+func TestEstimateBirthdayRecovery(t *testing.T) {
+	tests := []struct {
+		name     string
+		seedType SeedType
+		seedLen  int
+		wantErr  bool
+	}{{
+		name:     "twelve words",
+		seedType: STTwelveWords,
+		seedLen:  16,
+	}, {
+		name:     "fifteen words",
+		seedType: STFifteenWords,
+		seedLen:  16,
+		wantErr:  true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			params := CreateWalletParams{
+				OpenWalletParams: OpenWalletParams{
+					Net:      "simnet",
+					DataDir:  t.TempDir(),
+					DbDriver: "bdb",
+					Logger:   slog.Disabled,
+				},
+				Pass: []byte("pass"),
+			}
+			recovery := &RecoveryCfg{
+				Seed:             bytes.Repeat([]byte{5}, test.seedLen),
+				SeedType:         test.seedType,
+				Birthday:         time.Unix(1600000000, 0),
+				EstimateBirthday: true,
+			}
+			w, err := CreateWallet(context.Background(), params, recovery)
+			if test.wantErr {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			defer w.CloseWallet()
+
+			if !w.metaData.EstimateBirthday {
+				t.Fatal("expected the birthday estimate to be pending")
+			}
+			// The first sync skips the chain until the estimate.
+			bs, err := w.mainWallet.BirthState(context.Background())
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if !bs.SetFromTime || bs.Time.Before(time.Now().Add(-25*time.Hour)) {
+				t.Fatalf("expected a recent birthday but got %+v", bs)
+			}
+		})
+	}
+}
+
+func TestCreateWalletLanguage(t *testing.T) {
+	ctx := context.Background()
+	pass, seedPass := []byte("pass"), []byte("seedpass")
+	seed := bytes.Repeat([]byte{9}, 16)
+	spanishWords, err := mnemonic.GenerateMnemonic(seed, mnemonic.WithLanguage(mnemonic.Spanish))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	englishWords, err := mnemonic.GenerateMnemonic(seed)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	// BIP0039 applies the seed pass to the words in their language.
+	tweakedSeed, err := tweakSeed(seed, seedPass, STTwelveWords, mnemonic.Spanish)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !bytes.Equal(tweakedSeed, mnemonic.ApplyPassphrase(seed, seedPass, spanishWords)) {
+		t.Fatal("seed pass not applied to the spanish words")
+	}
+	_, _, acctKeyLegacy, _, err := udb.HDKeysFromSeed(tweakedSeed, chaincfg.SimNetParams())
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	openParams := OpenWalletParams{
+		Net:      "simnet",
+		DbDriver: "bdb",
+		Logger:   slog.Disabled,
+	}
+
+	t.Run("recovered", func(t *testing.T) {
+		openParams := openParams
+		openParams.DataDir = t.TempDir()
+		parsedSeed, seedType, lang, _, err := ParseMnemonic(spanishWords)
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		w, err := CreateWallet(ctx, CreateWalletParams{OpenWalletParams: openParams, Pass: pass}, &RecoveryCfg{
+			Seed:     parsedSeed,
+			SeedPass: seedPass,
+			SeedType: seedType,
+			Language: lang,
+		})
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		defer w.CloseWallet()
+		if w.metaData.DefaultAccountXPub != acctKeyLegacy.Neuter().String() {
+			t.Fatal("recovered wallet does not match the spanish mnemonic")
+		}
+		words, err := w.DecryptSeed(pass)
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		if words != spanishWords {
+			t.Fatalf("expected %q but got %q", spanishWords, words)
+		}
+		if err := w.VerifySeed(englishWords, pass); !errors.Is(err, ErrMnemonicMismatch) {
+			t.Fatalf("expected ErrMnemonicMismatch but got %v", err)
+		}
+		if err := w.VerifySeed(spanishWords, pass); err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+	})
+
+	t.Run("new", func(t *testing.T) {
+		openParams := openParams
+		openParams.DataDir = t.TempDir()
+		lang := mnemonic.Japanese
+		w, err := CreateWallet(ctx, CreateWalletParams{OpenWalletParams: openParams, Pass: pass, Language: &lang}, nil)
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		defer w.CloseWallet()
+		words, err := w.DecryptSeed(pass)
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		_, seedType, gotLang, _, err := ParseMnemonic(words)
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		if seedType != STTwentyFourWords || gotLang != mnemonic.Japanese {
+			t.Fatalf("expected a japanese 24 word mnemonic but got seed type %d in %s", seedType, gotLang)
+		}
+		if err := w.VerifySeed(words, pass); err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+	})
+}
+
+func TestCreateWalletCoinType(t *testing.T) {
+	params := chaincfg.SimNetParams()
+	seed := bytes.Repeat([]byte{9}, 16)
+	tweakedSeed, err := tweakSeed(seed, nil, STTwelveWords, mnemonic.English)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	_, _, acctKeyLegacy, acctKeySLIP0044, err := udb.HDKeysFromSeed(tweakedSeed, params)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	legacyXPub, slip0044XPub := acctKeyLegacy.Neuter().String(), acctKeySLIP0044.Neuter().String()
+
+	tests := []struct {
+		name         string
+		recovery     *RecoveryCfg
+		wantCoinType uint32
+		wantXPub     string
+	}{{
+		name:         "new wallet",
+		wantCoinType: params.SLIP0044CoinType,
+	}, {
+		name:         "recovered wallet",
+		recovery:     &RecoveryCfg{Seed: seed, SeedType: STTwelveWords},
+		wantCoinType: params.LegacyCoinType,
+		wantXPub:     legacyXPub,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.Background()
+			w, err := CreateWallet(ctx, CreateWalletParams{
+				OpenWalletParams: OpenWalletParams{
+					Net:      "simnet",
+					DataDir:  t.TempDir(),
+					DbDriver: "bdb",
+					Logger:   slog.Disabled,
+				},
+				Pass: []byte("pass"),
+			}, test.recovery)
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			defer w.CloseWallet()
+
+			if w.metaData.CoinType != test.wantCoinType {
+				t.Fatalf("expected coin type %d but got %d", test.wantCoinType, w.metaData.CoinType)
+			}
+			acctKey, err := w.mainWallet.AccountXpub(ctx, 0)
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if w.metaData.DefaultAccountXPub != acctKey.String() {
+				t.Fatal("default account xpub does not match the wallet's coin type")
+			}
+			if test.wantXPub != "" && w.metaData.DefaultAccountXPub != test.wantXPub {
+				t.Fatalf("expected xpub %s but got %s", test.wantXPub, w.metaData.DefaultAccountXPub)
+			}
+
+			// Addresses are derived from the wallet's coin type.
+			_, unused, _, err := w.DefaultAccountAddresses(ctx, 0, 1)
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			want, err := AddrFromExtendedKey(acctKey.String(), "0/0", "p2pkh", false)
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if len(unused) != 1 || unused[0] != want {
+				t.Fatalf("expected address %s but got %v", want, unused)
+			}
+
+			if test.recovery == nil {
+				return
+			}
+			// An upgrade during sync is recorded.
+			if err := w.mainWallet.UpgradeToSLIP0044CoinType(ctx); err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if err := w.updateCoinType(ctx); err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if w.metaData.CoinType != params.SLIP0044CoinType || w.metaData.DefaultAccountXPub != slip0044XPub {
+				t.Fatal("coin type upgrade not recorded")
+			}
+			wd, err := retrieveWalletData(w.dir, slog.Disabled)
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if wd.CoinType != params.SLIP0044CoinType {
+				t.Fatalf("expected saved coin type %d but got %d", params.SLIP0044CoinType, wd.CoinType)
+			}
+		})
+	}
+}
+
+func TestAdoptWallet(t *testing.T) {
+	ctx := context.Background()
+	params := chaincfg.SimNetParams()
+	seed := bytes.Repeat([]byte{7}, 32)
+	// New dcrwallet wallets use the legacy coin type until synced.
+	_, _, acctKeyLegacy, _, err := udb.HDKeysFromSeed(seed, params)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		dbPubPass  string
+		pubPass    string
+		wantErr    bool
+		retryAfter string
+	}{{
+		name:      "default public passphrase",
+		dbPubPass: wallet.InsecurePubPassphrase,
+	}, {
+		name:      "custom public passphrase",
+		dbPubPass: "custom",
+		pubPass:   "custom",
+	}, {
+		name:       "missing public passphrase",
+		dbPubPass:  "custom",
+		wantErr:    true,
+		retryAfter: "custom",
+	}, {
+		name:       "wrong public passphrase",
+		dbPubPass:  "custom",
+		pubPass:    "wrong",
+		wantErr:    true,
+		retryAfter: "custom",
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+
+			// Create a wallet like dcrwallet does, without wallet data.
+			db, err := wallet.CreateDB("bdb", filepath.Join(dir, walletDbName))
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			err = wallet.Create(ctx, db, []byte(test.dbPubPass), []byte("pass"), seed, params)
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if err := db.Close(); err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+
+			openParams := OpenWalletParams{
+				Net:      "simnet",
+				DataDir:  dir,
+				DbDriver: "bdb",
+				Logger:   slog.Disabled,
+			}
+			if _, err := LoadWallet(ctx, openParams); !errors.Is(err, ErrWalletDataNotFound) {
+				t.Fatalf("expected wallet data not found error but got %v", err)
+			}
+
+			adoptParams := AdoptWalletParams{
+				OpenWalletParams: openParams,
+				PubPass:          []byte(test.pubPass),
+			}
+			w, err := AdoptWallet(ctx, adoptParams)
+			if test.wantErr {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				// The database is unchanged and no wallet data is
+				// left, so adopting can be retried.
+				if _, err := retrieveWalletData(dir, slog.Disabled); !errors.Is(err, ErrWalletDataNotFound) {
+					t.Fatalf("expected wallet data not found error but got %v", err)
+				}
+				adoptParams.PubPass = []byte(test.retryAfter)
+				w, err = AdoptWallet(ctx, adoptParams)
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if w.metaData.DefaultAccountXPub != acctKeyLegacy.Neuter().String() {
+				t.Fatalf("unexpected default account xpub %s", w.metaData.DefaultAccountXPub)
+			}
+			if w.metaData.CoinType != params.LegacyCoinType {
+				t.Fatalf("expected coin type %d but got %d", params.LegacyCoinType, w.metaData.CoinType)
+			}
+			if w.HasStoredSeed() {
+				t.Fatal("adopted wallet should not have a stored seed")
+			}
+			if _, _, _, err := w.DefaultAccountAddresses(ctx, 0, 1); err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if err := w.CloseWallet(); err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+
+			// The wallet now loads without the public passphrase.
+			w, err = LoadWallet(ctx, openParams)
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if err := w.OpenWallet(ctx); err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if err := w.CloseWallet(); err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+
+			if _, err := AdoptWallet(ctx, adoptParams); err == nil {
+				t.Fatal("expected an error adopting a wallet with wallet data")
+			}
+		})
+	}
+}
+
+func TestUnlockSessions(t *testing.T) {
+	ctx := context.Background()
+	pass := []byte("pass")
+	w, err := CreateWallet(ctx, CreateWalletParams{
+		OpenWalletParams: OpenWalletParams{
+			Net:      "simnet",
+			DataDir:  t.TempDir(),
+			DbDriver: "bdb",
+			Logger:   slog.Disabled,
+		},
+		Pass: pass,
+	}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer w.CloseWallet()
+
+	checkState := func(wantLocked, wantSession bool) {
+		t.Helper()
+		state := w.LockState()
+		if state.Locked != wantLocked || state.Session != wantSession {
+			t.Fatalf("expected locked %v and session %v but got %+v", wantLocked, wantSession, state)
+		}
+	}
+	checkState(true, false)
+
+	// Signing without a session relocks.
+	done, err := w.UnlockForSigning(ctx, pass)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	checkState(false, false)
+	done()
+	checkState(true, false)
+
+	if _, err := w.UnlockForSigning(ctx, []byte("wrong")); err == nil {
+		t.Fatal("expected an error with a wrong passphrase")
+	}
+	if _, err := w.UnlockForSigning(ctx, nil); err == nil {
+		t.Fatal("expected an error without a passphrase or session")
+	}
+	if err := w.UnlockFor(ctx, pass, -time.Second); err == nil {
+		t.Fatal("expected an error with a negative duration")
+	}
+
+	// Signing during a session does not relock.
+	if err := w.UnlockFor(ctx, pass, 0); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	checkState(false, true)
+	if !w.LockState().Until.IsZero() {
+		t.Fatal("expected a session without timeout")
+	}
+	done, err = w.UnlockForSigning(ctx, nil)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	done()
+	checkState(false, true)
+	w.Lock()
+	checkState(true, false)
+
+	// A session that expires while signing relocks when signing is done.
+	if err := w.UnlockFor(ctx, pass, 50*time.Millisecond); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if w.LockState().Until.IsZero() {
+		t.Fatal("expected a session end time")
+	}
+	done, err = w.UnlockForSigning(ctx, nil)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	checkState(false, false)
+	done()
+	checkState(true, false)
+
+	// The session locks when it expires.
+	if err := w.UnlockFor(ctx, pass, 50*time.Millisecond); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	checkState(true, false)
+}
+
+func TestLockUnopenedWallet(t *testing.T) {
+	ctx := context.Background()
+	pass := []byte("pass")
+	params := OpenWalletParams{
+		Net:      "simnet",
+		DataDir:  t.TempDir(),
+		DbDriver: "bdb",
+		Logger:   slog.Disabled,
+	}
+	w, err := CreateWallet(ctx, CreateWalletParams{OpenWalletParams: params, Pass: pass}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err := w.CloseWallet(); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	w, err = LoadWallet(ctx, params)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	w.Lock()
+	if !w.LockState().Locked {
+		t.Fatal("expected a wallet that is not open to be locked")
+	}
+	if err := w.UnlockFor(ctx, pass, 0); err == nil {
+		t.Fatal("expected an error unlocking a wallet that is not open")
+	}
+	if _, err := w.UnlockForSigning(ctx, pass); err == nil {
+		t.Fatal("expected an error unlocking a wallet that is not open")
+	}
+}
+
+func TestSyncPercent(t *testing.T) {
+	tests := []struct {
+		name           string
+		stage          SyncStage
+		height, target int32
+		want           float64
+	}{{
+		name: "not started",
+		want: 0,
+	}, {
+		name:   "half of cfilters",
+		stage:  SyncStageFetchingCFilters,
+		height: 50,
+		target: 100,
+		want:   5,
+	}, {
+		name:   "half of headers",
+		stage:  SyncStageFetchingHeaders,
+		height: 50,
+		target: 100,
+		want:   35,
+	}, {
+		name:   "height past target",
+		stage:  SyncStageFetchingHeaders,
+		height: 150,
+		target: 100,
+		want:   60,
+	}, {
+		name:  "unknown target",
+		stage: SyncStageDiscoveringAddrs,
+		want:  60,
+	}, {
+		name:   "rescan",
+		stage:  SyncStageRescanning,
+		height: 90,
+		target: 100,
+		want:   97,
+	}, {
+		name:  "complete",
+		stage: SyncStageComplete,
+		want:  100,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := syncPercent(test.stage, test.height, test.target); got != test.want {
+				t.Fatalf("expected %v but got %v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestSyncProgress(t *testing.T) {
+	w := &Wallet{log: slog.Disabled}
+	now := time.Now()
+	oldSyncer, syncer := new(spv.Syncer), new(spv.Syncer)
+
+	w.progress.begin(oldSyncer)
+	oldNtfns := w.progressNotifications(oldSyncer, nil)
+	oldNtfns.PeerConnected(1, "old")
+	w.progress.end(errors.New("sync error"))
+
+	var userHeight int32
+	w.progress.begin(syncer)
+	ntfns := w.progressNotifications(syncer, &spv.Notifications{
+		FetchHeadersProgress: func(height int32, _ int64) { userHeight = height },
+	})
+	ntfns.PeerConnected(2, "new")
+	ntfns.FetchHeadersStarted()
+	ntfns.FetchHeadersProgress(40, 0)
+	if userHeight != 40 {
+		t.Fatal("notification was not passed on")
+	}
+	// Late notifications of the old syncer are ignored.
+	oldNtfns.PeerDisconnected(0, "old")
+	oldNtfns.Synced(true)
+
+	sp := w.progress.snapshot(false, 100, 40, now)
+	if sp.Stage != SyncStageFetchingHeaders || sp.HeadersHeight != 40 || sp.NumPeers != 2 {
+		t.Fatalf("unexpected progress %+v", sp)
+	}
+	if sp.Percent != 30 || sp.ETA != 0 {
+		t.Fatalf("unexpected percent %v and ETA %v", sp.Percent, sp.ETA)
+	}
+	if sp.LastErr == nil {
+		t.Fatal("expected the error that ended the last sync")
+	}
+
+	// Progress from 30 to 35 percent in a minute leaves 13 minutes.
+	ntfns.FetchHeadersProgress(50, 0)
+	sp = w.progress.snapshot(false, 100, 50, now.Add(time.Minute))
+	if sp.Percent != 35 || sp.ETA != 13*time.Minute {
+		t.Fatalf("unexpected percent %v and ETA %v", sp.Percent, sp.ETA)
+	}
+
+	// A rescan outside of sync takes precedence over sync stages.
+	if err := w.progress.startRescan(50); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err := w.progress.startRescan(50); err == nil {
+		t.Fatal("expected an error starting a second rescan")
+	}
+	ntfns.DiscoverAddressesStarted()
+	w.progress.rescanned(75)
+	sp = w.progress.snapshot(true, 100, 100, now)
+	if sp.Stage != SyncStageRescanning || sp.RescanHeight != 75 || sp.Percent != 50 {
+		t.Fatalf("unexpected progress %+v", sp)
+	}
+	w.progress.endRescan(nil)
+	sp = w.progress.snapshot(false, 100, 100, now)
+	if sp.Stage != SyncStageDiscoveringAddrs {
+		t.Fatalf("expected stage %v but got %v", SyncStageDiscoveringAddrs, sp.Stage)
+	}
+
+	// A missed synced notification is corrected by the syncer state.
+	sp = w.progress.snapshot(true, 100, 100, now)
+	if sp.Stage != SyncStageComplete || sp.Percent != 100 || sp.LastErr != nil {
+		t.Fatalf("unexpected progress %+v", sp)
+	}
+	ntfns.Synced(false)
+	if sp = w.progress.snapshot(false, 100, 100, now); sp.Stage != SyncStageNotStarted {
+		t.Fatalf("expected stage %v but got %v", SyncStageNotStarted, sp.Stage)
+	}
+
+	// The error that ended a rescan is reported and kept while synced.
+	ntfns.Synced(true)
+	rescanErr := errors.New("rescan error")
+	if err := w.progress.startRescan(50); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if _, err := w.StartRescan(context.Background(), 0); err == nil {
+		t.Fatal("expected an error starting a rescan while rescanning")
+	}
+	w.progress.endRescan(rescanErr)
+	for range 2 {
+		sp = w.progress.snapshot(true, 100, 100, now)
+		if sp.Stage != SyncStageComplete || !errors.Is(sp.LastErr, rescanErr) {
+			t.Fatalf("unexpected progress %+v", sp)
+		}
+	}
+}
+
+func TestEventQueue(t *testing.T) {
+	ctx := context.Background()
+	q := newEventQueue(3)
+	if events := q.next(ctx, 0, 0); events != nil {
+		t.Fatalf("expected no events but got %d", len(events))
+	}
+
+	// A waiting reader gets the first pushed event.
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		q.push(&Event{Type: EventBalance})
+	}()
+	events := q.next(ctx, 0, time.Second)
+	if len(events) != 1 || events[0].Type != EventBalance || events[0].Time == 0 {
+		t.Fatalf("unexpected events %+v", events)
+	}
+
+	// The oldest events are dropped when full.
+	for i := int32(0); i < 5; i++ {
+		q.push(&Event{Type: EventRescanProgress, Rescan: &RescanEvent{Height: i}})
+	}
+	events = q.next(ctx, 2, 0)
+	if len(events) != 2 || events[0].Type != EventDropped || events[0].Dropped != 2 {
+		t.Fatalf("unexpected events %+v", events)
+	}
+	if events[1].Rescan.Height != 2 {
+		t.Fatalf("expected height 2 but got %d", events[1].Rescan.Height)
+	}
+	events = q.next(ctx, 0, 0)
+	if len(events) != 2 || events[0].Rescan.Height != 3 || events[1].Rescan.Height != 4 {
+		t.Fatalf("unexpected events %+v", events)
+	}
+
+	// Stage changes are queued once.
+	p := &syncProgress{events: q}
+	p.mtx.Lock()
+	p.moveTo(SyncStageFetchingHeaders)
+	p.moveTo(SyncStageFetchingHeaders)
+	p.mtx.Unlock()
+	events = q.next(ctx, 0, 0)
+	if len(events) != 1 || events[0].SyncStage.Stage != SyncStageFetchingHeaders {
+		t.Fatalf("unexpected events %+v", events)
+	}
+}
+
+func TestQueueTxNotifications(t *testing.T) {
+	w := &Wallet{log: slog.Disabled, events: newEventQueue(defaultEventQueueSize)}
+	header := &wire.BlockHeader{Height: 10}
+	ticketHash := chainhash.Hash{1}
+	vote := wire.NewMsgTx()
+	vote.AddTxIn(wire.NewTxIn(&wire.OutPoint{}, 0, nil))
+	vote.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&ticketHash, 0, 0), 0, nil))
+	voteBytes, err := vote.Bytes()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	voteHash, purchaseHash := vote.TxHash(), chainhash.Hash{2}
+
+	w.queueTxNotifications(context.Background(), &wallet.TransactionNotifications{
+		AttachedBlocks: []wallet.Block{{
+			Header: header,
+			Transactions: []wallet.TransactionSummary{{
+				Hash:        &voteHash,
+				Transaction: voteBytes,
+				Type:        wallet.TransactionTypeVote,
+			}},
+		}},
+		UnminedTransactions: []wallet.TransactionSummary{{
+			Hash: &purchaseHash,
+			Type: wallet.TransactionTypeTicketPurchase,
+		}},
+		NewBalances: []wallet.AccountBalance{{Account: 0, TotalBalance: 5}},
+	})
+
+	events := w.NextEvents(context.Background(), 0, 0)
+	wantTypes := []EventType{EventBlockConnected, EventTransaction, EventTicket,
+		EventTransaction, EventTicket, EventBalance}
+	if len(events) != len(wantTypes) {
+		t.Fatalf("expected %d events but got %d", len(wantTypes), len(events))
+	}
+	for i, e := range events {
+		if e.Type != wantTypes[i] {
+			t.Fatalf("expected event %d to be %s but got %s", i, wantTypes[i], e.Type)
+		}
+	}
+	if events[0].Block.Height != 10 || events[1].Tx.BlockHeight != 10 || events[1].Tx.Type != "vote" {
+		t.Fatalf("unexpected block or transaction event %+v %+v", events[0].Block, events[1].Tx)
+	}
+	if ticket := events[2].Ticket; ticket.Hash != ticketHash.String() || ticket.Status != TicketStatusVoted {
+		t.Fatalf("unexpected vote ticket event %+v", ticket)
+	}
+	if events[3].Tx.BlockHeight != -1 {
+		t.Fatalf("expected an unmined transaction but got height %d", events[3].Tx.BlockHeight)
+	}
+	if ticket := events[4].Ticket; ticket.Hash != purchaseHash.String() || ticket.Status != TicketStatusUnmined {
+		t.Fatalf("unexpected purchase ticket event %+v", ticket)
+	}
+	if events[5].Balance.Total != 5 {
+		t.Fatalf("expected balance 5 but got %d", events[5].Balance.Total)
+	}
+}
+
+func TestTicketStatus(t *testing.T) {
+	params := chaincfg.SimNetParams()
+	maturity, expiry := int32(params.TicketMaturity), int32(params.TicketExpiry)
+	tests := []struct {
+		name             string
+		revocationHeight int32
+		want             string
+	}{{
+		name:             "missed at maturity",
+		revocationHeight: 100 + maturity + 1,
+		want:             TicketStatusMissed,
+	}, {
+		name:             "missed before expiry",
+		revocationHeight: 100 + maturity + expiry - 1,
+		want:             TicketStatusMissed,
+	}, {
+		name:             "expired",
+		revocationHeight: 100 + maturity + expiry,
+		want:             TicketStatusExpired,
+	}}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := revocationStatus(params, 100, test.revocationHeight); got != test.want {
+				t.Fatalf("expected %s but got %s", test.want, got)
+			}
+		})
+	}
+
+	if got, want := liveTicketsHeight(params, 100+maturity+1), int32(100); got != want {
+		t.Fatalf("expected tickets mined at %d to become live but got %d", want, got)
+	}
+}
+
+func TestTxNotificationsOpenWallet(t *testing.T) {
+	ctx := context.Background()
+	openParams := OpenWalletParams{
+		Net:      "simnet",
+		DataDir:  t.TempDir(),
+		DbDriver: "bdb",
+		Logger:   slog.Disabled,
+	}
+	w, err := CreateWallet(ctx, CreateWalletParams{
+		OpenWalletParams: openParams,
+		Pass:             []byte("pass"),
+	}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	watching := func() bool {
+		w.ntfnsMtx.Lock()
+		defer w.ntfnsMtx.Unlock()
+		return w.stopNtfns != nil
+	}
+	if !watching() {
+		t.Fatal("expected a created wallet to watch notifications")
+	}
+	if err := w.CloseWallet(); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if watching() {
+		t.Fatal("expected a closed wallet to stop watching notifications")
+	}
+
+	w, err = LoadWallet(ctx, openParams)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err := w.OpenWallet(ctx); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer w.CloseWallet()
+	if !watching() {
+		t.Fatal("expected an opened wallet to watch notifications without syncing")
+	}
+}
+
+func TestSyncRetryDelay(t *testing.T) {
+	const minDelay, maxDelay = time.Second * 5, time.Minute
+	tests := []struct {
+		name     string
+		failures int
+		want     time.Duration
+	}{{
+		name:     "first failure",
+		failures: 1,
+		want:     minDelay,
+	}, {
+		name:     "third failure",
+		failures: 3,
+		want:     minDelay * 4,
+	}, {
+		name:     "capped",
+		failures: 5,
+		want:     maxDelay,
+	}, {
+		name:     "many failures",
+		failures: 1000,
+		want:     maxDelay,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for i := 0; i < 100; i++ {
+				delay := syncRetryDelay(test.failures, minDelay, maxDelay)
+				if low := max(test.want/2, minDelay); delay < low || delay > test.want {
+					t.Fatalf("expected a delay from %v to %v but got %v", low, test.want, delay)
+				}
+			}
+		})
+	}
+
+	// The longest delays allowed by the config do not overflow.
+	longest := time.Duration(maxSyncRetryDelaySecs) * time.Second
+	for _, failures := range []int{1, 40, 1000} {
+		if delay := syncRetryDelay(failures, longest, longest); delay != longest {
+			t.Fatalf("expected a delay of %v but got %v", longest, delay)
+		}
+	}
+
+	// Failures are counted until synced.
+	var p syncProgress
+	p.begin(nil)
+	p.end(errors.New("first"))
+	if failures := p.end(errors.New("second")); failures != 2 {
+		t.Fatalf("expected 2 failures but got %d", failures)
+	}
+	if sp := p.snapshot(false, 0, 0, time.Now()); sp.Failures != 2 || sp.LastErr.Error() != "second" {
+		t.Fatalf("unexpected failures %d and error %v", sp.Failures, sp.LastErr)
+	}
+	p.mtx.Lock()
+	p.setStage(SyncStageComplete)
+	p.mtx.Unlock()
+	if sp := p.snapshot(false, 0, 0, time.Now()); sp.Failures != 0 || sp.LastErr != nil {
+		t.Fatalf("unexpected failures %d and error %v", sp.Failures, sp.LastErr)
+	}
+}
+
+func TestNormalizePeerAddr(t *testing.T) {
+	tests := []struct {
+		name, addr, want string
+		wantErr          bool
+	}{{
+		name: "ok with port",
+		addr: "127.0.0.1:19560",
+		want: "127.0.0.1:19560",
+	}, {
+		name: "ok default port",
+		addr: "127.0.0.1",
+		want: "127.0.0.1:18555",
+	}, {
+		name: "ok hostname",
+		addr: "node.example.com",
+		want: "node.example.com:18555",
+	}, {
+		name: "ok ipv6 default port",
+		addr: "::1",
+		want: "[::1]:18555",
+	}, {
+		name: "ok ipv6 canonical",
+		addr: "[0:0::1]:9108",
+		want: "[::1]:9108",
+	}, {
+		name:    "empty host",
+		addr:    ":9108",
+		wantErr: true,
+	}, {
+		name:    "bad port",
+		addr:    "127.0.0.1:port",
+		wantErr: true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			addr, err := normalizePeerAddr(test.addr, chaincfg.SimNetParams().DefaultPort)
+			if test.wantErr {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if addr != test.want {
+				t.Fatalf("expected %q but got %q", test.want, addr)
+			}
+		})
+	}
+}
+
+func TestPeerManagement(t *testing.T) {
+	ctx := context.Background()
+	openParams := OpenWalletParams{
+		Net:      "simnet",
+		DataDir:  t.TempDir(),
+		DbDriver: "bdb",
+		Logger:   slog.Disabled,
+	}
+	w, err := CreateWallet(ctx, CreateWalletParams{
+		OpenWalletParams: openParams,
+		Pass:             []byte("pass"),
+	}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if peers := w.Peers(); peers != nil {
+		t.Fatalf("expected no peers without sync but got %v", peers)
+	}
+	if err := w.AddPeer("127.0.0.1"); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err := w.AddPeer("127.0.0.2:18555"); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	// Adding a peer again does nothing.
+	if err := w.AddPeer("127.0.0.1:18555"); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err := w.DisconnectPeer("127.0.0.2"); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err := w.DisconnectPeer("127.0.0.3"); err == nil {
+		t.Fatal("expected an error disconnecting an unknown peer")
+	}
+	if err := w.BanPeer("127.0.0.4", 0); err == nil {
+		t.Fatal("expected an error with a zero ban duration")
+	}
+	if err := w.BanPeer("127.0.0.4", time.Hour); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if _, err := w.dialPeer(ctx, "tcp", "127.0.0.4:18555"); err == nil {
+		t.Fatal("expected an error dialing a banned peer")
+	}
+	if w.isBanned("127.0.0.4:1", time.Now().Add(2*time.Hour)) {
+		t.Fatal("expected the ban to expire")
+	}
+
+	// Concurrent changes are all saved and the wallet data is updated in
+	// place like other writers do, so it is safe to run with -race.
+	metaData := w.metaData
+	var wg sync.WaitGroup
+	for i := 10; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			addr := fmt.Sprintf("127.0.1.%d", i)
+			if err := w.AddPeer(addr); err != nil {
+				t.Errorf("unexpected error %v", err)
+			}
+			if err := w.BanPeer(fmt.Sprintf("127.0.2.%d", i), time.Hour); err != nil {
+				t.Errorf("unexpected error %v", err)
+			}
+			w.isBanned(addr, time.Now())
+			w.syncPeers()
+			w.HasStoredSeed()
+		}()
+	}
+	wg.Wait()
+	if w.metaData != metaData {
+		t.Fatal("expected the wallet data to be updated in place")
+	}
+	if peers := w.syncPeers(); len(peers) != 11 {
+		t.Fatalf("expected 11 persistent peers but got %d", len(peers))
+	}
+	if n := len(w.metaData.BannedPeers); n != 11 {
+		t.Fatalf("expected 11 banned peers but got %d", n)
+	}
+	for i := 10; i < 20; i++ {
+		if err := w.DisconnectPeer(fmt.Sprintf("127.0.1.%d", i)); err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+	}
+
+	if err := w.CloseWallet(); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	// Peers and bans are kept across restarts.
+	w, err = LoadWallet(ctx, openParams)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if peers := w.syncPeers(); len(peers) != 1 || peers[0] != "127.0.0.1:18555" {
+		t.Fatalf("expected persistent peer 127.0.0.1:18555 but got %v", peers)
+	}
+	if !w.isBanned("127.0.0.4:1", time.Now()) {
+		t.Fatal("expected host to be banned")
+	}
+}
+
+func TestCountingConn(t *testing.T) {
+	var pm peerManager
+	c1, c2 := net.Pipe()
+	defer c2.Close()
+	conn := pm.track("127.0.0.1:18555", c1, time.Second)
+
+	go func() {
+		b := make([]byte, 3)
+		io.ReadFull(c2, b)
+		c2.Write([]byte("hello"))
+	}()
+	if _, err := conn.Write([]byte("abc")); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if _, err := io.ReadFull(conn, make([]byte, 5)); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if sent, recv := conn.sent.Load(), conn.recv.Load(); sent != 3 || recv != 5 {
+		t.Fatalf("expected 3 bytes sent and 5 received but got %d and %d", sent, recv)
+	}
+	if pm.conns["127.0.0.1:18555"] != conn {
+		t.Fatal("expected connection to be tracked")
+	}
+	conn.Close()
+	if len(pm.conns) != 0 {
+		t.Fatal("expected closed connection to be untracked")
+	}
+}
+
+// socksRequest is a connection request received by testSocksProxy.
+type socksRequest struct {
+	user, pass, target string
+}
+
+// testSocksProxy is a minimal SOCKS5 proxy that records the requests it
+// receives and connects them to their targets.
+func testSocksProxy(t *testing.T) (addr string, requests <-chan socksRequest) {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	t.Cleanup(func() { l.Close() })
+	reqs := make(chan socksRequest, 10)
+	serve := func(conn net.Conn) {
+		defer conn.Close()
+		var req socksRequest
+		buf := make([]byte, 256)
+		readN := func(n int) []byte {
+			if _, err := io.ReadFull(conn, buf[:n]); err != nil {
+				return nil
+			}
+			return buf[:n]
+		}
+		b := readN(2)
+		if b == nil {
+			return
+		}
+		methods := readN(int(b[1]))
+		if methods == nil {
+			return
+		}
+		if bytes.IndexByte(methods, 2) >= 0 {
+			conn.Write([]byte{5, 2})
+			b = readN(2)
+			if b == nil {
+				return
+			}
+			req.user = string(readN(int(b[1])))
+			req.pass = string(readN(int(readN(1)[0])))
+			conn.Write([]byte{1, 0})
+		} else {
+			conn.Write([]byte{5, 0})
+		}
+		// Only domain connect requests are sent by go-socks.
+		if readN(4) == nil {
+			return
+		}
+		host := string(readN(int(readN(1)[0])))
+		p := readN(2)
+		req.target = net.JoinHostPort(host, strconv.Itoa(int(p[0])<<8|int(p[1])))
+		select {
+		case reqs <- req:
+		default:
+		}
+		target, err := net.Dial("tcp", req.target)
+		if err != nil {
+			conn.Write([]byte{5, 5, 0, 1, 0, 0, 0, 0, 0, 0})
+			return
+		}
+		defer target.Close()
+		conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+		go io.Copy(target, conn)
+		io.Copy(conn, target)
+	}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go serve(conn)
+		}
+	}()
+	return l.Addr().String(), reqs
+}
+
+func TestProxyConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     *ProxyConfig
+		wantErr bool
+	}{{
+		name: "ok nil",
+	}, {
+		name: "ok",
+		cfg:  &ProxyConfig{Addr: "127.0.0.1:9050"},
+	}, {
+		name:    "no address",
+		cfg:     &ProxyConfig{},
+		wantErr: true,
+	}, {
+		name:    "no port",
+		cfg:     &ProxyConfig{Addr: "127.0.0.1"},
+		wantErr: true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.cfg.validate()
+			if test.wantErr {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+		})
+	}
+}
+
+func TestProxyDial(t *testing.T) {
+	proxyAddr, requests := testSocksProxy(t)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+	srvAddr := srv.Listener.Addr().String()
+
+	// An unused port for a proxy that cannot be reached.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	closedAddr := l.Addr().String()
+	l.Close()
+
+	tests := []struct {
+		name      string
+		proxy     *ProxyConfig
+		wantErr   bool
+		wantProxy bool
+		wantCreds bool
+	}{{
+		name:  "ok no proxy",
+		proxy: nil,
+	}, {
+		name:      "ok proxy",
+		proxy:     &ProxyConfig{Addr: proxyAddr, NoClearnetFallback: true},
+		wantProxy: true,
+	}, {
+		name:      "ok proxy credentials",
+		proxy:     &ProxyConfig{Addr: proxyAddr, Username: "user", Password: "pass"},
+		wantProxy: true,
+		wantCreds: true,
+	}, {
+		name:      "ok tor isolation",
+		proxy:     &ProxyConfig{Addr: proxyAddr, TorIsolation: true},
+		wantProxy: true,
+	}, {
+		name:  "ok clearnet fallback",
+		proxy: &ProxyConfig{Addr: closedAddr},
+	}, {
+		name:    "no clearnet fallback",
+		proxy:   &ProxyConfig{Addr: closedAddr, NoClearnetFallback: true},
+		wantErr: true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := &Wallet{log: slog.Disabled, proxy: test.proxy}
+			res, err := w.httpClient().Get(srv.URL)
+			if test.wantErr {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			body, _ := io.ReadAll(res.Body)
+			res.Body.Close()
+			if string(body) != "ok" {
+				t.Fatalf("unexpected response %q", body)
+			}
+
+			var req socksRequest
+			select {
+			case req = <-requests:
+			default:
+				if test.wantProxy {
+					t.Fatal("expected a request through the proxy")
+				}
+				return
+			}
+			if !test.wantProxy {
+				t.Fatal("unexpected request through the proxy")
+			}
+			if req.target != srvAddr {
+				t.Fatalf("expected target %s but got %s", srvAddr, req.target)
+			}
+			if test.wantCreds && (req.user != "user" || req.pass != "pass") {
+				t.Fatalf("expected proxy credentials but got %q and %q", req.user, req.pass)
+			}
+			if test.proxy.TorIsolation && (req.user == "" || req.pass == "") {
+				t.Fatal("expected random credentials for tor isolation")
+			}
 		})
 	}
+
+	t.Run("probe", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		params := OpenWalletParams{
+			Net:     "simnet",
+			DataDir: t.TempDir(),
+			Logger:  slog.Disabled,
+			Proxy:   &ProxyConfig{Addr: proxyAddr, NoClearnetFallback: true},
+		}
+		recovery := &RecoveryCfg{
+			Seed:     bytes.Repeat([]byte{3}, 32),
+			SeedType: STThirtyThreeWords,
+		}
+		if _, err := ProbeSeed(ctx, params, recovery, "node.example.com:18555"); err == nil {
+			t.Fatal("expected an error probing with a host name peer without clearnet fallback")
+		}
+		// No peer listens at the closed address, so the probe only
+		// ends with the ctx.
+		if _, err := ProbeSeed(ctx, params, recovery, closedAddr); err == nil {
+			t.Fatal("expected an error without a peer")
+		}
+		select {
+		case req := <-requests:
+			if req.target != closedAddr {
+				t.Fatalf("expected target %s but got %s", closedAddr, req.target)
+			}
+		default:
+			t.Fatal("expected the probe to connect through the proxy")
+		}
+	})
+}
+
+func TestProxyPeerHosts(t *testing.T) {
+	ctx := context.Background()
+	w, err := CreateWallet(ctx, CreateWalletParams{
+		OpenWalletParams: OpenWalletParams{
+			Net:      "simnet",
+			DataDir:  t.TempDir(),
+			DbDriver: "bdb",
+			Logger:   slog.Disabled,
+			Proxy:    &ProxyConfig{Addr: "127.0.0.1:9050", NoClearnetFallback: true},
+		},
+		Pass: []byte("pass"),
+	}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer w.CloseWallet()
+
+	if err := w.AddPeer("node.example.com"); err == nil {
+		t.Fatal("expected an error adding a host name peer without clearnet fallback")
+	}
+	if err := w.StartSync(ctx, nil, "node.example.com:18555"); err == nil {
+		t.Fatal("expected an error syncing with a host name peer without clearnet fallback")
+	}
+	if err := w.AddPeer("127.0.0.1"); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
 }
@@ -0,0 +1,134 @@
+package dcr
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// ErrWalletOpen is returned when trying to delete a wallet that is open.
+var ErrWalletOpen = errors.New("wallet is open")
+
+const (
+	peersFileName = "peers.json" // written by the address manager
+	logsDirName   = "logs"
+)
+
+var (
+	// openWalletsMtx protects openWallets.
+	openWalletsMtx sync.Mutex
+	// openWallets holds the data directories of open wallets, keyed by
+	// walletDirKey.
+	openWallets = make(map[string]struct{})
+)
+
+// walletDirKey returns the absolute path of dataDir with any symlinks
+// resolved, so that every path to the same directory has the same key.
+func walletDirKey(dataDir string) (string, error) {
+	dir, err := filepath.Abs(dataDir)
+	if err != nil {
+		return "", fmt.Errorf("unable to resolve wallet directory %q: %v", dataDir, err)
+	}
+	resolved, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return dir, nil
+		}
+		return "", fmt.Errorf("unable to resolve wallet directory %q: %v", dataDir, err)
+	}
+	return resolved, nil
+}
+
+// markWalletOpen records that the wallet in dataDir is being opened and
+// returns the key to pass to markWalletClosed. An error is returned if it is
+// already open.
+func markWalletOpen(dataDir string) (string, error) {
+	dir, err := walletDirKey(dataDir)
+	if err != nil {
+		return "", err
+	}
+	openWalletsMtx.Lock()
+	defer openWalletsMtx.Unlock()
+	if _, open := openWallets[dir]; open {
+		return "", ErrWalletOpen
+	}
+	openWallets[dir] = struct{}{}
+	return dir, nil
+}
+
+// markWalletClosed records that the wallet with the key returned by
+// markWalletOpen is closed.
+func markWalletClosed(dir string) {
+	openWalletsMtx.Lock()
+	defer openWalletsMtx.Unlock()
+	delete(openWallets, dir)
+}
+
+// DeleteWalletOpts are options for DeleteWallet.
+type DeleteWalletOpts struct {
+	// KeepWalletData keeps the wallet data file, which holds the encrypted
+	// seed, so that the wallet can be restored later with
+	// RecoveryCfg.UseLocalSeed.
+	KeepWalletData bool
+}
+
+// DeleteWallet removes the wallet in dataDir from disk. This includes the
+// wallet database, the wallet data file and its backup, the peers file, any
+// seed probe cache and any logs in the data directory. The data directory itself is removed if nothing
+// else is left in it. The wallet must not be open.
+func DeleteWallet(dataDir string, opts *DeleteWalletOpts) error {
+	if opts == nil {
+		opts = new(DeleteWalletOpts)
+	}
+
+	dir, err := walletDirKey(dataDir)
+	if err != nil {
+		return err
+	}
+	// Hold the lock while deleting so that the wallet is not opened
+	// concurrently.
+	openWalletsMtx.Lock()
+	defer openWalletsMtx.Unlock()
+	if _, open := openWallets[dir]; open {
+		return ErrWalletOpen
+	}
+
+	entries, err := os.ReadDir(dataDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("wallet at %q doesn't exist", dataDir)
+		}
+		return fmt.Errorf("unable to read wallet directory: %v", err)
+	}
+
+	for _, net := range []string{"mainnet", "testnet", "simnet"} {
+		if err := RemoveSeedProbeCache(dataDir, net); err != nil {
+			return fmt.Errorf("unable to remove seed probe cache: %v", err)
+		}
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		switch {
+		case name == walletDbName, name == peersFileName, name == logsDirName,
+			strings.HasSuffix(name, ".log"):
+		case name == walletDataFileName, name == walletDataBackupFileName,
+			strings.HasPrefix(name, walletDataFileName+".tmp"):
+			if opts.KeepWalletData {
+				continue
+			}
+		default:
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(dataDir, name)); err != nil {
+			return fmt.Errorf("unable to remove %s: %v", name, err)
+		}
+	}
+
+	// Only succeeds if the directory is empty.
+	_ = os.Remove(dataDir)
+	return nil
+}
@@ -1,11 +1,16 @@
 package dcr
 
 import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
 	"encoding/hex"
 	"errors"
+	"fmt"
 
 	"github.com/kevinburke/nacl"
 	"github.com/kevinburke/nacl/secretbox"
+	"golang.org/x/crypto/argon2"
 	"golang.org/x/crypto/scrypt"
 )
 
@@ -13,34 +18,217 @@ var (
 	ErrInvalidPassphrase = errors.New("invalid_passphrase")
 )
 
-// makeEncryptionKey loads a nacl.Key using a cryptographic key generated from
-// the provided passphrase via scrypt.Key.
-func makeEncryptionKey(pass []byte) (nacl.Key, error) {
-	const N, r, p, keyLength = 1 << 15, 8, 1, 32
-	keyBytes, err := scrypt.Key(pass, nil, N, r, p, keyLength)
-	if err != nil {
+// KDF identifies the key derivation function used to derive an encryption key
+// from a passphrase.
+type KDF byte
+
+const (
+	KDFScrypt KDF = iota + 1
+	KDFArgon2id
+)
+
+// Encrypted data is stored in an envelope with the following layout:
+//
+//	magic (4) | version (1) | kdf (1) | kdf params (3 x uint32) | salt (16) | sealed box
+//
+// The kdf params are N, r and p for scrypt and time, memory in KiB and threads
+// for Argon2id. Data encrypted before the envelope was introduced is a bare
+// sealed box with a key derived by scrypt without a salt.
+const (
+	envelopeVersion    = 1
+	envelopeSaltSize   = 16
+	envelopeHeaderSize = 4 + 1 + 1 + 3*4 + envelopeSaltSize
+
+	defaultArgon2Time    = 3
+	defaultArgon2Memory  = 64 * 1024
+	defaultArgon2Threads = 4
+
+	legacyScryptN = 1 << 15
+	legacyScryptR = 8
+	legacyScryptP = 1
+
+	// The limits of the kdf params keep data from untrusted sources, such
+	// as backups, from exhausting memory or time during key derivation.
+	// Argon2id memory is in KiB, and scrypt uses 128·N·r bytes of memory.
+	maxArgon2Time    = 10
+	maxArgon2Memory  = 1 << 20 // 1 GiB
+	maxArgon2Threads = 255
+	maxScryptN       = 1 << 20
+	maxScryptR       = 8
+	maxScryptRP      = 16
+
+	keyLength = 32
+)
+
+var envelopeMagic = []byte{'l', 'w', 'e', 'd'}
+
+// kdfParams are the key derivation settings stored with the encrypted data.
+type kdfParams struct {
+	kdf        KDF
+	p1, p2, p3 uint32
+	salt       []byte
+}
+
+// errKDFParams is returned for kdf settings that are invalid or above the
+// limits.
+var errKDFParams = errors.New("invalid kdf params")
+
+// validate checks that the kdf settings are valid and within the limits.
+func (kp *kdfParams) validate() error {
+	switch kp.kdf {
+	case KDFScrypt:
+		// N must be a power of two greater than one.
+		if kp.p1 <= 1 || kp.p1&(kp.p1-1) != 0 || kp.p2 == 0 || kp.p3 == 0 {
+			return errKDFParams
+		}
+		if kp.p1 > maxScryptN || kp.p2 > maxScryptR || uint64(kp.p2)*uint64(kp.p3) > maxScryptRP {
+			return fmt.Errorf("%w: scrypt N %d, r %d and p %d exceed the limits", errKDFParams, kp.p1, kp.p2, kp.p3)
+		}
+	case KDFArgon2id:
+		if kp.p1 == 0 || kp.p2 == 0 || kp.p3 == 0 {
+			return errKDFParams
+		}
+		if kp.p1 > maxArgon2Time || kp.p2 > maxArgon2Memory || kp.p3 > maxArgon2Threads {
+			return fmt.Errorf("%w: argon2id time %d, memory %d KiB and threads %d exceed the limits",
+				errKDFParams, kp.p1, kp.p2, kp.p3)
+		}
+	default:
+		return fmt.Errorf("unknown kdf %d", kp.kdf)
+	}
+	return nil
+}
+
+// deriveKey derives a nacl.Key from the passphrase using the kdf settings.
+// Settings that are invalid or above the limits are rejected before deriving.
+func (kp *kdfParams) deriveKey(pass []byte) (nacl.Key, error) {
+	if err := kp.validate(); err != nil {
 		return nil, err
 	}
+	var keyBytes []byte
+	switch kp.kdf {
+	case KDFScrypt:
+		var err error
+		keyBytes, err = scrypt.Key(pass, kp.salt, int(kp.p1), int(kp.p2), int(kp.p3), keyLength)
+		if err != nil {
+			return nil, err
+		}
+	case KDFArgon2id:
+		keyBytes = argon2.IDKey(pass, kp.salt, kp.p1, kp.p2, uint8(kp.p3), keyLength)
+	default:
+		return nil, fmt.Errorf("unknown kdf %d", kp.kdf)
+	}
 	return nacl.Load(hex.EncodeToString(keyBytes))
 }
 
-// EncryptData encrypts the provided data with the provided passphrase.
+// parseEnvelope splits data in the envelope format into its kdf settings and
+// the sealed box. ok is false if data is not in the envelope format. The kdf
+// settings are not checked against the limits until a key is derived.
+func parseEnvelope(data []byte) (kp *kdfParams, sealed []byte, ok bool) {
+	if len(data) < envelopeHeaderSize || !bytes.Equal(data[:4], envelopeMagic) || data[4] != envelopeVersion {
+		return nil, nil, false
+	}
+	kp = &kdfParams{
+		kdf:  KDF(data[5]),
+		p1:   binary.BigEndian.Uint32(data[6:10]),
+		p2:   binary.BigEndian.Uint32(data[10:14]),
+		p3:   binary.BigEndian.Uint32(data[14:18]),
+		salt: data[18:envelopeHeaderSize],
+	}
+	if kp.kdf != KDFScrypt && kp.kdf != KDFArgon2id {
+		return nil, nil, false
+	}
+	return kp, data[envelopeHeaderSize:], true
+}
+
+// isLegacyData returns true if data was encrypted before the envelope format
+// was introduced.
+func isLegacyData(data []byte) bool {
+	_, _, ok := parseEnvelope(data)
+	return !ok
+}
+
+// plaintextLen returns the length of the data encrypted in data.
+func plaintextLen(data []byte) int {
+	if _, sealed, ok := parseEnvelope(data); ok {
+		data = sealed
+	}
+	// A sealed box holds a 24 byte nonce and a 16 byte authenticator.
+	const overhead = 24 + 16
+	return len(data) - overhead
+}
+
+// EncryptData encrypts the provided data with the provided passphrase using a
+// key derived with Argon2id and a random salt.
 func EncryptData(data, passphrase []byte) ([]byte, error) {
-	key, err := makeEncryptionKey(passphrase)
+	return encryptDataWithKDF(data, passphrase, KDFArgon2id)
+}
+
+// encryptDataWithKDF encrypts data with a key derived from the passphrase by
+// kdf using its default settings.
+func encryptDataWithKDF(data, passphrase []byte, kdf KDF) ([]byte, error) {
+	kp, err := newKDFParams(kdf)
+	if err != nil {
+		return nil, err
+	}
+	key, err := kp.deriveKey(passphrase)
 	if err != nil {
 		return nil, err
 	}
-	return secretbox.EasySeal(data, key), nil
+	return append(kp.envelopeHeader(), secretbox.EasySeal(data, key)...), nil
+}
+
+// newKDFParams returns the default settings of kdf with a random salt.
+func newKDFParams(kdf KDF) (*kdfParams, error) {
+	kp := &kdfParams{
+		kdf:  kdf,
+		salt: make([]byte, envelopeSaltSize),
+	}
+	switch kdf {
+	case KDFScrypt:
+		kp.p1, kp.p2, kp.p3 = legacyScryptN, legacyScryptR, legacyScryptP
+	case KDFArgon2id:
+		kp.p1, kp.p2, kp.p3 = defaultArgon2Time, defaultArgon2Memory, defaultArgon2Threads
+	default:
+		return nil, fmt.Errorf("unknown kdf %d", kdf)
+	}
+	if _, err := rand.Read(kp.salt); err != nil {
+		return nil, fmt.Errorf("unable to generate salt: %v", err)
+	}
+	return kp, nil
 }
 
-// DecryptData uses the provided passphrase to decrypt the provided data.
+// envelopeHeader returns the envelope header that stores the kdf settings.
+func (kp *kdfParams) envelopeHeader() []byte {
+	b := make([]byte, envelopeHeaderSize)
+	copy(b, envelopeMagic)
+	b[4] = envelopeVersion
+	b[5] = byte(kp.kdf)
+	binary.BigEndian.PutUint32(b[6:10], kp.p1)
+	binary.BigEndian.PutUint32(b[10:14], kp.p2)
+	binary.BigEndian.PutUint32(b[14:18], kp.p3)
+	copy(b[18:], kp.salt)
+	return b
+}
+
+// DecryptData uses the provided passphrase to decrypt the provided data. Data
+// encrypted before the envelope format was introduced is also accepted.
 func DecryptData(data, passphrase []byte) ([]byte, error) {
-	key, err := makeEncryptionKey(passphrase)
+	kp, sealed, ok := parseEnvelope(data)
+	if !ok {
+		kp = &kdfParams{
+			kdf: KDFScrypt,
+			p1:  legacyScryptN,
+			p2:  legacyScryptR,
+			p3:  legacyScryptP,
+		}
+		sealed = data
+	}
+	key, err := kp.deriveKey(passphrase)
 	if err != nil {
 		return nil, err
 	}
 
-	decryptedData, err := secretbox.EasyOpen(data, key)
+	decryptedData, err := secretbox.EasyOpen(sealed, key)
 	if err != nil {
 		return nil, ErrInvalidPassphrase
 	}
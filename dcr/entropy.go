@@ -0,0 +1,147 @@
+package dcr
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/decred/dcrd/hdkeychain/v3"
+)
+
+// User entropy types.
+const (
+	EntropyDice  = "dice"  // rolls of a six sided die, 1-6
+	EntropyCoins = "coins" // coin flips, h/t or 0/1
+	EntropyHex   = "hex"   // raw hex
+)
+
+// entropyMixMethod describes how user entropy is mixed into a new seed.
+const entropyMixMethod = "seed = HMAC-SHA256(key = system random bytes, message = type || 0x00 || normalized user entropy)[:seed length]"
+
+// UserEntropy is entropy supplied by the user, such as from dice rolls made on
+// an air-gapped machine, that is mixed with system randomness to create a new
+// seed. It never replaces system randomness, so poor user entropy cannot
+// weaken the seed.
+type UserEntropy struct {
+	// Type is one of EntropyDice, EntropyCoins or EntropyHex.
+	Type string `json:"type"`
+	// Data is the rolls, flips or hex. Whitespace and commas are ignored.
+	Data string `json:"data"`
+}
+
+// EntropyAudit records how a new seed was derived from system randomness
+// and user entropy. It does not hold any secrets.
+type EntropyAudit struct {
+	Method      string `json:"method"`
+	SystemBytes int    `json:"systembytes"`
+	UserType    string `json:"usertype"`
+	UserSymbols int    `json:"usersymbols"`
+	// UserBits is an estimate of the entropy of the user input in bits,
+	// assuming fair dice and coins.
+	UserBits float64 `json:"userbits"`
+	// UserSHA256Hex is the sha256 hash of the normalized user input so
+	// that the user can check that their input was used.
+	UserSHA256Hex string `json:"usersha256hex"`
+	SeedBytes     int    `json:"seedbytes"`
+	Created       int64  `json:"created"`
+}
+
+// normalize returns the user input without separators and the estimated bits
+// of entropy it holds.
+func (ue *UserEntropy) normalize() (string, float64, error) {
+	data := strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) || r == ',' {
+			return -1
+		}
+		return unicode.ToLower(r)
+	}, ue.Data)
+	if data == "" {
+		return "", 0, fmt.Errorf("no %s entropy", ue.Type)
+	}
+	var valid string
+	var bitsPerSymbol float64
+	switch ue.Type {
+	case EntropyDice:
+		valid, bitsPerSymbol = "123456", math.Log2(6)
+	case EntropyCoins:
+		// Store flips as bits.
+		data = strings.NewReplacer("h", "1", "t", "0").Replace(data)
+		valid, bitsPerSymbol = "01", 1
+	case EntropyHex:
+		if len(data)%2 != 0 {
+			return "", 0, errors.New("odd length hex entropy")
+		}
+		valid, bitsPerSymbol = "0123456789abcdef", 4
+	default:
+		return "", 0, fmt.Errorf("unknown entropy type %q", ue.Type)
+	}
+	for i, r := range data {
+		if !strings.ContainsRune(valid, r) {
+			return "", 0, fmt.Errorf("invalid %s entropy symbol %q at position %d", ue.Type, r, i)
+		}
+	}
+	return data, float64(len(data)) * bitsPerSymbol, nil
+}
+
+// generateSeed returns a new random seed of seedLen bytes. If ue is not nil,
+// its entropy is mixed with the system randomness and an audit record of the
+// derivation is returned.
+func generateSeed(seedLen int, ue *UserEntropy) ([]byte, *EntropyAudit, error) {
+	if ue == nil {
+		seed, err := hdkeychain.GenerateSeed(uint8(seedLen))
+		if err != nil {
+			return nil, nil, fmt.Errorf("unable to generate random seed: %v", err)
+		}
+		return seed, nil, nil
+	}
+	if seedLen > sha256.Size {
+		return nil, nil, fmt.Errorf("seed length %d is too long", seedLen)
+	}
+	data, bits, err := ue.normalize()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Use as much system randomness as the hash output.
+	sysRand, err := hdkeychain.GenerateSeed(sha256.Size)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to generate random seed: %v", err)
+	}
+
+	mac := hmac.New(sha256.New, sysRand)
+	mac.Write([]byte(ue.Type))
+	mac.Write([]byte{0})
+	mac.Write([]byte(data))
+	seed := mac.Sum(nil)[:seedLen]
+
+	userHash := sha256.Sum256([]byte(data))
+	audit := &EntropyAudit{
+		Method:        entropyMixMethod,
+		SystemBytes:   len(sysRand),
+		UserType:      ue.Type,
+		UserSymbols:   len(data),
+		UserBits:      math.Floor(bits*100) / 100,
+		UserSHA256Hex: hex.EncodeToString(userHash[:]),
+		SeedBytes:     seedLen,
+		Created:       time.Now().Unix(),
+	}
+	return seed, audit, nil
+}
+
+// EntropyAudit returns the record of how the wallet seed was derived from user
+// entropy, or nil if no user entropy was used.
+func (w *Wallet) EntropyAudit() *EntropyAudit {
+	w.seedMtx.Lock()
+	defer w.seedMtx.Unlock()
+	if w.metaData.EntropyAudit == nil {
+		return nil
+	}
+	audit := *w.metaData.EntropyAudit
+	return &audit
+}
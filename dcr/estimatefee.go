@@ -18,7 +18,8 @@ const (
 	testnetExternalApiUrl = "https://testnet.dcrdata.org/insight/api"
 )
 
-// FetchFeeFromOracle gets the fee rate from the external API.
+// FetchFeeFromOracle gets the fee rate from the external API through the proxy
+// of the wallet, if any.
 func (w *Wallet) FetchFeeFromOracle(ctx context.Context, nBlocks uint64) (float64, error) {
 	var url string
 	if w.chainParams.Net == wire.TestNet3 {
@@ -33,7 +34,7 @@ func (w *Wallet) FetchFeeFromOracle(ctx context.Context, nBlocks uint64) (float6
 	if err != nil {
 		return 0, err
 	}
-	httpResponse, err := http.DefaultClient.Do(r)
+	httpResponse, err := w.httpClient().Do(r)
 	if err != nil {
 		return 0, err
 	}
@@ -0,0 +1,412 @@
+package dcr
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"decred.org/dcrwallet/v5/wallet"
+	"github.com/decred/dcrd/chaincfg/chainhash"
+	"github.com/decred/dcrd/chaincfg/v3"
+	"github.com/decred/dcrd/wire"
+)
+
+// EventType identifies the kind of an Event.
+type EventType string
+
+const (
+	EventSyncStage         EventType = "syncstage"
+	EventPeerConnected     EventType = "peerconnected"
+	EventPeerDisconnected  EventType = "peerdisconnected"
+	EventBlockConnected    EventType = "blockconnected"
+	EventBlockDisconnected EventType = "blockdisconnected"
+	EventTransaction       EventType = "transaction"
+	EventBalance           EventType = "balance"
+	EventRescanProgress    EventType = "rescanprogress"
+	EventTicket            EventType = "ticket"
+	// EventDropped reports that older events were dropped because they
+	// were not read before the queue filled up.
+	EventDropped EventType = "dropped"
+)
+
+// The ticket statuses of ticket events. Missed and expired tickets are
+// reported when they are revoked.
+const (
+	TicketStatusUnmined  = "unmined"
+	TicketStatusImmature = "immature"
+	TicketStatusLive     = "live"
+	TicketStatusVoted    = "voted"
+	TicketStatusMissed   = "missed"
+	TicketStatusExpired  = "expired"
+)
+
+// defaultEventQueueSize is the number of events kept for each wallet until
+// they are read.
+const defaultEventQueueSize = 1000
+
+// Event is a change in the wallet. Only the field for its type is set.
+type Event struct {
+	Type EventType `json:"type"`
+	// Time is the unix time of the event.
+	Time      int64           `json:"time"`
+	SyncStage *SyncStageEvent `json:"syncstage,omitempty"`
+	Peer      *PeerEvent      `json:"peer,omitempty"`
+	Block     *BlockEvent     `json:"block,omitempty"`
+	Tx        *TxEvent        `json:"tx,omitempty"`
+	Balance   *BalanceEvent   `json:"balance,omitempty"`
+	Rescan    *RescanEvent    `json:"rescan,omitempty"`
+	Ticket    *TicketEvent    `json:"ticket,omitempty"`
+	// Dropped is the number of events dropped.
+	Dropped int `json:"dropped,omitempty"`
+}
+
+type SyncStageEvent struct {
+	Stage SyncStage `json:"stage"`
+	Name  string    `json:"name"`
+}
+
+type PeerEvent struct {
+	Addr     string `json:"addr"`
+	NumPeers int32  `json:"numpeers"`
+}
+
+type BlockEvent struct {
+	Hash   string `json:"hash"`
+	Height int32  `json:"height"`
+}
+
+// TxEvent is a new relevant transaction or one that was mined.
+type TxEvent struct {
+	Hash string `json:"hash"`
+	// Type is one of regular, coinbase, ticket, vote or revocation.
+	Type string `json:"type"`
+	// BlockHash and BlockHeight are the block the transaction was mined
+	// in. BlockHeight is -1 if unmined.
+	BlockHash   string `json:"blockhash,omitempty"`
+	BlockHeight int32  `json:"blockheight"`
+}
+
+// BalanceEvent is the new total balance, including unconfirmed funds, of an
+// account in atoms.
+type BalanceEvent struct {
+	Account uint32 `json:"account"`
+	Total   int64  `json:"total"`
+}
+
+type RescanEvent struct {
+	Height int32 `json:"height"`
+}
+
+// TicketEvent is a change of the status of a ticket caused by the transaction
+// TxHash. TxHash is empty when the ticket became live.
+type TicketEvent struct {
+	Hash   string `json:"hash"`
+	Status string `json:"status"`
+	TxHash string `json:"txhash,omitempty"`
+}
+
+// eventQueue holds events until they are read. When full, the oldest events
+// are dropped.
+type eventQueue struct {
+	mtx     sync.Mutex
+	size    int
+	events  []*Event
+	dropped int
+	// ready is closed and replaced when events are pushed.
+	ready chan struct{}
+}
+
+func newEventQueue(size int) *eventQueue {
+	return &eventQueue{
+		size:  size,
+		ready: make(chan struct{}),
+	}
+}
+
+// push adds e to the queue. A nil queue drops all events.
+func (q *eventQueue) push(e *Event) {
+	if q == nil {
+		return
+	}
+	e.Time = time.Now().Unix()
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+	if len(q.events) == q.size {
+		q.events[0] = nil
+		q.events = q.events[1:]
+		q.dropped++
+	}
+	q.events = append(q.events, e)
+	close(q.ready)
+	q.ready = make(chan struct{})
+}
+
+// next returns up to max events, or all queued events if max is zero, waiting
+// up to timeout for the first. If events were dropped, the first event is an
+// EventDropped.
+func (q *eventQueue) next(ctx context.Context, max int, timeout time.Duration) []*Event {
+	if q == nil {
+		return nil
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		q.mtx.Lock()
+		if events := q.take(max); len(events) > 0 {
+			q.mtx.Unlock()
+			return events
+		}
+		ready := q.ready
+		q.mtx.Unlock()
+
+		select {
+		case <-ready:
+		case <-timer.C:
+			return nil
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// take removes and returns up to max events, or all if max is zero. The mtx
+// MUST be held.
+func (q *eventQueue) take(max int) []*Event {
+	var events []*Event
+	if q.dropped > 0 {
+		events = append(events, &Event{
+			Type:    EventDropped,
+			Time:    time.Now().Unix(),
+			Dropped: q.dropped,
+		})
+		q.dropped = 0
+	}
+	n := len(q.events)
+	if max > 0 {
+		n = min(n, max-len(events))
+	}
+	events = append(events, q.events[:n]...)
+	q.events = append(q.events[:0:0], q.events[n:]...)
+	return events
+}
+
+// NextEvents returns up to max events that happened since the last call, or
+// all of them if max is zero, waiting up to timeout for the first. Events are
+// queued while the wallet is open. If events are not read often enough, the
+// oldest are dropped and reported with an EventDropped.
+func (w *Wallet) NextEvents(ctx context.Context, max int, timeout time.Duration) []*Event {
+	return w.events.next(ctx, max, timeout)
+}
+
+// txTypes are the names of transaction types in events.
+var txTypes = map[wallet.TransactionType]string{
+	wallet.TransactionTypeRegular:        "regular",
+	wallet.TransactionTypeCoinbase:       "coinbase",
+	wallet.TransactionTypeTicketPurchase: "ticket",
+	wallet.TransactionTypeVote:           "vote",
+	wallet.TransactionTypeRevocation:     "revocation",
+}
+
+// startTxNotifications queues events for the transaction notifications of the
+// main wallet until stopTxNotifications is called.
+func (w *Wallet) startTxNotifications() {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	w.ntfnsMtx.Lock()
+	w.stopNtfns = func() {
+		cancel()
+		<-done
+	}
+	w.ntfnsMtx.Unlock()
+	go func() {
+		defer close(done)
+		w.watchTxNotifications(ctx)
+	}()
+}
+
+// stopTxNotifications stops queueing events for transaction notifications and
+// waits for the watcher to return.
+func (w *Wallet) stopTxNotifications() {
+	w.ntfnsMtx.Lock()
+	stop := w.stopNtfns
+	w.stopNtfns = nil
+	w.ntfnsMtx.Unlock()
+	if stop != nil {
+		stop()
+	}
+}
+
+// watchTxNotifications queues events for the transaction notifications of the
+// main wallet until ctx is canceled.
+func (w *Wallet) watchTxNotifications(ctx context.Context) {
+	client := w.mainWallet.NtfnServer.TransactionNotifications()
+	defer client.Done()
+	for {
+		select {
+		case n := <-client.C:
+			w.queueTxNotifications(ctx, n)
+			for _, block := range n.AttachedBlocks {
+				w.queueLiveTickets(ctx, int32(block.Header.Height))
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// queueTxNotifications queues events for the blocks, transactions and
+// balances in n.
+func (w *Wallet) queueTxNotifications(ctx context.Context, n *wallet.TransactionNotifications) {
+	for _, header := range n.DetachedBlocks {
+		w.events.push(&Event{
+			Type: EventBlockDisconnected,
+			Block: &BlockEvent{
+				Hash:   header.BlockHash().String(),
+				Height: int32(header.Height),
+			},
+		})
+	}
+	for _, block := range n.AttachedBlocks {
+		blockHash := block.Header.BlockHash().String()
+		height := int32(block.Header.Height)
+		w.events.push(&Event{
+			Type:  EventBlockConnected,
+			Block: &BlockEvent{Hash: blockHash, Height: height},
+		})
+		for i := range block.Transactions {
+			w.queueTxSummary(ctx, &block.Transactions[i], blockHash, height)
+		}
+	}
+	for i := range n.UnminedTransactions {
+		w.queueTxSummary(ctx, &n.UnminedTransactions[i], "", -1)
+	}
+	for _, bal := range n.NewBalances {
+		w.events.push(&Event{
+			Type: EventBalance,
+			Balance: &BalanceEvent{
+				Account: bal.Account,
+				Total:   int64(bal.TotalBalance),
+			},
+		})
+	}
+}
+
+// queueTxSummary queues the events for a transaction mined in the block at
+// height, or unmined if height is -1.
+func (w *Wallet) queueTxSummary(ctx context.Context, tx *wallet.TransactionSummary, blockHash string, height int32) {
+	txHash := tx.Hash.String()
+	w.events.push(&Event{
+		Type: EventTransaction,
+		Tx: &TxEvent{
+			Hash:        txHash,
+			Type:        txTypes[tx.Type],
+			BlockHash:   blockHash,
+			BlockHeight: height,
+		},
+	})
+
+	ticket := &TicketEvent{TxHash: txHash}
+	switch tx.Type {
+	case wallet.TransactionTypeTicketPurchase:
+		ticket.Hash = txHash
+		ticket.Status = TicketStatusUnmined
+		if height >= 0 {
+			ticket.Status = TicketStatusImmature
+		}
+	case wallet.TransactionTypeVote, wallet.TransactionTypeRevocation:
+		// Votes spend the ticket in their second input after the stakebase
+		// and revocations in their first.
+		var msgTx wire.MsgTx
+		if err := msgTx.Deserialize(bytes.NewReader(tx.Transaction)); err != nil {
+			w.log.Errorf("Unable to decode transaction %s: %v", txHash, err)
+			return
+		}
+		in := 0
+		if tx.Type == wallet.TransactionTypeVote {
+			in = 1
+		}
+		if len(msgTx.TxIn) <= in {
+			return
+		}
+		ticketHash := &msgTx.TxIn[in].PreviousOutPoint.Hash
+		ticket.Hash = ticketHash.String()
+		ticket.Status = TicketStatusVoted
+		if tx.Type == wallet.TransactionTypeRevocation {
+			status, err := w.revokedTicketStatus(ctx, ticketHash, height)
+			if err != nil {
+				w.log.Errorf("Unable to get the status of ticket %s: %v", ticketHash, err)
+				return
+			}
+			ticket.Status = status
+		}
+	default:
+		return
+	}
+	w.events.push(&Event{Type: EventTicket, Ticket: ticket})
+}
+
+// revokedTicketStatus returns whether the ticket revoked by a revocation mined
+// at height, or unmined if height is -1, was missed or expired.
+func (w *Wallet) revokedTicketStatus(ctx context.Context, ticketHash *chainhash.Hash, height int32) (string, error) {
+	_, header, err := w.mainWallet.GetTicketInfo(ctx, ticketHash)
+	if err != nil {
+		return "", err
+	}
+	if header == nil {
+		return "", errors.New("ticket is not mined")
+	}
+	if height < 0 {
+		// An unmined revocation can be mined in the next block.
+		_, tipHeight := w.mainWallet.MainChainTip(ctx)
+		height = tipHeight + 1
+	}
+	return revocationStatus(w.chainParams, int32(header.Height), height), nil
+}
+
+// revocationStatus returns whether a ticket mined at ticketHeight and revoked
+// at revocationHeight was missed or expired.
+func revocationStatus(params *chaincfg.Params, ticketHeight, revocationHeight int32) string {
+	expiry := int32(params.TicketMaturity) + int32(params.TicketExpiryBlocks())
+	if revocationHeight-ticketHeight >= expiry {
+		return TicketStatusExpired
+	}
+	return TicketStatusMissed
+}
+
+// liveTicketsHeight returns the height of the tickets that become live in the
+// block at height. dcrd considers tickets live one block later than the
+// ticket maturity implies.
+func liveTicketsHeight(params *chaincfg.Params, height int32) int32 {
+	return height - int32(params.TicketMaturity) - 1
+}
+
+// queueLiveTickets queues events for the tickets of the wallet that became live
+// in the block at height.
+func (w *Wallet) queueLiveTickets(ctx context.Context, height int32) {
+	ticketsHeight := liveTicketsHeight(w.chainParams, height)
+	if ticketsHeight < 0 {
+		return
+	}
+	block := wallet.NewBlockIdentifierFromHeight(ticketsHeight)
+	err := w.mainWallet.GetTickets(ctx, func(tickets []*wallet.TicketSummary, _ *wire.BlockHeader) (bool, error) {
+		for _, t := range tickets {
+			// Without an RPC connection live tickets are unspent.
+			if t.Spender != nil || t.Status != wallet.TicketStatusUnspent {
+				continue
+			}
+			w.events.push(&Event{
+				Type: EventTicket,
+				Ticket: &TicketEvent{
+					Hash:   t.Ticket.Hash.String(),
+					Status: TicketStatusLive,
+				},
+			})
+		}
+		return false, nil
+	}, block, block)
+	if err != nil {
+		w.log.Errorf("Unable to get the tickets mined at height %d: %v", ticketsHeight, err)
+	}
+}
@@ -16,11 +16,15 @@ import (
 	"github.com/decred/dcrd/crypto/blake256"
 	"github.com/decred/dcrd/hdkeychain/v3"
 	"github.com/decred/libwallet/mnemonic"
+	"github.com/decred/libwallet/mnemonic/slip39"
 )
 
 const (
 	walletDbName = "wallet.db"
 	entropyBytes = 18 // 144 bits
+	// bip39EntropyBytes is the seed length of new wallets with a twenty
+	// four word mnemonic.
+	bip39EntropyBytes = 32 // 256 bits
 )
 
 // WalletExistsAt returns whether a wallet database file exists at the specified
@@ -39,6 +43,14 @@ func CreateWallet(ctx context.Context, params CreateWalletParams, recovery *Reco
 		return nil, fmt.Errorf("error parsing chain params: %w", err)
 	}
 
+	if err := params.WalletConfig.validate(); err != nil {
+		return nil, fmt.Errorf("invalid wallet config: %w", err)
+	}
+
+	if err := params.Proxy.validate(); err != nil {
+		return nil, fmt.Errorf("invalid proxy config: %w", err)
+	}
+
 	if exists, err := WalletExistsAt(params.DataDir); err != nil {
 		return nil, err
 	} else if exists {
@@ -50,15 +62,23 @@ func CreateWallet(ctx context.Context, params CreateWalletParams, recovery *Reco
 		return nil, fmt.Errorf("check new wallet data directory error: %w", err)
 	}
 
+	if params.Entropy != nil && recovery != nil {
+		return nil, errors.New("entropy cannot be used when recovering a wallet")
+	}
+
 	var (
 		seed, seedPass, tweakedSeed []byte
 		birthday                    time.Time
+		birthdayHeight              *uint32
 		seedType                    SeedType
+		lang                        mnemonic.Language
+		entropyAudit                *EntropyAudit
+		estimateBirthday            bool
 	)
 
 	if recovery != nil {
 		if recovery.UseLocalSeed {
-			wd, err := retrieveWalletData(params.DataDir)
+			wd, err := retrieveWalletData(params.DataDir, params.Logger)
 			if err != nil {
 				return nil, fmt.Errorf("unable to get wallet data: %v", err)
 			}
@@ -81,60 +101,90 @@ func CreateWallet(ctx context.Context, params CreateWalletParams, recovery *Reco
 				}
 			}
 			birthday = time.Unix(wd.Birthday, 0)
-			seedType = wd.SeedType
+			seedType, lang = wd.SeedType, wd.Language
+			estimateBirthday = wd.EstimateBirthday
+		} else if len(recovery.SeedShares) != 0 {
+			seed, err = slip39.CombineMnemonics(recovery.SeedShares, recovery.SeedPass)
+			if err != nil {
+				return nil, fmt.Errorf("unable to combine seed shares: %w", err)
+			}
+			birthday, seedType = recovery.Birthday, STSeedShares
 		} else {
 			seed, seedPass, birthday, seedType = recovery.Seed, recovery.SeedPass, recovery.Birthday, recovery.SeedType
+			lang = recovery.Language
+		}
+		if !recovery.UseLocalSeed {
+			birthdayHeight, estimateBirthday = recovery.BirthdayHeight, recovery.EstimateBirthday
+		}
+		if estimateBirthday {
+			if seedType == STFifteenWords {
+				return nil, errors.New("fifteen word seeds have a birthday to recover from")
+			}
+			// Skip scanning the chain on the first sync. The
+			// birthday is moved back once estimated.
+			birthday, birthdayHeight = time.Now(), nil
+		}
+		// An unknown birthday starts at the genesis block.
+		if genesis := chainParams.GenesisBlock.Header.Timestamp; birthday.Before(genesis) {
+			birthday = genesis
 		}
 	} else {
-		seed, err = hdkeychain.GenerateSeed(entropyBytes)
+		// Seed type is default fifteen words, which are only in
+		// English.
+		seedLen := entropyBytes
+		if params.Language != nil {
+			seedLen, seedType, lang = bip39EntropyBytes, STTwentyFourWords, *params.Language
+		}
+		seed, entropyAudit, err = generateSeed(seedLen, params.Entropy)
 		if err != nil {
-			return nil, fmt.Errorf("unable to generate random seed: %v", err)
+			return nil, err
 		}
 		birthday = time.Now()
-		// Seed type is default fifteen words.
 	}
 
-	switch seedType {
-	case STFifteenWords:
-		// Applying the seed pass to 15 word wallets breaks existing
-		// wallets when no pass is supplied. Also, the mnemonic cannot
-		// be used by other decred software if a passphrase is applied.
-		if len(seedPass) != 0 {
-			return nil, errors.New("seed passphrase cannot be used with 15 word mnemonics")
-		}
-		// Adjust seed to create the same wallet as bison wallet.
-		b := make([]byte, len(seed)+4)
-		copy(b, seed)
-		binary.BigEndian.PutUint32(b[len(seed):], 42)
-		ts := blake256.Sum256(b)
-		tweakedSeed = ts[:]
-	case STTwelveWords, STTwentyFourWords:
-		words, err := mnemonic.GenerateMnemonic(seed)
-		if err != nil {
-			return nil, fmt.Errorf("unable to recreate seed mnemonic: %v", err)
-		}
-		// Apply even if password is null.
-		tweakedSeed = mnemonic.ApplyPassphrase(seed, seedPass, words)
+	tweakedSeed, err = tweakSeed(seed, seedPass, seedType, lang)
+	if err != nil {
+		return nil, err
 	}
 
-	_, _, _, acctKeySLIP0044Priv, err := udb.HDKeysFromSeed(tweakedSeed, chainParams)
+	_, _, acctKeyLegacyPriv, acctKeySLIP0044Priv, err := udb.HDKeysFromSeed(tweakedSeed, chainParams)
 	if err != nil {
 		return nil, err
 	}
+	defer acctKeyLegacyPriv.Zero()
 	defer acctKeySLIP0044Priv.Zero()
-	xpub := acctKeySLIP0044Priv.Neuter()
-	wd, err := saveWalletData(seed, seedPass, xpub.String(), birthday, params.DataDir, params.Pass, seedType)
+	// New wallets are upgraded to the SLIP0044 coin type below. Recovered
+	// wallets start on the legacy coin type and are upgraded during sync
+	// if it has no history.
+	xpub, coinType := acctKeySLIP0044Priv.Neuter(), chainParams.SLIP0044CoinType
+	if recovery != nil {
+		xpub, coinType = acctKeyLegacyPriv.Neuter(), chainParams.LegacyCoinType
+	}
+	wd, err := newWalletData(seed, seedPass, xpub.String(), birthday, params.Pass, seedType, params.WalletConfig)
 	if err != nil {
+		return nil, err
+	}
+	wd.CoinType = coinType
+	wd.Language = lang
+	wd.EntropyAudit = entropyAudit
+	wd.EstimateBirthday = estimateBirthday
+	if err := saveWalletData(wd, params.DataDir); err != nil {
 		return nil, fmt.Errorf("saveWalletData error: %v", err)
 	}
 
 	ctx, cancel := context.WithTimeout(ctx, time.Minute)
 	defer cancel()
 
+	openDir, err := markWalletOpen(params.DataDir)
+	if err != nil {
+		return nil, err
+	}
+
 	// Create the wallet database using the specified db driver.
 	dbPath := filepath.Join(params.DataDir, walletDbName)
 	db, err := wallet.CreateDB(params.DbDriver, dbPath)
 	if err != nil {
+		markWalletClosed(openDir)
 		return nil, fmt.Errorf("CreateDB error: %w", err)
 	}
 
@@ -149,6 +199,7 @@ func CreateWallet(ctx context.Context, params CreateWalletParams, recovery *Reco
 			// deleting anything won't destroy a wallet in use. Attempt to
 			// remove any wallet remnants.
 			_ = os.Remove(params.DataDir)
+			markWalletClosed(openDir)
 		}
 	}()
 
@@ -159,16 +210,14 @@ func CreateWallet(ctx context.Context, params CreateWalletParams, recovery *Reco
 	}
 
 	// Open the newly-created wallet.
-	w, err := wallet.Open(ctx, newWalletConfig(db, chainParams))
+	walletCfg := newWalletConfig(db, chainParams, wd.Config)
+	walletCfg.DisableCoinTypeUpgrades = wd.EstimateBirthday
+	w, err := wallet.Open(ctx, walletCfg)
 	if err != nil {
 		return nil, fmt.Errorf("wallet.Open error: %w", err)
 	}
 
-	birthState := &udb.BirthdayState{
-		Time:        birthday.Add(time.Hour * -24),
-		SetFromTime: true,
-	}
-
+	birthState := newBirthState(birthday, birthdayHeight, chainParams)
 	if err := w.SetBirthState(ctx, birthState); err != nil {
 		return nil, fmt.Errorf("wallet.SetBirthState error: %w", err)
 	}
@@ -188,16 +237,74 @@ func CreateWallet(ctx context.Context, params CreateWalletParams, recovery *Reco
 	}
 
 	bailOnWallet = false
-	return &Wallet{
+	events := newEventQueue(defaultEventQueueSize)
+	wal := &Wallet{
 		dir:         params.DataDir,
 		dbDriver:    params.DbDriver,
 		chainParams: chainParams,
 		log:         params.Logger,
+		openDir:     openDir,
 		metaData:    wd,
 		db:          db,
 		mainWallet:  w,
 		syncHelper:  &syncHelper{log: params.Logger},
-	}, nil
+		progress:    syncProgress{events: events},
+		events:      events,
+		proxy:       params.Proxy,
+	}
+	wal.startTxNotifications()
+	return wal, nil
+}
+
+// tweakSeed returns the seed used to create the wallet's keys. Fifteen word
+// seeds are tweaked to create the same wallet as bison wallet and bip39 seeds
+// have the seed pass applied to their words in lang.
+func tweakSeed(seed, seedPass []byte, seedType SeedType, lang mnemonic.Language) ([]byte, error) {
+	switch seedType {
+	case STFifteenWords:
+		// Applying the seed pass to 15 word wallets breaks existing
+		// wallets when no pass is supplied. Also, the mnemonic cannot
+		// be used by other decred software if a passphrase is applied.
+		if len(seedPass) != 0 {
+			return nil, errors.New("seed passphrase cannot be used with 15 word mnemonics")
+		}
+		// Adjust seed to create the same wallet as bison wallet.
+		b := make([]byte, len(seed)+4)
+		copy(b, seed)
+		binary.BigEndian.PutUint32(b[len(seed):], 42)
+		ts := blake256.Sum256(b)
+		return ts[:], nil
+	case STTwelveWords, STTwentyFourWords:
+		// BIP0039 derives the seed from the words, so they must be in
+		// the language of the mnemonic.
+		words, err := bip39Words(seed, lang)
+		if err != nil {
+			return nil, fmt.Errorf("unable to recreate seed mnemonic: %v", err)
+		}
+		// Apply even if password is null.
+		return mnemonic.ApplyPassphrase(seed, seedPass, words), nil
+	case STThirtyThreeWords:
+		// dcrwallet uses the seed as is.
+		if len(seedPass) != 0 {
+			return nil, errors.New("seed passphrase cannot be used with 33 word mnemonics")
+		}
+		if len(seed) != mnemonic.PGPSeedBytes {
+			return nil, fmt.Errorf("33 word seeds must be %d bytes, got %d", mnemonic.PGPSeedBytes, len(seed))
+		}
+		return seed, nil
+	case STSeedShares:
+		// The SLIP-0039 passphrase is applied when combining the shares.
+		if len(seedPass) != 0 {
+			return nil, errors.New("seed passphrase cannot be used with seed shares")
+		}
+		if len(seed) < hdkeychain.MinSeedBytes || len(seed) > hdkeychain.MaxSeedBytes {
+			return nil, fmt.Errorf("seed from shares must be between %d and %d bytes, got %d",
+				hdkeychain.MinSeedBytes, hdkeychain.MaxSeedBytes, len(seed))
+		}
+		return seed, nil
+	default:
+		return nil, fmt.Errorf("unknown seed type %d", seedType)
+	}
 }
 
 // CreateWatchOnlyWallet creates and opens a watchonly SPV wallet.
@@ -207,6 +314,14 @@ func CreateWatchOnlyWallet(ctx context.Context, extendedPubKey string, params Cr
 		return nil, fmt.Errorf("error parsing chain params: %w", err)
 	}
 
+	if err := params.WalletConfig.validate(); err != nil {
+		return nil, fmt.Errorf("invalid wallet config: %w", err)
+	}
+
+	if err := params.Proxy.validate(); err != nil {
+		return nil, fmt.Errorf("invalid proxy config: %w", err)
+	}
+
 	if exists, err := WalletExistsAt(params.DataDir); err != nil {
 		return nil, err
 	} else if exists {
@@ -219,7 +334,7 @@ func CreateWatchOnlyWallet(ctx context.Context, extendedPubKey string, params Cr
 	}
 
 	if useLocalSeed {
-		wd, err := retrieveWalletData(params.DataDir)
+		wd, err := retrieveWalletData(params.DataDir, params.Logger)
 		if err != nil {
 			return nil, fmt.Errorf("unable to get wallet data: %v", err)
 		}
@@ -231,18 +346,27 @@ func CreateWatchOnlyWallet(ctx context.Context, extendedPubKey string, params Cr
 		return nil, fmt.Errorf("unable to parse extended key: %w", err)
 	}
 
-	wd, err := saveWalletData(nil, nil, xpub.String(), time.Time{}, params.DataDir, nil, 0) // password not required
+	wd, err := newWalletData(nil, nil, xpub.String(), time.Time{}, nil, 0, params.WalletConfig) // password not required
 	if err != nil {
+		return nil, err
+	}
+	if err := saveWalletData(wd, params.DataDir); err != nil {
 		return nil, fmt.Errorf("saveWalletData error: %v", err)
 	}
 
 	ctx, cancel := context.WithTimeout(ctx, time.Minute)
 	defer cancel()
 
+	openDir, err := markWalletOpen(params.DataDir)
+	if err != nil {
+		return nil, err
+	}
+
 	// Create the wallet database using the specified db driver.
 	dbPath := filepath.Join(params.DataDir, walletDbName)
 	db, err := wallet.CreateDB(params.DbDriver, dbPath)
 	if err != nil {
+		markWalletClosed(openDir)
 		return nil, fmt.Errorf("CreateDB error: %w", err)
 	}
 
@@ -258,6 +382,7 @@ func CreateWatchOnlyWallet(ctx context.Context, extendedPubKey string, params Cr
 			// deleting anything won't destroy a wallet in use. Attempt to
 			// remove any wallet remnants.
 			_ = os.Remove(params.DataDir)
+			markWalletClosed(openDir)
 		}
 	}()
 
@@ -268,22 +393,36 @@ func CreateWatchOnlyWallet(ctx context.Context, extendedPubKey string, params Cr
 	}
 
 	// Open the newly-created wallet.
-	w, err := wallet.Open(ctx, newWalletConfig(db, chainParams))
+	w, err := wallet.Open(ctx, newWalletConfig(db, chainParams, wd.Config))
 	if err != nil {
 		return nil, fmt.Errorf("wallet.Open error: %w", err)
 	}
 
+	if params.BirthdayHeight != nil || !params.Birthday.IsZero() {
+		birthState := newBirthState(params.Birthday, params.BirthdayHeight, chainParams)
+		if err := w.SetBirthState(ctx, birthState); err != nil {
+			return nil, fmt.Errorf("wallet.SetBirthState error: %w", err)
+		}
+	}
+
 	bailOnWallet = false
-	return &Wallet{
+	events := newEventQueue(defaultEventQueueSize)
+	wal := &Wallet{
 		dir:         params.DataDir,
 		dbDriver:    params.DbDriver,
 		chainParams: chainParams,
 		log:         params.Logger,
+		openDir:     openDir,
 		metaData:    wd,
 		db:          db,
 		mainWallet:  w,
 		syncHelper:  &syncHelper{log: params.Logger},
-	}, nil
+		progress:    syncProgress{events: events},
+		events:      events,
+		proxy:       params.Proxy,
+	}
+	wal.startTxNotifications()
+	return wal, nil
 }
 
 // LoadWallet loads a previously created SPV wallet. The wallet must be opened
@@ -300,11 +439,38 @@ func LoadWallet(ctx context.Context, params OpenWalletParams) (*Wallet, error) {
 		return nil, fmt.Errorf("error parsing chain params: %w", err)
 	}
 
-	wd, err := retrieveWalletData(params.DataDir)
+	if err := params.WalletConfig.validate(); err != nil {
+		return nil, fmt.Errorf("invalid wallet config: %w", err)
+	}
+
+	if err := params.Proxy.validate(); err != nil {
+		return nil, fmt.Errorf("invalid proxy config: %w", err)
+	}
+
+	wd, err := retrieveWalletData(params.DataDir, params.Logger)
+	if err != nil {
+		return nil, err
+	}
+
+	migrated, err := migrateWalletData(wd)
 	if err != nil {
 		return nil, err
 	}
 
+	// Apply the provided settings over the saved ones.
+	if params.WalletConfig != nil {
+		wd.Config = wd.Config.merge(params.WalletConfig)
+		if err := wd.Config.validate(); err != nil {
+			return nil, fmt.Errorf("invalid wallet config: %w", err)
+		}
+	}
+	if migrated || params.WalletConfig != nil {
+		if err := saveWalletData(wd, params.DataDir); err != nil {
+			return nil, fmt.Errorf("saveWalletData error: %v", err)
+		}
+	}
+
+	events := newEventQueue(defaultEventQueueSize)
 	return &Wallet{
 		dir:         params.DataDir,
 		dbDriver:    params.DbDriver,
@@ -312,5 +478,8 @@ func LoadWallet(ctx context.Context, params OpenWalletParams) (*Wallet, error) {
 		log:         params.Logger,
 		metaData:    wd,
 		syncHelper:  &syncHelper{log: params.Logger},
+		progress:    syncProgress{events: events},
+		events:      events,
+		proxy:       params.Proxy,
 	}, nil
 }
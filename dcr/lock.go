@@ -0,0 +1,146 @@
+package dcr
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// unlockSession is a period started by UnlockFor during which the wallet stays
+// unlocked.
+type unlockSession struct {
+	// until is when the session ends. Zero if it only ends with Lock.
+	until time.Time
+	timer *time.Timer
+}
+
+// LockState describes whether the wallet can sign.
+type LockState struct {
+	Locked bool
+	// Session is true if the wallet was unlocked with UnlockFor and is not
+	// relocked after signing.
+	Session bool
+	// Until is when the session ends. Zero if there is no session or it
+	// only ends with Lock.
+	Until time.Time
+}
+
+// UnlockFor unlocks the wallet with the private passphrase for duration, or
+// until Lock is called if duration is zero. The wallet is not relocked after
+// signing while the session is active. Unlocking again replaces the session.
+func (w *Wallet) UnlockFor(ctx context.Context, passphrase []byte, duration time.Duration) error {
+	if duration < 0 {
+		return errors.New("unlock duration cannot be negative")
+	}
+	w.lockMtx.Lock()
+	defer w.lockMtx.Unlock()
+	if err := w.Unlock(ctx, passphrase, nil); err != nil {
+		// A wrong passphrase locks the main wallet.
+		if w.locked() {
+			w.endSession()
+		}
+		return err
+	}
+	w.endSession()
+	s := new(unlockSession)
+	if duration > 0 {
+		s.until = time.Now().Add(duration)
+		s.timer = time.AfterFunc(duration, func() {
+			w.lockMtx.Lock()
+			defer w.lockMtx.Unlock()
+			if w.session != s {
+				return
+			}
+			w.session = nil
+			// Signing in progress locks the wallet once done.
+			if w.nSigning == 0 {
+				w.lock()
+			}
+			w.log.Info("Unlock session expired. Wallet locked.")
+		})
+	}
+	w.session = s
+	return nil
+}
+
+// Lock ends any unlock session, locks the wallet and removes any private keys
+// held in memory.
+func (w *Wallet) Lock() {
+	w.lockMtx.Lock()
+	defer w.lockMtx.Unlock()
+	w.endSession()
+	w.lock()
+}
+
+// LockState returns whether the wallet is locked and the unlock session, if
+// any.
+func (w *Wallet) LockState() LockState {
+	w.lockMtx.Lock()
+	defer w.lockMtx.Unlock()
+	state := LockState{Locked: w.locked()}
+	if w.session != nil {
+		state.Session = true
+		state.Until = w.session.until
+	}
+	return state
+}
+
+// UnlockForSigning unlocks the wallet for a signing operation and returns a
+// function that must be called when it is done. The wallet is locked again
+// when all signing operations are done unless an unlock session is active.
+// The passphrase may be empty during a session.
+func (w *Wallet) UnlockForSigning(ctx context.Context, passphrase []byte) (done func(), err error) {
+	w.lockMtx.Lock()
+	defer w.lockMtx.Unlock()
+	if w.session == nil || len(passphrase) != 0 {
+		if err := w.Unlock(ctx, passphrase, nil); err != nil {
+			if w.locked() {
+				w.endSession()
+			}
+			return nil, err
+		}
+	}
+	w.nSigning++
+	return func() {
+		w.lockMtx.Lock()
+		defer w.lockMtx.Unlock()
+		w.nSigning--
+		if w.nSigning == 0 && w.session == nil {
+			w.lock()
+		}
+	}, nil
+}
+
+// endSession stops the unlock session. The lockMtx MUST be held.
+func (w *Wallet) endSession() {
+	if w.session == nil {
+		return
+	}
+	if w.session.timer != nil {
+		w.session.timer.Stop()
+	}
+	w.session = nil
+}
+
+// lock locks the main wallet and removes the signing key of an upgraded
+// watching only wallet.
+func (w *Wallet) lock() {
+	w.lockSeedSigner(nil)
+	if w.mainWallet != nil {
+		w.mainWallet.Lock()
+	}
+}
+
+// locked returns whether the wallet is unable to sign. A wallet that is not
+// open is locked.
+func (w *Wallet) locked() bool {
+	if w.mainWallet == nil {
+		return true
+	}
+	if w.isUpgradedWatchOnly() {
+		w.signKeyMtx.Lock()
+		defer w.signKeyMtx.Unlock()
+		return w.signKey == nil
+	}
+	return w.mainWallet.Locked()
+}
@@ -0,0 +1,60 @@
+package dcr
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	dexmnemonic "decred.org/dcrdex/client/mnemonic"
+	"github.com/decred/libwallet/mnemonic"
+)
+
+// ParseMnemonic detects the type of the mnemonic from its word count and
+// decodes it. The language is detected for twelve and twenty four word
+// mnemonics and is English for the others. The birthday is only returned for
+// fifteen word mnemonics, which encode it.
+func ParseMnemonic(words string) (seed []byte, seedType SeedType, lang mnemonic.Language, birthday *time.Time, err error) {
+	words = strings.Join(strings.Fields(strings.ToLower(words)), " ")
+	nWords := len(strings.Fields(words))
+	switch nWords {
+	case 15:
+		var bday time.Time
+		seed, bday, err = dexmnemonic.DecodeMnemonic(words)
+		seedType, birthday = STFifteenWords, &bday
+	case 12:
+		seed, lang, err = decodeBIP39Mnemonic(words)
+		seedType = STTwelveWords
+	case 24:
+		seed, lang, err = decodeBIP39Mnemonic(words)
+		seedType = STTwentyFourWords
+	case mnemonic.PGPSeedWords:
+		seed, err = mnemonic.DecodePGPMnemonic(words)
+		seedType = STThirtyThreeWords
+	default:
+		return nil, 0, 0, nil, fmt.Errorf("unknown mnemonic format. expected 12, 15, 24, or 33 words, got %d", nWords)
+	}
+	if err != nil {
+		return nil, 0, 0, nil, fmt.Errorf("unable to decode mnemonic: %w", err)
+	}
+	return seed, seedType, lang, birthday, nil
+}
+
+// decodeBIP39Mnemonic decodes a BIP0039 mnemonic and detects its language.
+// Wallet seeds are derived from the words of the mnemonic in its language, so
+// the language must be kept to restore the same wallet.
+func decodeBIP39Mnemonic(words string) ([]byte, mnemonic.Language, error) {
+	lang, err := mnemonic.DetectLanguage(words)
+	if err != nil {
+		return nil, 0, err
+	}
+	seed, err := mnemonic.DecodeMnemonic(words)
+	if err != nil {
+		return nil, 0, err
+	}
+	return seed, lang, nil
+}
+
+// bip39Words returns the BIP0039 mnemonic of seed in lang.
+func bip39Words(seed []byte, lang mnemonic.Language) (string, error) {
+	return mnemonic.GenerateMnemonic(seed, mnemonic.WithLanguage(lang))
+}
@@ -3,6 +3,7 @@ package dcr
 import (
 	"time"
 
+	"github.com/decred/libwallet/mnemonic"
 	"github.com/decred/slog"
 )
 
@@ -12,21 +13,65 @@ type OpenWalletParams struct {
 	DataDir  string
 	DbDriver string
 	Logger   slog.Logger
+	// WalletConfig holds custom wallet settings. When loading a wallet, its
+	// set fields are applied over the settings saved with the wallet and
+	// the others keep their saved values.
+	WalletConfig *WalletConfig
+	// Proxy routes the wallet's peer connections and HTTP requests through
+	// a SOCKS5 proxy if set.
+	Proxy *ProxyConfig
 }
 
 // CreateWalletParams are the parameters for creating a wallet.
 type CreateWalletParams struct {
 	OpenWalletParams
-	Pass     []byte
-	Birthday time.Time
+	Pass []byte
+	// Birthday and BirthdayHeight set where watching only wallets start
+	// scanning for transactions. BirthdayHeight is used instead of Birthday
+	// if set. Watching only wallets scan from the genesis block if neither
+	// are set. New wallets are born now and recovered wallets use the
+	// RecoveryCfg birthday.
+	Birthday       time.Time
+	BirthdayHeight *uint32
+	// Entropy is optional user entropy mixed with system randomness when
+	// a new seed is generated. It cannot be used with a recovery.
+	Entropy *UserEntropy
+	// Language, if set, gives a new wallet a twenty four word BIP0039
+	// mnemonic in the language instead of the default fifteen word
+	// mnemonic, which is only in English. It is ignored for a recovery.
+	Language *mnemonic.Language
+}
+
+// AdoptWalletParams are the parameters for adopting a wallet.
+type AdoptWalletParams struct {
+	OpenWalletParams
+	// PubPass is the public passphrase of the wallet database, if it has
+	// one. The default dcrwallet public passphrase is tried if empty.
+	PubPass []byte
 }
 
 // RecoveryCfg is the information used to recover a wallet.
 type RecoveryCfg struct {
-	Seed                 []byte
-	SeedPass             []byte
-	SeedType             SeedType
-	Birthday             time.Time
+	Seed     []byte
+	SeedPass []byte
+	SeedType SeedType
+	// Language is the language of twelve and twenty four word mnemonics.
+	// BIP0039 applies the seed pass to the words in this language, so a
+	// different language creates a different wallet.
+	Language mnemonic.Language
+	Birthday time.Time
+	// BirthdayHeight is the height of the block to start scanning from.
+	// If set, it is used instead of Birthday to find the first block.
+	BirthdayHeight *uint32
+	// EstimateBirthday is set to find the birthday of a seed without one
+	// from the earliest block paying its first addresses. The estimate is
+	// made after the first sync, replacing Birthday and BirthdayHeight.
+	// Fifteen word seeds have a birthday and cannot use it.
+	EstimateBirthday bool
+	// SeedShares are SLIP-0039 share mnemonics, such as those from
+	// Wallet.ExportSeedShares, to recover the wallet from. If set, SeedPass
+	// is the SLIP-0039 passphrase and Seed and SeedType are ignored.
+	SeedShares           []string
 	UseLocalSeed         bool
 	NumExternalAddresses uint32
 	NumInternalAddresses uint32
@@ -0,0 +1,372 @@
+package dcr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// PeerInfo describes a peer the wallet is connected to.
+type PeerInfo struct {
+	Addr           string `json:"addr"`
+	Version        uint32 `json:"version"`
+	UserAgent      string `json:"useragent"`
+	StartingHeight int32  `json:"startingheight"`
+	LastHeight     int32  `json:"lastheight"`
+	BytesSent      uint64 `json:"bytessent"`
+	BytesReceived  uint64 `json:"bytesreceived"`
+	// LatencyMs is the time it took to connect to the peer.
+	LatencyMs int64 `json:"latencyms"`
+	// Persistent is true for peers added with AddPeer or passed to
+	// StartSync.
+	Persistent bool `json:"persistent"`
+}
+
+// peerManager holds the connections of the running sync and the peers it
+// was started with.
+type peerManager struct {
+	mtx sync.Mutex
+	// conns are the open peer connections by the address dialed.
+	conns map[string]*countingConn
+	// connectPeers are the peers passed to StartSync.
+	connectPeers []string
+	// cancelRun stops the current syncer so that sync is restarted with
+	// a changed set of persistent peers.
+	cancelRun context.CancelFunc
+}
+
+// countingConn is a peer connection that counts the bytes sent and received.
+type countingConn struct {
+	net.Conn
+	addr    string
+	latency time.Duration
+	sent    atomic.Uint64
+	recv    atomic.Uint64
+	onClose func(*countingConn)
+	once    sync.Once
+}
+
+func (c *countingConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	c.recv.Add(uint64(n))
+	return n, err
+}
+
+func (c *countingConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	c.sent.Add(uint64(n))
+	return n, err
+}
+
+func (c *countingConn) Close() error {
+	c.once.Do(func() {
+		if c.onClose != nil {
+			c.onClose(c)
+		}
+	})
+	return c.Conn.Close()
+}
+
+// track wraps conn to count its bytes until it is closed.
+func (pm *peerManager) track(addr string, conn net.Conn, latency time.Duration) *countingConn {
+	c := &countingConn{
+		Conn:    conn,
+		addr:    addr,
+		latency: latency,
+		onClose: pm.untrack,
+	}
+	pm.mtx.Lock()
+	defer pm.mtx.Unlock()
+	if pm.conns == nil {
+		pm.conns = make(map[string]*countingConn)
+	}
+	pm.conns[addr] = c
+	return c
+}
+
+func (pm *peerManager) untrack(c *countingConn) {
+	pm.mtx.Lock()
+	defer pm.mtx.Unlock()
+	if pm.conns[c.addr] == c {
+		delete(pm.conns, c.addr)
+	}
+}
+
+// setConnectPeers sets the peers passed to StartSync.
+func (pm *peerManager) setConnectPeers(peers []string) {
+	pm.mtx.Lock()
+	defer pm.mtx.Unlock()
+	pm.connectPeers = peers
+}
+
+// setCancelRun sets the function that stops the current syncer.
+func (pm *peerManager) setCancelRun(cancelRun context.CancelFunc) {
+	pm.mtx.Lock()
+	defer pm.mtx.Unlock()
+	pm.cancelRun = cancelRun
+}
+
+// restart stops the current syncer, if any, so that sync is restarted.
+func (pm *peerManager) restart() {
+	pm.mtx.Lock()
+	defer pm.mtx.Unlock()
+	if pm.cancelRun != nil {
+		pm.cancelRun()
+	}
+}
+
+// normalizePeerAddr returns addr as host:port, adding defaultPort if addr has
+// no port.
+func normalizePeerAddr(addr, defaultPort string) (string, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		host, port = addr, defaultPort
+	}
+	if host == "" {
+		return "", fmt.Errorf("invalid peer address %q", addr)
+	}
+	if _, err := strconv.ParseUint(port, 10, 16); err != nil {
+		return "", fmt.Errorf("invalid port in peer address %q", addr)
+	}
+	return net.JoinHostPort(peerHost(host), port), nil
+}
+
+// peerHost returns the host of a peer address used for bans. IP addresses are
+// returned in their canonical form.
+func peerHost(addr string) string {
+	host := addr
+	if h, _, err := net.SplitHostPort(addr); err == nil {
+		host = h
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return ip.String()
+	}
+	return host
+}
+
+// dialPeer dials peer and seeder connections for sync through the proxy of the
+// wallet, if any. Banned hosts are refused and peer connections are tracked
+// for Peers.
+func (w *Wallet) dialPeer(ctx context.Context, network, addr string) (net.Conn, error) {
+	if w.isBanned(addr, time.Now()) {
+		return nil, fmt.Errorf("peer %s is banned", addr)
+	}
+	start := time.Now()
+	conn, err := w.dial(ctx, network, addr)
+	if err != nil {
+		return nil, err
+	}
+	return w.peers.track(addr, conn, time.Since(start)), nil
+}
+
+// isBanned returns true if the host of addr is banned at now.
+func (w *Wallet) isBanned(addr string, now time.Time) bool {
+	w.seedMtx.Lock()
+	defer w.seedMtx.Unlock()
+	until, ok := w.metaData.BannedPeers[peerHost(addr)]
+	return ok && now.Unix() < until
+}
+
+// syncPeers returns the peers to sync with, which are the peers passed to
+// StartSync and the persistent peers of the wallet. Sync uses the network's
+// seeders if there are none.
+func (w *Wallet) syncPeers() []string {
+	w.seedMtx.Lock()
+	peers := slices.Clone(w.metaData.PersistentPeers)
+	w.seedMtx.Unlock()
+	w.peers.mtx.Lock()
+	defer w.peers.mtx.Unlock()
+	for _, addr := range w.peers.connectPeers {
+		if !slices.Contains(peers, addr) {
+			peers = append(peers, addr)
+		}
+	}
+	return peers
+}
+
+// updatePeerData saves the wallet data after changing a copy of it with f.
+// f must not modify the slices and maps of the wallet data it is passed.
+func (w *Wallet) updatePeerData(f func(wd *walletData)) error {
+	w.seedMtx.Lock()
+	defer w.seedMtx.Unlock()
+	updatedMetaData := *w.metaData
+	f(&updatedMetaData)
+	if err := saveWalletData(&updatedMetaData, w.dir); err != nil {
+		return err
+	}
+	*w.metaData = updatedMetaData
+	return nil
+}
+
+// Peers returns the peers the wallet is connected to, sorted by address.
+func (w *Wallet) Peers() []*PeerInfo {
+	w.syncerMtx.RLock()
+	syncer := w.syncer
+	w.syncerMtx.RUnlock()
+	if syncer == nil {
+		return nil
+	}
+
+	persistent := w.syncPeers()
+	w.peers.mtx.Lock()
+	defer w.peers.mtx.Unlock()
+	var peers []*PeerInfo
+	for addr, rp := range syncer.GetRemotePeers() {
+		info := &PeerInfo{
+			Addr:           addr,
+			Version:        rp.Pver(),
+			UserAgent:      rp.UA(),
+			StartingHeight: rp.InitialHeight(),
+			LastHeight:     rp.LastHeight(),
+			Persistent:     slices.Contains(persistent, addr),
+		}
+		if c := w.peers.conns[addr]; c != nil {
+			info.BytesSent = c.sent.Load()
+			info.BytesReceived = c.recv.Load()
+			info.LatencyMs = c.latency.Milliseconds()
+		}
+		peers = append(peers, info)
+	}
+	sort.Slice(peers, func(i, j int) bool {
+		return peers[i].Addr < peers[j].Addr
+	})
+	return peers
+}
+
+// AddPeer adds addr to the persistent peers of the wallet, which are kept
+// across restarts. The default port of the network is used if addr has none.
+// Once the wallet has persistent peers it only syncs with them and no longer
+// finds peers through the network's seeders. If the wallet is syncing, sync
+// is restarted to connect to the peer.
+func (w *Wallet) AddPeer(addr string) error {
+	addr, err := normalizePeerAddr(addr, w.chainParams.DefaultPort)
+	if err != nil {
+		return err
+	}
+	if err := w.checkPeerHost(addr); err != nil {
+		return err
+	}
+	w.seedMtx.Lock()
+	exists := slices.Contains(w.metaData.PersistentPeers, addr)
+	w.seedMtx.Unlock()
+	if exists {
+		return nil
+	}
+	if err := w.updatePeerData(func(wd *walletData) {
+		wd.PersistentPeers = append(slices.Clone(wd.PersistentPeers), addr)
+	}); err != nil {
+		return fmt.Errorf("unable to save persistent peers: %w", err)
+	}
+	w.log.Infof("Added persistent peer %s", addr)
+	w.peers.restart()
+	return nil
+}
+
+// DisconnectPeer disconnects from the peer at addr. If it is a persistent
+// peer, it is removed from the persistent peers and sync is restarted.
+// Otherwise the syncer may connect to the peer again later.
+func (w *Wallet) DisconnectPeer(addr string) error {
+	addr, err := normalizePeerAddr(addr, w.chainParams.DefaultPort)
+	if err != nil {
+		return err
+	}
+	removed, err := w.removePersistentPeer(addr)
+	if err != nil {
+		return err
+	}
+	disconnected := w.disconnectPeers(func(raddr string) bool {
+		return raddr == addr
+	}, errors.New("disconnected by user"))
+	if !removed && disconnected == 0 {
+		return fmt.Errorf("not connected to peer %s", addr)
+	}
+	if removed {
+		w.log.Infof("Removed persistent peer %s", addr)
+		w.peers.restart()
+	}
+	return nil
+}
+
+// removePersistentPeer removes addr from the persistent peers of the wallet
+// and the peers passed to StartSync. It returns true if addr was removed.
+func (w *Wallet) removePersistentPeer(addr string) (bool, error) {
+	w.peers.mtx.Lock()
+	i := slices.Index(w.peers.connectPeers, addr)
+	if i >= 0 {
+		w.peers.connectPeers = slices.Delete(slices.Clone(w.peers.connectPeers), i, i+1)
+	}
+	w.peers.mtx.Unlock()
+
+	w.seedMtx.Lock()
+	stored := slices.Contains(w.metaData.PersistentPeers, addr)
+	w.seedMtx.Unlock()
+	if !stored {
+		return i >= 0, nil
+	}
+	if err := w.updatePeerData(func(wd *walletData) {
+		wd.PersistentPeers = slices.DeleteFunc(slices.Clone(wd.PersistentPeers), func(p string) bool {
+			return p == addr
+		})
+	}); err != nil {
+		return false, fmt.Errorf("unable to save persistent peers: %w", err)
+	}
+	return true, nil
+}
+
+// BanPeer disconnects from all peers on the host of addr and refuses
+// connections to it for duration. Bans are kept across restarts.
+func (w *Wallet) BanPeer(addr string, duration time.Duration) error {
+	if duration <= 0 {
+		return errors.New("ban duration must be positive")
+	}
+	addr, err := normalizePeerAddr(addr, w.chainParams.DefaultPort)
+	if err != nil {
+		return err
+	}
+	host := peerHost(addr)
+	now := time.Now()
+	if err := w.updatePeerData(func(wd *walletData) {
+		banned := make(map[string]int64, len(wd.BannedPeers)+1)
+		for h, until := range wd.BannedPeers {
+			// Drop expired bans.
+			if now.Unix() < until {
+				banned[h] = until
+			}
+		}
+		banned[host] = now.Add(duration).Unix()
+		wd.BannedPeers = banned
+	}); err != nil {
+		return fmt.Errorf("unable to save banned peers: %w", err)
+	}
+	w.log.Infof("Banned peer %s for %v", host, duration)
+	w.disconnectPeers(func(raddr string) bool {
+		return peerHost(raddr) == host
+	}, fmt.Errorf("banned by user for %v", duration))
+	return nil
+}
+
+// disconnectPeers disconnects the connected peers whose addresses match and
+// returns the number disconnected.
+func (w *Wallet) disconnectPeers(match func(addr string) bool, reason error) int {
+	w.syncerMtx.RLock()
+	syncer := w.syncer
+	w.syncerMtx.RUnlock()
+	if syncer == nil {
+		return 0
+	}
+	var n int
+	for raddr, rp := range syncer.GetRemotePeers() {
+		if match(raddr) || match(rp.RemoteAddr().String()) {
+			rp.Disconnect(reason)
+			n++
+		}
+	}
+	return n
+}
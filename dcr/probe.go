@@ -0,0 +1,423 @@
+package dcr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"decred.org/dcrwallet/v5/p2p"
+	"decred.org/dcrwallet/v5/spv"
+	"decred.org/dcrwallet/v5/wallet"
+	"decred.org/dcrwallet/v5/wallet/udb"
+	"github.com/decred/dcrd/addrmgr/v3"
+	"github.com/decred/dcrd/chaincfg/chainhash"
+	"github.com/decred/dcrd/chaincfg/v3"
+	"github.com/decred/dcrd/hdkeychain/v3"
+	"github.com/decred/dcrd/txscript/v4/stdaddr"
+	"github.com/decred/dcrd/wire"
+	"github.com/decred/libwallet/mnemonic/slip39"
+	"github.com/decred/slog"
+)
+
+// probeSem is held while a seed is probed. The cached probe wallet can only be
+// open once.
+var probeSem = make(chan struct{}, 1)
+
+const (
+	// defaultProbeAddresses is the number of addresses probed on each
+	// branch if not set in the RecoveryCfg.
+	defaultProbeAddresses = 20
+	// probeBatchSize is the number of blocks whose filters are matched at
+	// once.
+	probeBatchSize = 2000
+)
+
+// SeedProbeResult is the on-chain activity found for the probed addresses of
+// one coin type of a seed.
+type SeedProbeResult struct {
+	CoinType uint32 `json:"cointype"`
+	// Active is true if a probed address received funds.
+	Active bool `json:"active"`
+	// FirstHeight and FirstTime are the height and unix time of the
+	// earliest block paying a probed address, or -1 and 0 if not active.
+	FirstHeight int32 `json:"firstheight"`
+	FirstTime   int64 `json:"firsttime"`
+}
+
+// probeAddresses returns the first nExt external and nInt internal P2PKH
+// addresses of the account key acctKey.
+func probeAddresses(acctKey *hdkeychain.ExtendedKey, nExt, nInt uint32, chainParams *chaincfg.Params) ([]stdaddr.Address, error) {
+	var addrs []stdaddr.Address
+	for branch, n := range []uint32{nExt, nInt} {
+		branchKey, err := acctKey.Child(uint32(branch))
+		if err != nil {
+			return nil, fmt.Errorf("unable to derive branch %d key: %w", branch, err)
+		}
+		for i := uint32(0); i < n; i++ {
+			key, err := branchKey.Child(i)
+			if errors.Is(err, hdkeychain.ErrInvalidChild) {
+				// The wallet skips invalid children too.
+				continue
+			}
+			if err != nil {
+				return nil, fmt.Errorf("unable to derive address key: %w", err)
+			}
+			pkHash := stdaddr.Hash160(key.SerializedPubKey())
+			addr, err := stdaddr.NewAddressPubKeyHashEcdsaSecp256k1V0(pkHash, chainParams)
+			if err != nil {
+				return nil, err
+			}
+			addrs = append(addrs, addr)
+		}
+	}
+	return addrs, nil
+}
+
+// ProbeSeed reports whether the first addresses of the seed in recovery have
+// received funds, for both the SLIP-0044 and legacy coin types, without
+// creating a wallet. The number of addresses probed on each branch is set by
+// the recovery's NumExternalAddresses and NumInternalAddresses, and blocks
+// before the recovery birthday are skipped.
+//
+// Block headers and filters are synced over SPV into a throwaway wallet, which
+// may take some minutes. It is created in a temporary directory that is removed
+// when probing finishes unless params.DataDir is set, in which case it is
+// cached there so that later probes only sync the blocks mined since. The cache
+// holds no keys of the probed seeds and is removed with RemoveSeedProbeCache or
+// DeleteWallet. Probes are run one at a time. Connections are made through
+// params.Proxy if set.
+func ProbeSeed(ctx context.Context, params OpenWalletParams, recovery *RecoveryCfg, connectPeers ...string) ([]*SeedProbeResult, error) {
+	chainParams, err := ParseChainParams(params.Net)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing chain params: %w", err)
+	}
+	if err := params.Proxy.validate(); err != nil {
+		return nil, fmt.Errorf("invalid proxy config: %w", err)
+	}
+	for _, peer := range connectPeers {
+		if err := params.Proxy.checkPeerHost(peer); err != nil {
+			return nil, err
+		}
+	}
+	if recovery == nil || recovery.UseLocalSeed {
+		return nil, errors.New("a seed or seed shares are required")
+	}
+	seed, seedPass, seedType := recovery.Seed, recovery.SeedPass, recovery.SeedType
+	if len(recovery.SeedShares) != 0 {
+		seed, err = slip39.CombineMnemonics(recovery.SeedShares, recovery.SeedPass)
+		if err != nil {
+			return nil, fmt.Errorf("unable to combine seed shares: %w", err)
+		}
+		seedPass, seedType = nil, STSeedShares
+	}
+	tweakedSeed, err := tweakSeed(seed, seedPass, seedType, recovery.Language)
+	if err != nil {
+		return nil, err
+	}
+
+	nExt, nInt := recovery.NumExternalAddresses, recovery.NumInternalAddresses
+	if nExt == 0 && nInt == 0 {
+		nExt, nInt = defaultProbeAddresses, defaultProbeAddresses
+	}
+	results, addrs, scripts, err := probeTargets(tweakedSeed, nExt, nInt, chainParams)
+	if err != nil {
+		return nil, err
+	}
+
+	select {
+	case probeSem <- struct{}{}:
+		defer func() { <-probeSem }()
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	var dir string
+	if params.DataDir == "" {
+		dir, err = os.MkdirTemp("", "libwallet-probe-")
+		if err != nil {
+			return nil, err
+		}
+		defer os.RemoveAll(dir)
+	} else {
+		dir = probeCacheDir(params.DataDir, chainParams)
+		if err := checkCreateDir(dir); err != nil {
+			return nil, err
+		}
+	}
+
+	log := params.Logger
+	if log == nil {
+		log = slog.Disabled
+	}
+	w, syncer, stop, err := startProbeSync(ctx, dir, chainParams, params.Proxy, log, connectPeers)
+	if err != nil {
+		return nil, err
+	}
+	defer stop()
+
+	if err := syncer.LoadTxFilter(ctx, true, addrs, nil); err != nil {
+		return nil, err
+	}
+	startHeight, err := probeStartHeight(ctx, w, recovery)
+	if err != nil {
+		return nil, err
+	}
+	found := 0
+	save := func(blockHash *chainhash.Hash, txs []*wire.MsgTx) error {
+		for _, tx := range txs {
+			for _, out := range tx.TxOut {
+				res := scripts[string(out.PkScript)]
+				if res == nil || res.Active {
+					continue
+				}
+				bi, err := w.BlockInfo(ctx, wallet.NewBlockIdentifierFromHash(blockHash))
+				if err != nil {
+					return err
+				}
+				res.Active = true
+				res.FirstHeight = bi.Height
+				res.FirstTime = bi.Timestamp
+				found++
+			}
+		}
+		if found == len(results) {
+			return errScanDone
+		}
+		return nil
+	}
+	if err := scanBlocks(ctx, w, syncer, startHeight, save); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// errScanDone is returned by a scanBlocks save function to end the scan.
+var errScanDone = errors.New("scan done")
+
+// scanBlocks matches the filters of the main chain blocks from startHeight
+// against the syncer's transaction filter and passes the transactions of
+// matching blocks to save in block order.
+func scanBlocks(ctx context.Context, w *wallet.Wallet, syncer *spv.Syncer, startHeight int32,
+	save func(*chainhash.Hash, []*wire.MsgTx) error) error {
+	_, tipHeight := w.MainChainTip(ctx)
+	for height := startHeight; height <= tipHeight; height += probeBatchSize {
+		hashes := make([]chainhash.Hash, 0, probeBatchSize)
+		for h := height; h < height+probeBatchSize && h <= tipHeight; h++ {
+			bi, err := w.BlockInfo(ctx, wallet.NewBlockIdentifierFromHeight(h))
+			if err != nil {
+				return err
+			}
+			hashes = append(hashes, bi.Hash)
+		}
+		err := syncer.Rescan(ctx, hashes, save)
+		if errors.Is(err, errScanDone) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("rescan error: %w", err)
+		}
+	}
+	return nil
+}
+
+// probeTargets returns a result for each coin type of the seed along with the
+// probed addresses and a map of their scripts to the coin type's result.
+func probeTargets(seed []byte, nExt, nInt uint32, chainParams *chaincfg.Params) ([]*SeedProbeResult,
+	[]stdaddr.Address, map[string]*SeedProbeResult, error) {
+	coinTypeLegacy, coinTypeSLIP0044, acctKeyLegacy, acctKeySLIP0044, err := udb.HDKeysFromSeed(seed, chainParams)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	defer func() {
+		coinTypeLegacy.Zero()
+		coinTypeSLIP0044.Zero()
+		acctKeyLegacy.Zero()
+		acctKeySLIP0044.Zero()
+	}()
+
+	var (
+		results []*SeedProbeResult
+		addrs   []stdaddr.Address
+		scripts = make(map[string]*SeedProbeResult)
+	)
+	acctKeys := map[uint32]*hdkeychain.ExtendedKey{
+		chainParams.SLIP0044CoinType: acctKeySLIP0044,
+		chainParams.LegacyCoinType:   acctKeyLegacy,
+	}
+	for _, coinType := range []uint32{chainParams.SLIP0044CoinType, chainParams.LegacyCoinType} {
+		acctKey := acctKeys[coinType]
+		if acctKey == nil {
+			continue // both coin types are the same
+		}
+		delete(acctKeys, coinType)
+		res := &SeedProbeResult{CoinType: coinType, FirstHeight: -1}
+		results = append(results, res)
+		acctAddrs, err := probeAddresses(acctKey.Neuter(), nExt, nInt, chainParams)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		for _, addr := range acctAddrs {
+			_, script := addr.PaymentScript()
+			scripts[string(script)] = res
+		}
+		addrs = append(addrs, acctAddrs...)
+	}
+	return results, addrs, scripts, nil
+}
+
+// startProbeSync opens the throwaway wallet cached in dir and syncs its headers
+// and filters over SPV through proxy, if not nil. The returned function stops
+// the sync and closes the wallet.
+func startProbeSync(ctx context.Context, dir string, chainParams *chaincfg.Params, proxy *ProxyConfig,
+	log slog.Logger, connectPeers []string) (*wallet.Wallet, *spv.Syncer, func(), error) {
+	w, db, err := openProbeWallet(ctx, dir, chainParams, log)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	addr := &net.TCPAddr{IP: net.ParseIP("::1"), Port: 0}
+	lp := p2p.NewLocalPeer(chainParams, addr, addrmgr.New(dir))
+	lp.SetDialFunc(func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return proxy.dial(ctx, log, network, addr)
+	})
+	syncer := spv.NewSyncer(w, lp)
+	if len(connectPeers) > 0 {
+		syncer.SetPersistentPeers(connectPeers)
+	}
+	syncer.DisableDiscoverAccounts()
+	syncedCh := make(chan struct{})
+	var syncedOnce sync.Once
+	syncer.SetNotifications(&spv.Notifications{
+		Synced: func(synced bool) {
+			if synced {
+				syncedOnce.Do(func() { close(syncedCh) })
+			}
+		},
+	})
+	w.SetNetworkBackend(syncer)
+
+	syncCtx, cancel := context.WithCancel(ctx)
+	runDone := make(chan struct{})
+	var runErr error
+	go func() {
+		runErr = syncer.Run(syncCtx)
+		close(runDone)
+	}()
+	stop := func() {
+		cancel()
+		<-runDone
+		db.Close()
+	}
+
+	select {
+	case <-syncedCh:
+		return w, syncer, stop, nil
+	case <-runDone:
+		stop()
+		return nil, nil, nil, fmt.Errorf("sync ended: %w", runErr)
+	case <-ctx.Done():
+		stop()
+		return nil, nil, nil, ctx.Err()
+	}
+}
+
+// probeStartHeight returns the height of the recovery birthday block in the
+// synced chain.
+func probeStartHeight(ctx context.Context, w *wallet.Wallet, recovery *RecoveryCfg) (int32, error) {
+	_, tipHeight := w.MainChainTip(ctx)
+	if recovery.BirthdayHeight != nil {
+		return min(int32(*recovery.BirthdayHeight), tipHeight), nil
+	}
+	if recovery.Birthday.IsZero() {
+		return 0, nil
+	}
+	// Allow for differences between block and wall clock times like the
+	// wallet birthday.
+	cutoff := recovery.Birthday.Add(time.Hour * -24).Unix()
+	var err error
+	h := sort.Search(int(tipHeight)+1, func(h int) bool {
+		if err != nil {
+			return true
+		}
+		var bi *wallet.BlockInfo
+		bi, err = w.BlockInfo(ctx, wallet.NewBlockIdentifierFromHeight(int32(h)))
+		return err == nil && bi.Timestamp >= cutoff
+	})
+	if err != nil {
+		return 0, err
+	}
+	// Start at the last block before the birthday.
+	return max(int32(h)-1, 0), nil
+}
+
+// probeCacheDir returns the directory of the probe wallet cached in dataDir.
+func probeCacheDir(dataDir string, chainParams *chaincfg.Params) string {
+	return filepath.Join(dataDir, "probe-"+chainParams.Name)
+}
+
+// RemoveSeedProbeCache removes the headers and filters cached in dataDir by
+// ProbeSeed for net.
+func RemoveSeedProbeCache(dataDir, net string) error {
+	chainParams, err := ParseChainParams(net)
+	if err != nil {
+		return fmt.Errorf("error parsing chain params: %w", err)
+	}
+	probeSem <- struct{}{}
+	defer func() { <-probeSem }()
+	return os.RemoveAll(probeCacheDir(dataDir, chainParams))
+}
+
+// openProbeWallet opens the throwaway wallet in dir. A new one with a random
+// seed is created if there is none or the existing one cannot be opened, such
+// as when creating it was interrupted.
+func openProbeWallet(ctx context.Context, dir string, chainParams *chaincfg.Params, log slog.Logger) (*wallet.Wallet, wallet.DB, error) {
+	dbPath := filepath.Join(dir, walletDbName)
+	if exists, err := fileExists(dbPath); err != nil {
+		return nil, nil, err
+	} else if exists {
+		db, err := wallet.OpenDB("bdb", dbPath)
+		if err == nil {
+			var w *wallet.Wallet
+			w, err = wallet.Open(ctx, newWalletConfig(db, chainParams, nil))
+			if err == nil {
+				return w, db, nil
+			}
+			db.Close()
+		}
+		log.Warnf("Replacing the seed probe cache that cannot be opened: %v", err)
+		if err := os.Remove(dbPath); err != nil {
+			return nil, nil, fmt.Errorf("unable to remove the seed probe cache: %w", err)
+		}
+	}
+
+	db, err := wallet.CreateDB("bdb", dbPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("CreateDB error: %w", err)
+	}
+	fail := func(err error) (*wallet.Wallet, wallet.DB, error) {
+		db.Close()
+		os.Remove(dbPath)
+		return nil, nil, err
+	}
+	seed, err := hdkeychain.GenerateSeed(hdkeychain.RecommendedSeedLen)
+	if err != nil {
+		return fail(err)
+	}
+	if err := wallet.Create(ctx, db, nil, []byte("probe"), seed, chainParams); err != nil {
+		return fail(fmt.Errorf("wallet.Create error: %w", err))
+	}
+	w, err := wallet.Open(ctx, newWalletConfig(db, chainParams, nil))
+	if err != nil {
+		return fail(fmt.Errorf("wallet.Open error: %w", err))
+	}
+	// The throwaway wallet has no history to scan for.
+	if err := w.SetBirthState(ctx, &udb.BirthdayState{Time: time.Now(), SetFromTime: true}); err != nil {
+		return fail(fmt.Errorf("wallet.SetBirthState error: %w", err))
+	}
+	return w, db, nil
+}
@@ -0,0 +1,109 @@
+package dcr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/decred/go-socks/socks"
+	"github.com/decred/slog"
+)
+
+// ProxyConfig routes the network connections of a wallet, both to peers and
+// for HTTP requests, through a SOCKS5 proxy such as Tor.
+type ProxyConfig struct {
+	// Addr is the host:port of the SOCKS5 proxy.
+	Addr     string `json:"addr"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+	// TorIsolation uses random credentials for every connection so that
+	// Tor uses a separate circuit for each. Username and Password are
+	// ignored if set.
+	TorIsolation bool `json:"torisolation"`
+	// NoClearnetFallback refuses connections when the proxy cannot be
+	// reached instead of connecting directly. Peers must then be IP
+	// addresses because peer host names are resolved without the proxy.
+	NoClearnetFallback bool `json:"noclearnetfallback"`
+}
+
+// validate checks that the proxy has an address.
+func (cfg *ProxyConfig) validate() error {
+	if cfg == nil {
+		return nil
+	}
+	if _, _, err := net.SplitHostPort(cfg.Addr); err != nil {
+		return fmt.Errorf("invalid proxy address %q: %v", cfg.Addr, err)
+	}
+	return nil
+}
+
+// dial connects to addr through the proxy of the wallet, if any. If the proxy
+// cannot be reached, addr is dialed directly unless clearnet fallback is
+// disabled.
+func (w *Wallet) dial(ctx context.Context, network, addr string) (net.Conn, error) {
+	return w.proxy.dial(ctx, w.log, network, addr)
+}
+
+// dial connects to addr through the proxy, or directly if cfg is nil. log is
+// warned when falling back to a direct connection.
+func (cfg *ProxyConfig) dial(ctx context.Context, log slog.Logger, network, addr string) (net.Conn, error) {
+	var d net.Dialer
+	if cfg == nil {
+		return d.DialContext(ctx, network, addr)
+	}
+	proxy := &socks.Proxy{
+		Addr:         cfg.Addr,
+		Username:     cfg.Username,
+		Password:     cfg.Password,
+		TorIsolation: cfg.TorIsolation,
+	}
+	conn, err := proxy.DialContext(ctx, network, addr)
+	if err == nil {
+		return conn, nil
+	}
+	// Only fall back if the proxy itself could not be reached. Errors
+	// from the proxy, such as an unreachable addr, are returned.
+	var opErr *net.OpError
+	if cfg.NoClearnetFallback || !errors.As(err, &opErr) || opErr.Op != "dial" || ctx.Err() != nil {
+		return nil, fmt.Errorf("unable to connect to %s through proxy %s: %w", addr, cfg.Addr, err)
+	}
+	log.Warnf("Unable to reach proxy %s, connecting to %s directly: %v", cfg.Addr, addr, err)
+	return d.DialContext(ctx, network, addr)
+}
+
+// httpClient returns the client for HTTP requests, which uses the proxy of the
+// wallet, if any. Connections are not kept open because a new client is
+// returned for every request.
+func (w *Wallet) httpClient() *http.Client {
+	if w.proxy == nil {
+		return http.DefaultClient
+	}
+	return &http.Client{
+		Transport: &http.Transport{
+			DialContext:       w.dial,
+			ForceAttemptHTTP2: true,
+			DisableKeepAlives: true,
+		},
+	}
+}
+
+// checkPeerHost returns an error if addr is a host name and the wallet must
+// not make connections outside of its proxy.
+func (w *Wallet) checkPeerHost(addr string) error {
+	return w.proxy.checkPeerHost(addr)
+}
+
+// checkPeerHost returns an error if addr is a host name and clearnet fallback
+// is disabled. dcrwallet resolves peer host names with the system resolver
+// before dialing them.
+func (cfg *ProxyConfig) checkPeerHost(addr string) error {
+	if cfg == nil || !cfg.NoClearnetFallback {
+		return nil
+	}
+	if net.ParseIP(peerHost(addr)) == nil {
+		return fmt.Errorf("peer %s must be an IP address when clearnet fallback is disabled", addr)
+	}
+	return nil
+}
@@ -0,0 +1,205 @@
+package dcr
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	dexmnemonic "decred.org/dcrdex/client/mnemonic"
+	"github.com/decred/libwallet/mnemonic"
+	"github.com/decred/libwallet/mnemonic/slip39"
+)
+
+// ErrMnemonicMismatch is returned when a mnemonic or seed shares do not match
+// the wallet's stored seed.
+var ErrMnemonicMismatch = errors.New("mnemonic does not match the wallet seed")
+
+// storedSeed decrypts the stored seed with the wallet pass. The seedMtx MUST be
+// held.
+func (w *Wallet) storedSeed(pass []byte) ([]byte, error) {
+	if w.metaData.EncryptedSeedHex == "" {
+		return nil, errors.New("encrypted seed does not exist")
+	}
+	encryptedSeed, err := hex.DecodeString(w.metaData.EncryptedSeedHex)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode encrypted hex seed: %v", err)
+	}
+	return DecryptData(encryptedSeed, pass)
+}
+
+// storedSeedPass decrypts the stored seed pass with the wallet pass. Nil is
+// returned if there is no seed pass. The seedMtx MUST be held.
+func (w *Wallet) storedSeedPass(pass []byte) ([]byte, error) {
+	if w.metaData.EncryptedSeedPassHex == "" {
+		return nil, nil
+	}
+	encSeedPass, err := hex.DecodeString(w.metaData.EncryptedSeedPassHex)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode encrypted seed pass: %v", err)
+	}
+	seedPass, err := DecryptData(encSeedPass, pass)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decrypt wallet seed pass: %v", err)
+	}
+	return seedPass, nil
+}
+
+// VerifySeed checks that the mnemonic, as written down by the user, matches the
+// stored seed. The wallet pass is needed to decrypt the stored seed. For
+// fifteen word mnemonics, the encoded birthday may be earlier than the wallet's
+// but not later, since restoring from it could then miss transactions. The
+// seed is recorded as backed up if it matches.
+func (w *Wallet) VerifySeed(words string, pass []byte) error {
+	w.seedMtx.Lock()
+	defer w.seedMtx.Unlock()
+
+	seed, err := w.storedSeed(pass)
+	if err != nil {
+		return err
+	}
+
+	words = strings.Join(strings.Fields(strings.ToLower(words)), " ")
+	var userSeed []byte
+	switch w.metaData.SeedType {
+	case STFifteenWords:
+		var birthday time.Time
+		userSeed, birthday, err = dexmnemonic.DecodeMnemonic(words)
+		if err != nil {
+			return fmt.Errorf("unable to decode mnemonic: %w", err)
+		}
+		// Mnemonic birthdays are truncated to the day.
+		const secondsPerDay = 86400
+		if birthday.Unix()/secondsPerDay > w.metaData.Birthday/secondsPerDay {
+			return fmt.Errorf("%w: mnemonic birthday %s is after the wallet birthday %s",
+				ErrMnemonicMismatch, birthday.UTC().Format(time.DateOnly),
+				time.Unix(w.metaData.Birthday, 0).UTC().Format(time.DateOnly))
+		}
+	case STTwelveWords, STTwentyFourWords:
+		var lang mnemonic.Language
+		userSeed, lang, err = decodeBIP39Mnemonic(words)
+		if err != nil {
+			return fmt.Errorf("unable to decode mnemonic: %w", err)
+		}
+		// The same seed in another language creates another wallet.
+		if lang != w.metaData.Language {
+			return fmt.Errorf("%w: mnemonic is in %s, not %s", ErrMnemonicMismatch, lang, w.metaData.Language)
+		}
+	case STThirtyThreeWords:
+		userSeed, err = mnemonic.DecodePGPMnemonic(words)
+		if err != nil {
+			return fmt.Errorf("unable to decode mnemonic: %w", err)
+		}
+	case STSeedShares:
+		return errors.New("wallet was recovered from seed shares and has no mnemonic, verify the shares with VerifySeedShares")
+	default:
+		return fmt.Errorf("unknown seed type %d", w.metaData.SeedType)
+	}
+	if !bytes.Equal(userSeed, seed) {
+		return ErrMnemonicMismatch
+	}
+	return w.setSeedBackedUp()
+}
+
+// VerifySeedShares checks that the SLIP-0039 share mnemonics, as written down
+// by the user, recover the wallet. sharesPass is the SLIP-0039 passphrase of
+// the shares, which is empty for shares from ExportSeedShares. Shares work for
+// every seed type, so this is the only way to verify the seed of a wallet
+// recovered from seed shares. The wallet pass is needed to decrypt the stored
+// seed. The seed is recorded as backed up if the shares match.
+func (w *Wallet) VerifySeedShares(shares []string, sharesPass, pass []byte) error {
+	w.seedMtx.Lock()
+	defer w.seedMtx.Unlock()
+
+	seed, err := w.storedSeed(pass)
+	if err != nil {
+		return err
+	}
+	seedPass, err := w.storedSeedPass(pass)
+	if err != nil {
+		return err
+	}
+	masterSeed, err := tweakSeed(seed, seedPass, w.metaData.SeedType, w.metaData.Language)
+	if err != nil {
+		return err
+	}
+
+	normalized := make([]string, len(shares))
+	for i, share := range shares {
+		normalized[i] = strings.Join(strings.Fields(strings.ToLower(share)), " ")
+	}
+	userSeed, err := slip39.CombineMnemonics(normalized, sharesPass)
+	if err != nil {
+		return fmt.Errorf("unable to combine seed shares: %w", err)
+	}
+	if !bytes.Equal(userSeed, masterSeed) {
+		return ErrMnemonicMismatch
+	}
+	return w.setSeedBackedUp()
+}
+
+// setSeedBackedUp records that the user verified their seed backup. The
+// seedMtx MUST be held.
+func (w *Wallet) setSeedBackedUp() error {
+	if w.metaData.SeedBackedUp {
+		return nil
+	}
+	updatedMetaData := *w.metaData
+	updatedMetaData.SeedBackedUp = true
+	if err := saveWalletData(&updatedMetaData, w.dir); err != nil {
+		return err
+	}
+	w.metaData.SeedBackedUp = true
+	return nil
+}
+
+// SeedBackedUp returns whether the user verified that they backed up the seed.
+func (w *Wallet) SeedBackedUp() bool {
+	w.seedMtx.Lock()
+	defer w.seedMtx.Unlock()
+	return w.metaData.SeedBackedUp
+}
+
+// HasStoredSeed returns whether the encrypted seed is stored with the wallet.
+func (w *Wallet) HasStoredSeed() bool {
+	w.seedMtx.Lock()
+	defer w.seedMtx.Unlock()
+	return w.metaData.EncryptedSeedHex != ""
+}
+
+// DeleteStoredSeed removes the encrypted seed and seed pass from the wallet
+// data. The seed must have been verified with VerifySeed or VerifySeedShares
+// first. The seed can no longer be shown or used to restore the wallet
+// database afterwards.
+func (w *Wallet) DeleteStoredSeed(pass []byte) error {
+	w.seedMtx.Lock()
+	defer w.seedMtx.Unlock()
+
+	if !w.metaData.SeedBackedUp {
+		return errors.New("seed must be verified before it is deleted")
+	}
+	// Upgraded watching only wallets sign with the seed.
+	if w.mainWallet != nil && w.mainWallet.WatchingOnly() {
+		return errors.New("watching only wallets need the seed to sign")
+	}
+	// Check the pass.
+	if _, err := w.storedSeed(pass); err != nil {
+		return err
+	}
+
+	updatedMetaData := *w.metaData
+	updatedMetaData.EncryptedSeedHex = ""
+	updatedMetaData.EncryptedSeedPassHex = ""
+	if err := saveWalletData(&updatedMetaData, w.dir); err != nil {
+		return err
+	}
+	// Save again to replace the backup, which still holds the seed.
+	if err := saveWalletData(&updatedMetaData, w.dir); err != nil {
+		return err
+	}
+	w.metaData.EncryptedSeedHex = ""
+	w.metaData.EncryptedSeedPassHex = ""
+	return nil
+}
@@ -0,0 +1,46 @@
+package dcr
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/decred/libwallet/mnemonic/slip39"
+)
+
+// ExportSeedShares splits the wallet's master seed into n SLIP-0039 share
+// mnemonics, any threshold of which recover the wallet with
+// RecoveryCfg.SeedShares. Fewer shares reveal nothing about the seed. The
+// master seed is the seed after any tweak or seed pass is applied, so the
+// shares work for every seed type. The wallet pass is needed to decrypt the
+// stored seed.
+func (w *Wallet) ExportSeedShares(pass []byte, threshold, n int) ([]string, error) {
+	if threshold < 1 || threshold > n {
+		return nil, fmt.Errorf("threshold must be between 1 and %d, got %d", n, threshold)
+	}
+
+	w.seedMtx.Lock()
+	defer w.seedMtx.Unlock()
+
+	seed, err := w.storedSeed(pass)
+	if err != nil {
+		return nil, err
+	}
+	seedPass, err := w.storedSeedPass(pass)
+	if err != nil {
+		return nil, err
+	}
+	masterSeed, err := tweakSeed(seed, seedPass, w.metaData.SeedType, w.metaData.Language)
+	if err != nil {
+		return nil, err
+	}
+
+	group := slip39.Group{MemberThreshold: threshold, MemberCount: n}
+	shares, err := slip39.SplitSecret(1, []slip39.Group{group}, masterSeed, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to split seed: %w", err)
+	}
+	if len(shares) != 1 {
+		return nil, errors.New("unexpected number of share groups")
+	}
+	return shares[0], nil
+}
@@ -2,7 +2,9 @@ package dcr
 
 import (
 	"context"
+	"math/rand"
 	"net"
+	"sync"
 	"time"
 
 	"decred.org/dcrwallet/v5/p2p"
@@ -13,8 +15,21 @@ import (
 
 // StartSync connects the wallet to the blockchain network via SPV and returns
 // immediately. The wallet stays connected in the background until the provided
-// ctx is canceled or either StopSync or CloseWallet is called.
+// ctx is canceled or either StopSync or CloseWallet is called. If there are
+// connectPeers or persistent peers added with AddPeer, the wallet only syncs
+// with those peers.
 func (w *Wallet) StartSync(ctx context.Context, ntfns *spv.Notifications, connectPeers ...string) error {
+	peers := make([]string, len(connectPeers))
+	for i, peer := range connectPeers {
+		peers[i] = peer
+		if addr, err := normalizePeerAddr(peer, w.chainParams.DefaultPort); err == nil {
+			peers[i] = addr
+		}
+		if err := w.checkPeerHost(peers[i]); err != nil {
+			return err
+		}
+	}
+
 	// Initialize the ctx to use for sync. Will error if sync was already
 	// started.
 	ctx, err := w.InitializeSyncContext(ctx)
@@ -27,6 +42,9 @@ func (w *Wallet) StartSync(ctx context.Context, ntfns *spv.Notifications, connec
 	addr := &net.TCPAddr{IP: net.ParseIP("::1"), Port: 0}
 	amgr := addrmgr.New(w.dir)
 	lp := p2p.NewLocalPeer(w.ChainParams(), addr, amgr)
+	lp.SetDialFunc(w.dialPeer)
+
+	w.peers.setConnectPeers(peers)
 
 	// We must create a new syncer for every attempt or we will get a
 	// closing closed channel panic when close(s.initialSyncDone) happens
@@ -35,10 +53,19 @@ func (w *Wallet) StartSync(ctx context.Context, ntfns *spv.Notifications, connec
 		w.syncerMtx.Lock()
 		defer w.syncerMtx.Unlock()
 		syncer := spv.NewSyncer(w.mainWallet, lp)
-		if len(connectPeers) > 0 {
-			syncer.SetPersistentPeers(connectPeers)
+		var peers []string
+		for _, peer := range w.syncPeers() {
+			if err := w.checkPeerHost(peer); err != nil {
+				w.log.Warnf("Skipping persistent peer: %v", err)
+				continue
+			}
+			peers = append(peers, peer)
+		}
+		if len(peers) > 0 {
+			syncer.SetPersistentPeers(peers)
 		}
-		syncer.SetNotifications(ntfns)
+		w.progress.begin(syncer)
+		syncer.SetNotifications(w.syncNotifications(ctx, syncer, ntfns))
 
 		// TODO: Set a birthday to sync from. I don't think dcrwallet allows
 		// this currently.
@@ -48,27 +75,58 @@ func (w *Wallet) StartSync(ctx context.Context, ntfns *spv.Notifications, connec
 		return syncer
 	}
 
+	w.seedMtx.Lock()
+	minDelay, maxDelay := w.metaData.Config.syncRetryDelays()
+	maxAttempts := 0
+	if w.metaData.Config != nil {
+		maxAttempts = w.metaData.Config.SyncMaxAttempts
+	}
+	w.seedMtx.Unlock()
+
 	// Start the syncer in a goroutine, monitor when the sync ctx is canceled
 	// and then disconnect the sync.
 	go func() {
+		defer func() {
+			w.syncerMtx.Lock()
+			defer w.syncerMtx.Unlock()
+			w.syncer = nil
+			w.SetNetworkBackend(nil)
+			w.SyncEnded(nil)
+			w.peers.setConnectPeers(nil)
+			w.peers.setCancelRun(nil)
+		}()
 		for {
+			// The syncer is run with its own ctx so that it can be
+			// restarted when persistent peers change.
+			runCtx, cancelRun := context.WithCancel(ctx)
+			w.peers.setCancelRun(cancelRun)
 			syncer := newSyncer()
-			err := syncer.Run(ctx)
+			err := syncer.Run(runCtx)
+			restarted := runCtx.Err() != nil
+			cancelRun()
 			if ctx.Err() != nil {
-				w.syncerMtx.Lock()
-				defer w.syncerMtx.Unlock()
 				// sync ctx canceled, quit syncing
-				w.syncer = nil
-				w.SetNetworkBackend(nil)
-				w.SyncEnded(nil)
+				w.progress.end(nil)
 				return
 			}
+			if restarted {
+				w.progress.end(nil)
+				w.log.Info("Restarting SPV synchronization with the changed peers")
+				continue
+			}
 
-			w.log.Errorf("SPV synchronization ended. Trying again in 10 seconds: %v", err)
+			failures := w.progress.end(err)
+			if maxAttempts > 0 && failures >= maxAttempts {
+				w.log.Errorf("SPV synchronization failed %d times. Giving up: %v", failures, err)
+				w.StopSync()
+				return
+			}
+			delay := syncRetryDelay(failures, minDelay, maxDelay)
+			w.log.Errorf("SPV synchronization ended. Trying again in %v: %v", delay.Round(time.Second), err)
 			select {
 			case <-ctx.Done():
 				return
-			case <-time.After(time.Second * 10):
+			case <-time.After(delay):
 			}
 		}
 	}()
@@ -76,6 +134,58 @@ func (w *Wallet) StartSync(ctx context.Context, ntfns *spv.Notifications, connec
 	return nil
 }
 
+// syncRetryDelay returns the delay before retrying sync after failures
+// consecutive failures. The delay doubles from minDelay with each failure up to
+// maxDelay, and a random part of up to half of it is taken off so that many
+// wallets do not reconnect at once. The delay is never less than minDelay.
+func syncRetryDelay(failures int, minDelay, maxDelay time.Duration) time.Duration {
+	delay := minDelay
+	for i := 1; i < failures && delay < maxDelay; i++ {
+		delay *= 2
+	}
+	delay = min(delay, maxDelay)
+	low := max(delay/2, minDelay)
+	if low >= delay {
+		return delay
+	}
+	return low + time.Duration(rand.Int63n(int64(delay-low)+1))
+}
+
+// syncNotifications returns the notifications for syncer, which also track
+// the sync progress. Once synced, the wallet data is updated if the coin type
+// was upgraded during sync, and the birthday of a recovered wallet is
+// estimated if requested.
+func (w *Wallet) syncNotifications(ctx context.Context, syncer *spv.Syncer, ntfns *spv.Notifications) *spv.Notifications {
+	wrapped := w.progressNotifications(syncer, ntfns)
+	progressSynced := wrapped.Synced
+	var once sync.Once
+	wrapped.Synced = func(synced bool) {
+		if synced {
+			if err := w.updateCoinType(ctx); err != nil {
+				w.log.Errorf("Unable to update the wallet coin type: %v", err)
+			}
+		}
+		progressSynced(synced)
+		if !synced {
+			return
+		}
+		w.seedMtx.Lock()
+		estimate := w.metaData.EstimateBirthday
+		w.seedMtx.Unlock()
+		if !estimate {
+			return
+		}
+		once.Do(func() {
+			go func() {
+				if err := w.estimateBirthday(ctx, syncer, ntfns); err != nil {
+					w.log.Errorf("Unable to estimate the wallet birthday: %v", err)
+				}
+			}()
+		})
+	}
+	return wrapped
+}
+
 // IsSyncing returns true if the wallet is catching up to the mainchain's best
 // block.
 func (w *Wallet) IsSyncing(ctx context.Context) bool {
@@ -99,11 +209,50 @@ func (w *Wallet) IsSynced(ctx context.Context) (bool, int32) {
 
 // RescanProgressFromHeight rescans for relevant transactions in all blocks in
 // the main chain starting at startHeight. Progress notifications and any
-// errors are sent to the channel p. This function blocks until the rescan
-// completes or ends in an error. p is closed before returning.
+// errors are sent to the channel p, and the error that ended the rescan is
+// also reported by SyncProgress. Only one rescan may run at a time. This
+// function blocks until the rescan completes or ends in an error. p is closed
+// before returning.
 func (w *Wallet) RescanProgressFromHeight(ctx context.Context,
 	startHeight int32, p chan<- dcrwallet.RescanProgress) {
-	w.syncerMtx.RLock()
-	defer w.syncerMtx.RUnlock()
-	w.mainWallet.RescanProgressFromHeight(ctx, w.syncer, startHeight, p)
+	if err := w.progress.startRescan(startHeight); err != nil {
+		p <- dcrwallet.RescanProgress{Err: err}
+		close(p)
+		return
+	}
+	w.rescan(ctx, startHeight, p)
+}
+
+// StartRescan starts a rescan from startHeight like RescanProgressFromHeight
+// but returns once it has started, or with an error if a rescan is already
+// running. The returned channel must be read until it is closed.
+func (w *Wallet) StartRescan(ctx context.Context, startHeight int32) (<-chan dcrwallet.RescanProgress, error) {
+	if err := w.progress.startRescan(startHeight); err != nil {
+		return nil, err
+	}
+	p := make(chan dcrwallet.RescanProgress)
+	go w.rescan(ctx, startHeight, p)
+	return p, nil
+}
+
+// rescan runs a rescan recorded with progress.startRescan, sending progress
+// to p and closing it when done.
+func (w *Wallet) rescan(ctx context.Context, startHeight int32, p chan<- dcrwallet.RescanProgress) {
+	prog := make(chan dcrwallet.RescanProgress)
+	go func() {
+		w.syncerMtx.RLock()
+		defer w.syncerMtx.RUnlock()
+		w.mainWallet.RescanProgressFromHeight(ctx, w.syncer, startHeight, prog)
+	}()
+	var rescanErr error
+	for rp := range prog {
+		if rp.Err != nil {
+			rescanErr = rp.Err
+		} else {
+			w.progress.rescanned(rp.ScannedThrough)
+		}
+		p <- rp
+	}
+	w.progress.endRescan(rescanErr)
+	close(p)
 }
@@ -0,0 +1,416 @@
+package dcr
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"decred.org/dcrwallet/v5/spv"
+)
+
+// SyncStage is a stage of wallet synchronization.
+type SyncStage int
+
+const (
+	SyncStageNotStarted SyncStage = iota
+	SyncStageFetchingCFilters
+	SyncStageFetchingHeaders
+	SyncStageDiscoveringAddrs
+	SyncStageRescanning
+	SyncStageComplete
+)
+
+func (s SyncStage) String() string {
+	return [...]string{"not started", "fetching cfilters", "fetching headers",
+		"discovering addresses", "rescanning", "sync complete"}[s]
+}
+
+// SyncProgress describes the progress of wallet synchronization.
+type SyncProgress struct {
+	Stage SyncStage
+	// TargetHeight is the best height advertised by peers, or the wallet's
+	// tip height once synced.
+	TargetHeight int32
+	// The heights reached in each stage.
+	CFiltersHeight int32
+	HeadersHeight  int32
+	RescanHeight   int32
+	// Percent is the overall progress from 0 to 100. During a rescan
+	// started with RescanProgressFromHeight it is the rescan progress.
+	Percent float64
+	// ETA is the estimated time until the sync or rescan completes. Zero
+	// if unknown.
+	ETA      time.Duration
+	NumPeers int32
+	// LastErr is the error that last ended sync, if any.
+	LastErr error
+	// Failures is the number of consecutive failed sync attempts. It is
+	// reset once synced.
+	Failures int
+}
+
+// The share of the overall progress of each stage, in order.
+var syncStageWeights = [...]float64{
+	SyncStageFetchingCFilters: 10,
+	SyncStageFetchingHeaders:  50,
+	SyncStageDiscoveringAddrs: 10,
+	SyncStageRescanning:       30,
+}
+
+// syncPercent returns the overall sync progress at height of target in stage.
+func syncPercent(stage SyncStage, height, target int32) float64 {
+	switch stage {
+	case SyncStageNotStarted:
+		return 0
+	case SyncStageComplete:
+		return 100
+	}
+	var percent float64
+	for s := SyncStageFetchingCFilters; s < stage; s++ {
+		percent += syncStageWeights[s]
+	}
+	if target > 0 && height > 0 {
+		percent += syncStageWeights[stage] * min(float64(height)/float64(target), 1)
+	}
+	return percent
+}
+
+// syncProgress tracks sync from the notifications of the current syncer and
+// rescans outside of the initial sync.
+type syncProgress struct {
+	mtx sync.Mutex
+	// events receives stage changes and rescan progress.
+	events *eventQueue
+	// syncer is the syncer notifications are accepted from. Notifications
+	// of an earlier syncer that arrive late are ignored.
+	syncer         *spv.Syncer
+	stage          SyncStage
+	cfiltersHeight int32
+	headersHeight  int32
+	rescanHeight   int32
+	numPeers       int32
+	lastErr        error
+	failures       int
+
+	// rescanning is true during a rescan outside of the initial sync, such
+	// as one started with RescanProgressFromHeight. Stage changes of the
+	// syncer are ignored until it is done and the stage is restored to
+	// preRescanStage.
+	rescanning     bool
+	rescanStart    int32
+	preRescanStage SyncStage
+
+	// etaStart and etaStartPercent are when and from which percentage the
+	// progress of the current sync or rescan is measured for the ETA.
+	etaStart        time.Time
+	etaStartPercent float64
+}
+
+// begin starts tracking the notifications of syncer.
+func (p *syncProgress) begin(syncer *spv.Syncer) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	p.syncer = syncer
+	p.numPeers = 0
+	p.etaStart = time.Time{}
+	if !p.rescanning {
+		p.moveTo(SyncStageNotStarted)
+	}
+}
+
+// end stops tracking the current syncer. err is the error that ended sync,
+// if any. The number of consecutive failures is returned.
+func (p *syncProgress) end(err error) int {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	p.syncer = nil
+	p.numPeers = 0
+	if err != nil {
+		p.lastErr = err
+		p.failures++
+	}
+	if p.rescanning {
+		p.preRescanStage = SyncStageNotStarted
+	} else {
+		p.moveTo(SyncStageNotStarted)
+	}
+	return p.failures
+}
+
+// update calls f if syncer is the current syncer.
+func (p *syncProgress) update(syncer *spv.Syncer, f func()) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	if p.syncer == syncer {
+		f()
+	}
+}
+
+// setStage moves to stage unless a rescan outside of the initial sync is in
+// progress. The mtx MUST be held.
+func (p *syncProgress) setStage(stage SyncStage) {
+	if p.rescanning {
+		p.preRescanStage = stage
+		return
+	}
+	// Errors are cleared when sync completes, not every time the stage
+	// is confirmed.
+	if stage == SyncStageComplete && p.stage != SyncStageComplete {
+		p.lastErr = nil
+		p.failures = 0
+	}
+	p.moveTo(stage)
+}
+
+// moveTo changes the stage and queues an event if it changed. The mtx MUST be
+// held.
+func (p *syncProgress) moveTo(stage SyncStage) {
+	if p.stage == stage {
+		return
+	}
+	p.stage = stage
+	p.events.push(&Event{
+		Type:      EventSyncStage,
+		SyncStage: &SyncStageEvent{Stage: stage, Name: stage.String()},
+	})
+}
+
+// setRescanHeight records the height a rescan reached and queues an event.
+// The mtx MUST be held.
+func (p *syncProgress) setRescanHeight(height int32) {
+	p.rescanHeight = height
+	p.events.push(&Event{
+		Type:   EventRescanProgress,
+		Rescan: &RescanEvent{Height: height},
+	})
+}
+
+// startRescan records the start of a rescan from height.
+func (p *syncProgress) startRescan(height int32) error {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	if p.rescanning {
+		return errors.New("wallet already rescanning")
+	}
+	p.rescanning = true
+	p.preRescanStage = p.stage
+	p.moveTo(SyncStageRescanning)
+	p.rescanStart = height
+	p.rescanHeight = height
+	p.etaStart = time.Time{}
+	return nil
+}
+
+// rescanned records the height a rescan started with startRescan reached.
+func (p *syncProgress) rescanned(height int32) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	p.setRescanHeight(height)
+}
+
+// endRescan records the end of a rescan started with startRescan. err is the
+// error that ended the rescan, if any.
+func (p *syncProgress) endRescan(err error) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	if err != nil {
+		p.lastErr = err
+	}
+	p.rescanning = false
+	p.moveTo(p.preRescanStage)
+	p.etaStart = time.Time{}
+}
+
+// snapshot returns the progress at now. synced and targetHeight are the state
+// of the syncer and tipHeight the wallet's main chain tip height.
+func (p *syncProgress) snapshot(synced bool, targetHeight, tipHeight int32, now time.Time) *SyncProgress {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	// Stage notifications may arrive out of order, such as a rescan
+	// notification after sync completed, so trust the syncer once synced.
+	if synced && !p.rescanning && p.syncer != nil {
+		p.setStage(SyncStageComplete)
+	}
+	sp := &SyncProgress{
+		Stage:          p.stage,
+		TargetHeight:   targetHeight,
+		CFiltersHeight: p.cfiltersHeight,
+		HeadersHeight:  p.headersHeight,
+		RescanHeight:   p.rescanHeight,
+		NumPeers:       p.numPeers,
+		LastErr:        p.lastErr,
+		Failures:       p.failures,
+	}
+	switch {
+	case p.rescanning:
+		if tipHeight > p.rescanStart {
+			done := float64(p.rescanHeight-p.rescanStart) / float64(tipHeight-p.rescanStart)
+			sp.Percent = 100 * min(max(done, 0), 1)
+		}
+	case p.stage == SyncStageFetchingCFilters:
+		// Missing cfilters are fetched for headers the wallet has.
+		sp.Percent = syncPercent(p.stage, p.cfiltersHeight, tipHeight)
+	case p.stage == SyncStageFetchingHeaders:
+		sp.Percent = syncPercent(p.stage, p.headersHeight, targetHeight)
+	case p.stage == SyncStageRescanning:
+		sp.Percent = syncPercent(p.stage, p.rescanHeight, tipHeight)
+	default:
+		sp.Percent = syncPercent(p.stage, 0, 0)
+	}
+
+	if sp.Stage == SyncStageNotStarted || sp.Stage == SyncStageComplete {
+		p.etaStart = time.Time{}
+		return sp
+	}
+	if p.etaStart.IsZero() {
+		p.etaStart, p.etaStartPercent = now, sp.Percent
+		return sp
+	}
+	if done := sp.Percent - p.etaStartPercent; done > 0 {
+		elapsed := now.Sub(p.etaStart)
+		sp.ETA = time.Duration(float64(elapsed) * (100 - sp.Percent) / done)
+	}
+	return sp
+}
+
+// SyncProgress returns the progress of wallet synchronization.
+func (w *Wallet) SyncProgress() *SyncProgress {
+	ctx := context.Background()
+	synced, targetHeight := w.IsSynced(ctx)
+	_, tipHeight := w.mainWallet.MainChainTip(ctx)
+	return w.progress.snapshot(synced, targetHeight, tipHeight, time.Now())
+}
+
+// progressNotifications returns ntfns wrapped to track the progress of
+// syncer.
+func (w *Wallet) progressNotifications(syncer *spv.Syncer, ntfns *spv.Notifications) *spv.Notifications {
+	user := new(spv.Notifications)
+	if ntfns != nil {
+		*user = *ntfns
+	}
+	wrapped := *user
+	p := &w.progress
+
+	wrapped.Synced = func(synced bool) {
+		p.update(syncer, func() {
+			if synced {
+				p.setStage(SyncStageComplete)
+			} else {
+				p.setStage(SyncStageNotStarted)
+			}
+		})
+		if synced {
+			w.log.Debug("Sync completed.")
+		} else {
+			w.log.Debug("Wallet is no longer synced.")
+		}
+		if user.Synced != nil {
+			user.Synced(synced)
+		}
+	}
+	wrapped.PeerConnected = func(peerCount int32, addr string) {
+		p.update(syncer, func() {
+			p.numPeers = peerCount
+			w.events.push(&Event{
+				Type: EventPeerConnected,
+				Peer: &PeerEvent{Addr: addr, NumPeers: peerCount},
+			})
+		})
+		w.log.Debugf("Connected to peer at %s. %d total peers.", addr, peerCount)
+		if user.PeerConnected != nil {
+			user.PeerConnected(peerCount, addr)
+		}
+	}
+	wrapped.PeerDisconnected = func(peerCount int32, addr string) {
+		p.update(syncer, func() {
+			p.numPeers = peerCount
+			w.events.push(&Event{
+				Type: EventPeerDisconnected,
+				Peer: &PeerEvent{Addr: addr, NumPeers: peerCount},
+			})
+		})
+		w.log.Debugf("Disconnected from peer at %s. %d total peers.", addr, peerCount)
+		if user.PeerDisconnected != nil {
+			user.PeerDisconnected(peerCount, addr)
+		}
+	}
+	wrapped.FetchMissingCFiltersStarted = func() {
+		p.update(syncer, func() { p.setStage(SyncStageFetchingCFilters) })
+		w.log.Debug("Fetching missing cfilters started.")
+		if user.FetchMissingCFiltersStarted != nil {
+			user.FetchMissingCFiltersStarted()
+		}
+	}
+	wrapped.FetchMissingCFiltersProgress = func(startCFiltersHeight, endCFiltersHeight int32) {
+		p.update(syncer, func() { p.cfiltersHeight = endCFiltersHeight })
+		w.log.Debugf("Fetching cfilters from %d to %d.", startCFiltersHeight, endCFiltersHeight)
+		if user.FetchMissingCFiltersProgress != nil {
+			user.FetchMissingCFiltersProgress(startCFiltersHeight, endCFiltersHeight)
+		}
+	}
+	wrapped.FetchMissingCFiltersFinished = func() {
+		w.log.Debug("Finished fetching missing cfilters.")
+		if user.FetchMissingCFiltersFinished != nil {
+			user.FetchMissingCFiltersFinished()
+		}
+	}
+	wrapped.FetchHeadersStarted = func() {
+		p.update(syncer, func() { p.setStage(SyncStageFetchingHeaders) })
+		w.log.Debug("Fetching headers started.")
+		if user.FetchHeadersStarted != nil {
+			user.FetchHeadersStarted()
+		}
+	}
+	wrapped.FetchHeadersProgress = func(lastHeaderHeight int32, lastHeaderTime int64) {
+		p.update(syncer, func() { p.headersHeight = lastHeaderHeight })
+		w.log.Debugf("Fetching headers to %d.", lastHeaderHeight)
+		if user.FetchHeadersProgress != nil {
+			user.FetchHeadersProgress(lastHeaderHeight, lastHeaderTime)
+		}
+	}
+	wrapped.FetchHeadersFinished = func() {
+		w.log.Debug("Fetching headers finished.")
+		if user.FetchHeadersFinished != nil {
+			user.FetchHeadersFinished()
+		}
+	}
+	wrapped.DiscoverAddressesStarted = func() {
+		p.update(syncer, func() { p.setStage(SyncStageDiscoveringAddrs) })
+		w.log.Debug("Discover addresses started.")
+		if user.DiscoverAddressesStarted != nil {
+			user.DiscoverAddressesStarted()
+		}
+	}
+	wrapped.DiscoverAddressesFinished = func() {
+		w.log.Debug("Discover addresses finished.")
+		if user.DiscoverAddressesFinished != nil {
+			user.DiscoverAddressesFinished()
+		}
+	}
+	wrapped.RescanStarted = func() {
+		p.update(syncer, func() { p.setStage(SyncStageRescanning) })
+		w.log.Debug("Rescan started.")
+		if user.RescanStarted != nil {
+			user.RescanStarted()
+		}
+	}
+	wrapped.RescanProgress = func(rescannedThrough int32) {
+		p.update(syncer, func() {
+			if !p.rescanning {
+				p.setRescanHeight(rescannedThrough)
+			}
+		})
+		w.log.Debugf("Rescanned through block %d.", rescannedThrough)
+		if user.RescanProgress != nil {
+			user.RescanProgress(rescannedThrough)
+		}
+	}
+	wrapped.RescanFinished = func() {
+		w.log.Debug("Rescan finished.")
+		if user.RescanFinished != nil {
+			user.RescanFinished()
+		}
+	}
+	return &wrapped
+}
@@ -27,7 +27,6 @@ import (
 )
 
 const (
-	defaultAccount = "default"
 	// sstxCommitmentString is the string to insert when a verbose
 	// transaction output's pkscript type is a ticket commitment.
 	sstxCommitmentString = "sstxcommitment"
@@ -68,7 +67,15 @@ func newTxOut(amount int64, pkScriptVer uint16, pkScript []byte) *wire.TxOut {
 func (w *Wallet) signRawTransaction(ctx context.Context, baseTx *wire.MsgTx) (*wire.MsgTx, error) {
 	// Copy the passed transaction to avoid altering it.
 	tx := baseTx.Copy()
-	sigErrs, err := w.mainWallet.SignTransaction(ctx, tx, txscript.SigHashAll, nil, nil, nil)
+	var keys map[string]*dcrutil.WIF
+	if w.isUpgradedWatchOnly() {
+		var err error
+		keys, err = w.seedSigningKeys(ctx, tx)
+		if err != nil {
+			return nil, err
+		}
+	}
+	sigErrs, err := w.mainWallet.SignTransaction(ctx, tx, txscript.SigHashAll, nil, keys, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -110,11 +117,13 @@ func (cs *changeSource) ScriptSize() int {
 	return len(cs.script)
 }
 
-// CreateTransaction creates a transaction. The wallet must be unlocked before
-// calling if signing. sendAll will send everything to one output. In that
-// case the output's amount is ignored.
-func (w *Wallet) CreateTransaction(ctx context.Context, outputs []*Output,
-	inputs, ignoreInputs []*Input, feeRate uint64, sendAll, sign bool) (signedTx []byte,
+// CreateTransaction creates a transaction spending from the account. If sign
+// is true the wallet is unlocked with pass for signing and locked again after
+// unless an unlock session is active, in which case pass may be empty. sendAll
+// will send everything to one output. In that case the output's amount is
+// ignored.
+func (w *Wallet) CreateTransaction(ctx context.Context, accountNum uint32, outputs []*Output,
+	inputs, ignoreInputs []*Input, feeRate uint64, sendAll, sign bool, pass []byte) (signedTx []byte,
 	txid *chainhash.Hash, fee uint64, err error) {
 	if sendAll && len(outputs) > 1 {
 		return nil, nil, 0, errors.New("send all can only be used with one recepient")
@@ -122,6 +131,10 @@ func (w *Wallet) CreateTransaction(ctx context.Context, outputs []*Output,
 	if len(outputs) < 1 {
 		return nil, nil, 0, errors.New("no outputs")
 	}
+	accountName, err := w.mainWallet.AccountName(ctx, accountNum)
+	if err != nil {
+		return nil, nil, 0, err
+	}
 	var ignoreCoinIDs = make(map[string]struct{})
 	for _, in := range ignoreInputs {
 		ignoreCoinIDs[in.String()] = struct{}{}
@@ -154,12 +167,12 @@ func (w *Wallet) CreateTransaction(ctx context.Context, outputs []*Output,
 	}
 	if len(inputs) > 0 {
 		// If inputs were specified use only them and all of them.
-		unspents, err := w.mainWallet.ListUnspent(ctx, 0, math.MaxInt32, nil, defaultAccount)
+		unspents, err := w.mainWallet.ListUnspent(ctx, 0, math.MaxInt32, nil, accountName)
 		if err != nil {
 			return nil, nil, 0, err
 		}
 		if len(unspents) == 0 {
-			return nil, nil, 0, errors.New("insufficient funds. 0 DCR available to spend in default account")
+			return nil, nil, 0, fmt.Errorf("insufficient funds. 0 DCR available to spend in account %q", accountName)
 		}
 		var coinIDs = make(map[string]struct{})
 		for _, in := range inputs {
@@ -197,12 +210,12 @@ func (w *Wallet) CreateTransaction(ctx context.Context, outputs []*Output,
 	} else if len(ignoreInputs) > 0 {
 		// If we have inputs to ignore, randomize all inputs and ignore
 		// those specified.
-		unspents, err := w.mainWallet.ListUnspent(ctx, 0, math.MaxInt32, nil, defaultAccount)
+		unspents, err := w.mainWallet.ListUnspent(ctx, 0, math.MaxInt32, nil, accountName)
 		if err != nil {
 			return nil, nil, 0, err
 		}
 		if len(unspents) == 0 {
-			return nil, nil, 0, errors.New("insufficient funds. 0 DCR available to spend in default account")
+			return nil, nil, 0, fmt.Errorf("insufficient funds. 0 DCR available to spend in account %q", accountName)
 		}
 		for i := range unspents {
 			j := rand.Intn(i + 1)
@@ -248,10 +261,7 @@ func (w *Wallet) CreateTransaction(ctx context.Context, outputs []*Output,
 		outs[i] = txOut
 	}
 
-	const (
-		accountNum = 0
-		confs      = 1
-	)
+	const confs = 1
 	var atx *txauthor.AuthoredTx
 
 	if sendAll {
@@ -287,6 +297,11 @@ func (w *Wallet) CreateTransaction(ctx context.Context, outputs []*Output,
 		return b, &txHash, fee, nil
 	}
 
+	done, err := w.UnlockForSigning(ctx, pass)
+	if err != nil {
+		return nil, nil, 0, fmt.Errorf("cannot unlock wallet: %w", err)
+	}
+	defer done()
 	signedMsgTx, err := w.signRawTransaction(ctx, atx.Tx)
 	if err != nil {
 		return nil, nil, 0, err
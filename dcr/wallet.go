@@ -13,6 +13,7 @@ import (
 	"decred.org/dcrwallet/v5/spv"
 	"decred.org/dcrwallet/v5/wallet"
 	"github.com/decred/dcrd/chaincfg/v3"
+	"github.com/decred/dcrd/hdkeychain/v3"
 	"github.com/decred/libwallet/mnemonic"
 	"github.com/decred/slog"
 )
@@ -20,7 +21,9 @@ import (
 type mainWallet = wallet.Wallet
 
 type Wallet struct {
-	dir         string
+	dir string
+	// openDir is the key of the wallet in openWallets while it is open.
+	openDir     string
 	dbDriver    string
 	chainParams *chaincfg.Params
 	log         slog.Logger
@@ -32,9 +35,29 @@ type Wallet struct {
 	db       wallet.DB
 	*mainWallet
 
+	// signKeyMtx protects signKey, the default account private key of an
+	// upgraded watching only wallet while it is unlocked.
+	signKeyMtx sync.Mutex
+	signKey    *hdkeychain.ExtendedKey
+
+	// lockMtx protects the unlock session started by UnlockFor and the
+	// number of signing operations in progress.
+	lockMtx  sync.Mutex
+	session  *unlockSession
+	nSigning int
+
 	syncerMtx sync.RWMutex
 	syncer    *spv.Syncer
 	*syncHelper
+	progress syncProgress
+	events   *eventQueue
+	peers    peerManager
+	// ntfnsMtx protects stopNtfns, which stops queueing events for
+	// transaction notifications while the wallet is open.
+	ntfnsMtx  sync.Mutex
+	stopNtfns func()
+	// proxy is the SOCKS5 proxy for network connections, if any.
+	proxy *ProxyConfig
 }
 
 // MainWallet returns the main dcr wallet with the core wallet functionalities.
@@ -48,6 +71,10 @@ func (w *Wallet) DecryptSeed(passphrase []byte) (string, error) {
 	w.seedMtx.Lock()
 	defer w.seedMtx.Unlock()
 
+	if w.metaData.EncryptedSeedHex == "" {
+		return "", errors.New("encrypted seed does not exist")
+	}
+
 	encryptedSeed, err := hex.DecodeString(w.metaData.EncryptedSeedHex)
 	if err != nil {
 		return "", fmt.Errorf("unable to decode encrypted hex seed: %v", err)
@@ -58,11 +85,19 @@ func (w *Wallet) DecryptSeed(passphrase []byte) (string, error) {
 		return "", err
 	}
 
+	if err := w.upgradeSeedEncryption(passphrase); err != nil {
+		w.log.Warnf("Unable to upgrade seed encryption: %v", err)
+	}
+
 	switch w.metaData.SeedType {
 	case STFifteenWords:
 		return dexmnemonic.GenerateMnemonic(seed, time.Unix(w.metaData.Birthday, 0))
 	case STTwelveWords, STTwentyFourWords:
-		return mnemonic.GenerateMnemonic(seed)
+		return bip39Words(seed, w.metaData.Language)
+	case STThirtyThreeWords:
+		return mnemonic.EncodePGPMnemonic(seed)
+	case STSeedShares:
+		return "", errors.New("wallet was recovered from seed shares and has no mnemonic")
 	default:
 		return "", fmt.Errorf("invalid saved seed length %d", len(seed))
 	}
@@ -99,16 +134,94 @@ func (w *Wallet) ReEncryptSeed(oldPass, newPass []byte) error {
 		}
 	}
 
-	birthday := time.Unix(w.metaData.Birthday, 0)
-	updatedMetaData, err := saveWalletData(seed, seedPass, w.metaData.DefaultAccountXPub, birthday, w.dir, newPass, w.metaData.SeedType)
+	encSeedHex, encSeedPassHex, err := encryptSeed(seed, seedPass, newPass)
 	if err != nil {
 		return err
 	}
+	updatedMetaData := *w.metaData
+	updatedMetaData.EncryptedSeedHex = encSeedHex
+	updatedMetaData.EncryptedSeedPassHex = encSeedPassHex
+	if err := saveWalletData(&updatedMetaData, w.dir); err != nil {
+		return err
+	}
 
 	// Update only the EncryptedSeedHex and pass field since we've held the seedMtx lock
 	// above.
-	w.metaData.EncryptedSeedHex = updatedMetaData.EncryptedSeedHex
-	w.metaData.EncryptedSeedPassHex = updatedMetaData.EncryptedSeedPassHex
+	w.metaData.EncryptedSeedHex = encSeedHex
+	w.metaData.EncryptedSeedPassHex = encSeedPassHex
+	return nil
+}
+
+// upgradeSeedEncryption re-encrypts the seed and seed pass with the current
+// encryption format if they were encrypted with the legacy unsalted format.
+// The seedMtx MUST be held.
+func (w *Wallet) upgradeSeedEncryption(pass []byte) error {
+	if w.metaData.EncryptedSeedHex == "" {
+		return nil
+	}
+	encryptedSeed, err := hex.DecodeString(w.metaData.EncryptedSeedHex)
+	if err != nil {
+		return fmt.Errorf("unable to decode encrypted hex seed: %v", err)
+	}
+	var encSeedPass []byte
+	if len(w.metaData.EncryptedSeedPassHex) != 0 {
+		encSeedPass, err = hex.DecodeString(w.metaData.EncryptedSeedPassHex)
+		if err != nil {
+			return fmt.Errorf("unable to decode encrypted seed pass: %v", err)
+		}
+	}
+	if !isLegacyData(encryptedSeed) && (encSeedPass == nil || !isLegacyData(encSeedPass)) {
+		return nil
+	}
+
+	seed, err := DecryptData(encryptedSeed, pass)
+	if err != nil {
+		return err
+	}
+	var seedPass []byte
+	if encSeedPass != nil {
+		seedPass, err = DecryptData(encSeedPass, pass)
+		if err != nil {
+			return fmt.Errorf("unable to decrypt wallet seed pass: %v", err)
+		}
+	}
+
+	encSeedHex, encSeedPassHex, err := encryptSeed(seed, seedPass, pass)
+	if err != nil {
+		return err
+	}
+	updatedMetaData := *w.metaData
+	updatedMetaData.EncryptedSeedHex = encSeedHex
+	updatedMetaData.EncryptedSeedPassHex = encSeedPassHex
+	if err := saveWalletData(&updatedMetaData, w.dir); err != nil {
+		return err
+	}
+	w.metaData.EncryptedSeedHex = encSeedHex
+	w.metaData.EncryptedSeedPassHex = encSeedPassHex
+	w.log.Info("Upgraded seed encryption")
+	return nil
+}
+
+// Unlock unlocks the wallet with the private passphrase. See the main wallet's
+// Unlock for the meaning of timeout. A seed encrypted with the legacy format is
+// re-encrypted with the current format once the passphrase is verified.
+func (w *Wallet) Unlock(ctx context.Context, passphrase []byte, timeout <-chan time.Time) error {
+	if w.mainWallet == nil {
+		return errors.New("wallet is not open")
+	}
+	// Watching only wallets upgraded with a seed sign with keys derived
+	// from the seed.
+	if w.isUpgradedWatchOnly() {
+		return w.unlockSeedSigner(passphrase, timeout)
+	}
+	if err := w.mainWallet.Unlock(ctx, passphrase, timeout); err != nil {
+		return err
+	}
+	w.seedMtx.Lock()
+	defer w.seedMtx.Unlock()
+	if err := w.upgradeSeedEncryption(passphrase); err != nil {
+		w.log.Warnf("Unable to upgrade seed encryption: %v", err)
+	}
 	return nil
 }
 
@@ -118,13 +231,22 @@ func (w *Wallet) OpenWallet(ctx context.Context) error {
 		return fmt.Errorf("wallet is already open")
 	}
 
+	openDir, err := markWalletOpen(w.dir)
+	if err != nil {
+		return err
+	}
+
 	w.log.Info("Opening wallet...")
 	db, err := wallet.OpenDB(w.dbDriver, filepath.Join(w.dir, walletDbName))
 	if err != nil {
+		markWalletClosed(openDir)
 		return fmt.Errorf("wallet.OpenDB error: %w", err)
 	}
 
-	dcrw, err := wallet.Open(ctx, newWalletConfig(db, w.chainParams))
+	walletCfg := newWalletConfig(db, w.chainParams, w.metaData.Config)
+	// The coin type is upgraded once the birthday is estimated.
+	walletCfg.DisableCoinTypeUpgrades = w.metaData.EstimateBirthday
+	dcrw, err := wallet.Open(ctx, walletCfg)
 	if err != nil {
 		// If this function does not return to completion the database must be
 		// closed.  Otherwise, because the database is locked on open, any
@@ -133,11 +255,18 @@ func (w *Wallet) OpenWallet(ctx context.Context) error {
 		if err := db.Close(); err != nil {
 			w.log.Errorf("Failed to close wallet database after OpenWallet error: %v", err)
 		}
+		markWalletClosed(openDir)
 		return fmt.Errorf("wallet.Open error: %w", err)
 	}
 
+	w.openDir = openDir
 	w.db = db
 	w.mainWallet = dcrw
+	w.startTxNotifications()
+	// Older wallets did not record their coin type.
+	if err := w.updateCoinType(ctx); err != nil {
+		w.log.Errorf("Unable to update the wallet coin type: %v", err)
+	}
 	return nil
 }
 
@@ -147,11 +276,14 @@ func (w *Wallet) CloseWallet() error {
 	w.log.Info("Closing wallet")
 	w.StopSync()
 	w.WaitForSyncToStop()
+	w.Lock()
+	w.stopTxNotifications()
 
 	w.log.Trace("Closing wallet db")
 	if err := w.db.Close(); err != nil {
 		return fmt.Errorf("close wallet db error: %w", err)
 	}
+	markWalletClosed(w.openDir)
 
 	w.log.Info("Wallet closed")
 	return nil
@@ -3,10 +3,15 @@ package dcr
 import (
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"time"
+
+	"github.com/decred/libwallet/mnemonic"
+	"github.com/decred/slog"
 )
 
 // SeedType defines the type of seed used by the wallet. Currently all use bip39
@@ -20,66 +25,256 @@ const (
 	STFifteenWords    SeedType = iota // 0
 	STTwelveWords                     // 1
 	STTwentyFourWords                 // 2
+	// STThirtyThreeWords is a 32 byte seed encoded with the PGP word list
+	// and a checksum word as used by dcrwallet and Decrediton. The seed is
+	// used without a tweak.
+	STThirtyThreeWords // 3
+	// STSeedShares is a wallet master seed recovered from SLIP-0039 seed
+	// shares. The seed is used without a tweak and has no mnemonic.
+	STSeedShares // 4
 )
 
-const walletDataFileName = "walletdata.json"
+const (
+	walletDataFileName       = "walletdata.json"
+	walletDataBackupFileName = walletDataFileName + ".bak"
+
+	// walletDataVersion is the current version of the wallet data file.
+	walletDataVersion = 2
+)
+
+// ErrWalletDataNotFound is returned when a wallet database exists but its
+// wallet data file and backup are missing.
+var ErrWalletDataNotFound = errors.New("wallet data file not found")
+
+// ErrWalletDataCorrupt is returned when the wallet data file cannot be decoded.
+var ErrWalletDataCorrupt = errors.New("wallet data file is corrupt")
+
+// walletDataMigrations holds the functions that upgrade the wallet data. The
+// migration at index i upgrades version i to version i+1.
+var walletDataMigrations = []func(wd *walletData) error{
+	// Version 1 adds the version field.
+	func(*walletData) error { return nil },
+	// Version 2 removes the encrypted empty seed that was saved for
+	// watching only wallets.
+	func(wd *walletData) error {
+		if wd.EncryptedSeedHex == "" {
+			return nil
+		}
+		encSeed, err := hex.DecodeString(wd.EncryptedSeedHex)
+		if err != nil {
+			return fmt.Errorf("unable to decode encrypted hex seed: %v", err)
+		}
+		if plaintextLen(encSeed) <= 0 {
+			wd.EncryptedSeedHex = ""
+		}
+		return nil
+	},
+}
 
 type walletData struct {
+	Version              int      `json:"version"`
 	EncryptedSeedHex     string   `json:"encryptedseedhex,omitempty"`
 	EncryptedSeedPassHex string   `json:"encryptedseedpasshex,omitempty"`
 	SeedType             SeedType `json:"seedtype,omitempty"`
-	DefaultAccountXPub   string   `json:"defaultaccountxpub,omitempty"`
-	Birthday             int64    `json:"birthday,omitempty"`
+	// Language is the language of twelve and twenty four word mnemonics.
+	Language           mnemonic.Language `json:"language,omitempty"`
+	DefaultAccountXPub string            `json:"defaultaccountxpub,omitempty"`
+	Birthday           int64             `json:"birthday,omitempty"`
+	Config             *WalletConfig     `json:"config,omitempty"`
+	SeedBackedUp       bool              `json:"seedbackedup,omitempty"`
+	EntropyAudit       *EntropyAudit     `json:"entropyaudit,omitempty"`
+	// EstimateBirthday is set until the birthday of a recovered wallet is
+	// estimated after syncing.
+	EstimateBirthday bool `json:"estimatebirthday,omitempty"`
+	// CoinType is the BIP0044 coin type of the default account xpub. Zero
+	// if unknown, as for watching only wallets.
+	CoinType uint32 `json:"cointype,omitempty"`
+	// PersistentPeers are the peers added with AddPeer.
+	PersistentPeers []string `json:"persistentpeers,omitempty"`
+	// BannedPeers are the unix times until which hosts are banned.
+	BannedPeers map[string]int64 `json:"bannedpeers,omitempty"`
 }
 
-func saveWalletData(seed, seedPass []byte, defaultAccountXPub string, birthday time.Time, dataDir string, walletPass []byte, seedType SeedType) (*walletData, error) {
+// encryptSeed encrypts the seed and the optional seed pass with the wallet
+// pass and returns them hex encoded. Empty strings are returned if there is no
+// seed.
+func encryptSeed(seed, seedPass, walletPass []byte) (encSeedHex, encSeedPassHex string, err error) {
+	// Watching only wallets have no seed.
+	if len(seed) == 0 {
+		return "", "", nil
+	}
+
 	encSeed, err := EncryptData(seed, walletPass)
 	if err != nil {
-		return nil, fmt.Errorf("seed encryption error: %v", err)
+		return "", "", fmt.Errorf("seed encryption error: %v", err)
 	}
 
-	encSeedPassHex := ""
 	if len(seedPass) != 0 {
 		encSeedPass, err := EncryptData(seedPass, walletPass)
 		if err != nil {
-			return nil, fmt.Errorf("seed pass encryption error: %v", err)
+			return "", "", fmt.Errorf("seed pass encryption error: %v", err)
 		}
 		encSeedPassHex = hex.EncodeToString(encSeedPass)
 	}
 
-	encSeedHex := hex.EncodeToString(encSeed)
-	wd := &walletData{
+	return hex.EncodeToString(encSeed), encSeedPassHex, nil
+}
+
+func newWalletData(seed, seedPass []byte, defaultAccountXPub string, birthday time.Time, walletPass []byte, seedType SeedType, cfg *WalletConfig) (*walletData, error) {
+	encSeedHex, encSeedPassHex, err := encryptSeed(seed, seedPass, walletPass)
+	if err != nil {
+		return nil, err
+	}
+	return &walletData{
 		EncryptedSeedHex:     encSeedHex,
 		EncryptedSeedPassHex: encSeedPassHex,
 		DefaultAccountXPub:   defaultAccountXPub,
 		Birthday:             birthday.Unix(),
 		SeedType:             seedType,
-	}
+		Config:               cfg,
+	}, nil
+}
+
+// saveWalletData writes the wallet data to the wallet data file in dataDir.
+// The previous file is kept as a backup and the new file replaces it
+// atomically so that a crash cannot leave a partially written file.
+func saveWalletData(wd *walletData, dataDir string) error {
+	wd.Version = walletDataVersion
 	file, err := json.MarshalIndent(wd, "", " ")
 	if err != nil {
-		return nil, fmt.Errorf("unable to marshal wallet data: %v", err)
+		return fmt.Errorf("unable to marshal wallet data: %v", err)
 	}
 	fp := filepath.Join(dataDir, walletDataFileName)
-	err = os.WriteFile(fp, file, 0644)
+	prev, err := os.ReadFile(fp)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("unable to read wallet data file: %v", err)
+	}
+	if len(prev) != 0 {
+		if err := writeFileAtomic(filepath.Join(dataDir, walletDataBackupFileName), prev); err != nil {
+			return fmt.Errorf("unable to back up wallet data: %v", err)
+		}
+	}
+	if err := writeFileAtomic(fp, file); err != nil {
+		return fmt.Errorf("unable to write wallet data to file: %v", err)
+	}
+	return nil
+}
+
+// writeFileAtomic writes b to a temporary file readable only by the owner,
+// syncs it and renames it to fp.
+func writeFileAtomic(fp string, b []byte) error {
+	return writeFileAtomicFunc(fp, func(w io.Writer) error {
+		_, err := w.Write(b)
+		return err
+	})
+}
+
+// writeFileAtomicFunc is like writeFileAtomic but the file contents are
+// written by write. fp is left unchanged if write fails.
+func writeFileAtomicFunc(fp string, write func(io.Writer) error) error {
+	dir := filepath.Dir(fp)
+	f, err := os.CreateTemp(dir, filepath.Base(fp)+".tmp")
 	if err != nil {
-		return nil, fmt.Errorf("unable to write wallet data to file: %v", err)
+		return err
+	}
+	tmpName := f.Name()
+	defer os.Remove(tmpName) // no-op after a successful rename
+	if err := f.Chmod(0600); err != nil {
+		f.Close()
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpName, fp); err != nil {
+		return err
+	}
+	// Sync the directory so that the rename is persisted. Not supported on
+	// all platforms, so errors are ignored.
+	if d, err := os.Open(dir); err == nil {
+		_ = d.Sync()
+		d.Close()
 	}
-	return wd, nil
+	return nil
 }
 
-// retrieveWalletData returns the wallet data from the data dir.
-func retrieveWalletData(dataDir string) (*walletData, error) {
-	fp := filepath.Join(dataDir, walletDataFileName)
+// readWalletDataFile reads and decodes the wallet data file at fp.
+func readWalletDataFile(fp string) (*walletData, error) {
 	b, err := os.ReadFile(fp)
 	if err != nil {
-		if os.IsNotExist(err) {
-			return new(walletData), nil
-		}
-		return nil, fmt.Errorf("unable to read wallet data file: %v", err)
+		return nil, err
 	}
 	var wd walletData
 	if err := json.Unmarshal(b, &wd); err != nil {
-		return nil, fmt.Errorf("unable to unmarshal wallet data file: %v", err)
+		return nil, fmt.Errorf("%w: %v", ErrWalletDataCorrupt, err)
 	}
 	return &wd, nil
 }
+
+// retrieveWalletData returns the wallet data from the data dir. The backup is
+// used if the wallet data file is missing or cannot be read, and the file is
+// then restored from it. A corrupt wallet data file is an error rather than
+// being replaced. Empty wallet data is returned if neither file exists and
+// there is no wallet database.
+func retrieveWalletData(dataDir string, log slog.Logger) (*walletData, error) {
+	fp := filepath.Join(dataDir, walletDataFileName)
+	wd, err := readWalletDataFile(fp)
+	if err == nil {
+		return wd, nil
+	}
+	if errors.Is(err, ErrWalletDataCorrupt) {
+		log.Errorf("Wallet data file %s is corrupt: %v", fp, err)
+		return nil, fmt.Errorf("%s: %w", fp, err)
+	}
+	bakFp := filepath.Join(dataDir, walletDataBackupFileName)
+	wd, bakErr := readWalletDataFile(bakFp)
+	if bakErr == nil {
+		log.Warnf("Unable to read wallet data file, restoring it from %s: %v", bakFp, err)
+		b, err := os.ReadFile(bakFp)
+		if err == nil {
+			err = writeFileAtomic(fp, b)
+		}
+		if err != nil {
+			log.Errorf("Unable to restore the wallet data file from its backup: %v", err)
+		}
+		return wd, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("unable to read wallet data file: %v", err)
+	}
+	if !os.IsNotExist(bakErr) {
+		return nil, fmt.Errorf("unable to read wallet data backup file: %v", bakErr)
+	}
+	if exists, err := WalletExistsAt(dataDir); err != nil {
+		return nil, err
+	} else if exists {
+		return nil, ErrWalletDataNotFound
+	}
+	return &walletData{Version: walletDataVersion}, nil
+}
+
+// migrateWalletData runs any migrations needed to bring the wallet data up to
+// the current version and returns true if it was changed.
+func migrateWalletData(wd *walletData) (bool, error) {
+	if wd.Version > walletDataVersion {
+		return false, fmt.Errorf("wallet data version %d is newer than the supported version %d",
+			wd.Version, walletDataVersion)
+	}
+	migrated := false
+	for wd.Version < walletDataVersion {
+		if err := walletDataMigrations[wd.Version](wd); err != nil {
+			return false, fmt.Errorf("wallet data migration to version %d failed: %v", wd.Version+1, err)
+		}
+		wd.Version++
+		migrated = true
+	}
+	return migrated, nil
+}
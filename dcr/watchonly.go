@@ -0,0 +1,304 @@
+package dcr
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"decred.org/dcrwallet/v5/wallet"
+	"decred.org/dcrwallet/v5/wallet/udb"
+	"github.com/decred/dcrd/chaincfg/chainhash"
+	"github.com/decred/dcrd/dcrec"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+	"github.com/decred/dcrd/dcrutil/v4"
+	"github.com/decred/dcrd/hdkeychain/v3"
+	"github.com/decred/dcrd/txscript/v4/stdaddr"
+	"github.com/decred/dcrd/txscript/v4/stdscript"
+	"github.com/decred/dcrd/wire"
+	"github.com/decred/libwallet/mnemonic"
+)
+
+// ErrSeedMismatch is returned when a seed does not belong to the wallet.
+var ErrSeedMismatch = errors.New("seed does not match the wallet")
+
+// seedAccountKey derives the private default account key from the seed and
+// returns it if it matches xpub, the wallet's default account pubkey. Both the
+// SLIP0044 and legacy coin types are checked.
+func (w *Wallet) seedAccountKey(seed, seedPass []byte, seedType SeedType, lang mnemonic.Language, xpub string) (*hdkeychain.ExtendedKey, error) {
+	tweakedSeed, err := tweakSeed(seed, seedPass, seedType, lang)
+	if err != nil {
+		return nil, err
+	}
+	coinTypeLegacy, coinTypeSLIP0044, acctKeyLegacy, acctKeySLIP0044, err := udb.HDKeysFromSeed(tweakedSeed, w.chainParams)
+	if err != nil {
+		return nil, err
+	}
+	coinTypeLegacy.Zero()
+	coinTypeSLIP0044.Zero()
+	switch xpub {
+	case acctKeySLIP0044.Neuter().String():
+		acctKeyLegacy.Zero()
+		return acctKeySLIP0044, nil
+	case acctKeyLegacy.Neuter().String():
+		acctKeySLIP0044.Zero()
+		return acctKeyLegacy, nil
+	}
+	acctKeySLIP0044.Zero()
+	acctKeyLegacy.Zero()
+	return nil, ErrSeedMismatch
+}
+
+// UpgradeWatchOnly adds the seed to a watching only wallet so that it is able
+// to sign transactions spending from the default account. The seed must derive
+// the wallet's default account pubkey. lang is the language of twelve and
+// twenty four word mnemonics. The seed is encrypted with walletPass
+// and saved with the wallet data. The wallet database and its history are
+// kept, so no rescan is needed. Unlock the wallet with walletPass to sign.
+func (w *Wallet) UpgradeWatchOnly(ctx context.Context, seed, seedPass []byte, seedType SeedType, lang mnemonic.Language, walletPass []byte) error {
+	if !w.mainWallet.WatchingOnly() {
+		return errors.New("wallet is not watching only")
+	}
+	if len(walletPass) == 0 {
+		return errors.New("wallet pass cannot be empty")
+	}
+
+	w.seedMtx.Lock()
+	defer w.seedMtx.Unlock()
+	if w.metaData.EncryptedSeedHex != "" {
+		return errors.New("wallet already has a seed")
+	}
+
+	acctKey, err := w.seedAccountKey(seed, seedPass, seedType, lang, w.metaData.DefaultAccountXPub)
+	if err != nil {
+		return err
+	}
+	defer acctKey.Zero()
+
+	// The birthday is encoded in fifteen word mnemonics. Use the wallet's
+	// birthday if known, or else the genesis block time so that a restore
+	// from the mnemonic does not skip any blocks.
+	birthday := w.chainParams.GenesisBlock.Header.Timestamp
+	bs, err := w.mainWallet.BirthState(ctx)
+	if err != nil {
+		return err
+	}
+	if bs != nil && bs.SetFromTime {
+		// Undo the day subtracted when the birth state was set.
+		birthday = bs.Time.Add(time.Hour * 24)
+	}
+
+	encSeedHex, encSeedPassHex, err := encryptSeed(seed, seedPass, walletPass)
+	if err != nil {
+		return err
+	}
+	updatedMetaData := *w.metaData
+	updatedMetaData.EncryptedSeedHex = encSeedHex
+	updatedMetaData.EncryptedSeedPassHex = encSeedPassHex
+	updatedMetaData.SeedType = seedType
+	updatedMetaData.Language = lang
+	updatedMetaData.Birthday = birthday.Unix()
+	if err := saveWalletData(&updatedMetaData, w.dir); err != nil {
+		return err
+	}
+	*w.metaData = updatedMetaData
+	return nil
+}
+
+// isUpgradedWatchOnly returns true if the wallet is watching only but has a
+// seed that can be used to sign.
+func (w *Wallet) isUpgradedWatchOnly() bool {
+	if w.mainWallet == nil {
+		return false
+	}
+	w.seedMtx.Lock()
+	defer w.seedMtx.Unlock()
+	return w.mainWallet.WatchingOnly() && w.metaData.EncryptedSeedHex != ""
+}
+
+// unlockSeedSigner decrypts the seed of an upgraded watching only wallet and
+// keeps the default account private key in memory until the wallet is locked
+// or the timeout fires.
+func (w *Wallet) unlockSeedSigner(passphrase []byte, timeout <-chan time.Time) error {
+	w.seedMtx.Lock()
+	encryptedSeed, err := hex.DecodeString(w.metaData.EncryptedSeedHex)
+	if err != nil {
+		w.seedMtx.Unlock()
+		return fmt.Errorf("unable to decode encrypted hex seed: %v", err)
+	}
+	var encSeedPass []byte
+	if len(w.metaData.EncryptedSeedPassHex) != 0 {
+		encSeedPass, err = hex.DecodeString(w.metaData.EncryptedSeedPassHex)
+		if err != nil {
+			w.seedMtx.Unlock()
+			return fmt.Errorf("unable to decode encrypted seed pass: %v", err)
+		}
+	}
+	seedType, lang := w.metaData.SeedType, w.metaData.Language
+	xpub := w.metaData.DefaultAccountXPub
+	w.seedMtx.Unlock()
+
+	seed, err := DecryptData(encryptedSeed, passphrase)
+	if err != nil {
+		return err
+	}
+	var seedPass []byte
+	if encSeedPass != nil {
+		seedPass, err = DecryptData(encSeedPass, passphrase)
+		if err != nil {
+			return fmt.Errorf("unable to decrypt wallet seed pass: %v", err)
+		}
+	}
+	acctKey, err := w.seedAccountKey(seed, seedPass, seedType, lang, xpub)
+	if err != nil {
+		return err
+	}
+
+	w.signKeyMtx.Lock()
+	if w.signKey != nil {
+		w.signKey.Zero()
+	}
+	w.signKey = acctKey
+	w.signKeyMtx.Unlock()
+
+	if timeout != nil {
+		go func() {
+			<-timeout
+			w.lockSeedSigner(acctKey)
+		}()
+	}
+	return nil
+}
+
+// lockSeedSigner removes the default account private key from memory. If key
+// is not nil, it is only removed if it is still the current key.
+func (w *Wallet) lockSeedSigner(key *hdkeychain.ExtendedKey) {
+	w.signKeyMtx.Lock()
+	defer w.signKeyMtx.Unlock()
+	if w.signKey == nil || (key != nil && key != w.signKey) {
+		return
+	}
+	w.signKey.Zero()
+	w.signKey = nil
+}
+
+// errSeedSignerAccount is returned when an upgraded watching only wallet is
+// asked to sign for an account other than the default account, which is the
+// only account its seed is known to control.
+var errSeedSignerAccount = errors.New("upgraded watching only wallets can only sign for the default account")
+
+// seedSigningKeys returns the private keys, by address, needed to sign the
+// inputs of tx with the seed of an upgraded watching only wallet. Inputs that
+// spend from other accounts of the wallet are an error.
+func (w *Wallet) seedSigningKeys(ctx context.Context, tx *wire.MsgTx) (map[string]*dcrutil.WIF, error) {
+	w.signKeyMtx.Lock()
+	defer w.signKeyMtx.Unlock()
+	if w.signKey == nil {
+		return nil, errors.New("wallet is locked")
+	}
+
+	keys := make(map[string]*dcrutil.WIF)
+	for i, txIn := range tx.TxIn {
+		prevOut, err := w.mainWallet.FetchOutput(ctx, &txIn.PreviousOutPoint)
+		if err != nil {
+			return nil, fmt.Errorf("unable to fetch %v: %v", &txIn.PreviousOutPoint, err)
+		}
+		_, addrs := stdscript.ExtractAddrs(prevOut.Version, prevOut.PkScript, w.chainParams)
+		for _, addr := range addrs {
+			ka, err := w.mainWallet.KnownAddress(ctx, addr)
+			if err != nil {
+				continue
+			}
+			privKey, err := w.seedPrivKey(ka)
+			if err != nil {
+				return nil, fmt.Errorf("unable to sign input %d: %w", i, err)
+			}
+			wif, err := dcrutil.NewWIF(privKey.Serialize(), w.chainParams.PrivateKeyID, dcrec.STEcdsaSecp256k1)
+			privKey.Zero()
+			if err != nil {
+				return nil, err
+			}
+			keys[addr.String()] = wif
+		}
+	}
+	return keys, nil
+}
+
+// seedPrivKey derives the private key of the wallet address ka from the
+// default account key of an upgraded watching only wallet. The signKeyMtx
+// MUST be held and signKey set.
+func (w *Wallet) seedPrivKey(ka wallet.KnownAddress) (*secp256k1.PrivateKey, error) {
+	bip44Addr, ok := ka.(wallet.BIP0044Address)
+	if !ok || ka.AccountKind() != wallet.AccountKindBIP0044 {
+		return nil, fmt.Errorf("%w: address %v is not derived from an account key", errSeedSignerAccount, ka)
+	}
+	acct, branch, child := bip44Addr.Path()
+	if acct != udb.DefaultAccountNum {
+		return nil, fmt.Errorf("%w: address %v is in account %d", errSeedSignerAccount, ka, acct)
+	}
+	branchKey, err := w.signKey.Child(branch)
+	if err != nil {
+		return nil, err
+	}
+	childKey, err := branchKey.Child(child)
+	branchKey.Zero()
+	if err != nil {
+		return nil, err
+	}
+	b, err := childKey.SerializedPrivKey()
+	if err != nil {
+		childKey.Zero()
+		return nil, err
+	}
+	// PrivKeyFromBytes copies the key, so zeroing the extended key after
+	// is safe.
+	privKey := secp256k1.PrivKeyFromBytes(b)
+	childKey.Zero()
+	// Sanity check that the derived key belongs to the address.
+	if !bytes.Equal(privKey.PubKey().SerializeCompressed(), bip44Addr.PubKey()) {
+		privKey.Zero()
+		return nil, fmt.Errorf("derived key does not match address %v", ka)
+	}
+	return privKey, nil
+}
+
+// SignMessage signs msg with the private key of addr. Upgraded watching only
+// wallets sign with keys derived from their seed and can only sign for
+// addresses in the default account. The wallet is unlocked with pass for signing
+// and locked again after unless an unlock session is active, in which case pass
+// may be empty.
+func (w *Wallet) SignMessage(ctx context.Context, msg string, addr stdaddr.Address, pass []byte) ([]byte, error) {
+	done, err := w.UnlockForSigning(ctx, pass)
+	if err != nil {
+		return nil, fmt.Errorf("cannot unlock wallet: %w", err)
+	}
+	defer done()
+	if !w.isUpgradedWatchOnly() {
+		return w.mainWallet.SignMessage(ctx, msg, addr)
+	}
+	ka, err := w.mainWallet.KnownAddress(ctx, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	w.signKeyMtx.Lock()
+	defer w.signKeyMtx.Unlock()
+	if w.signKey == nil {
+		return nil, errors.New("wallet is locked")
+	}
+	privKey, err := w.seedPrivKey(ka)
+	if err != nil {
+		return nil, err
+	}
+	defer privKey.Zero()
+
+	// Hash the message as the main wallet does so that signatures verify
+	// with wallet.VerifyMessage.
+	var buf bytes.Buffer
+	wire.WriteVarString(&buf, 0, "Decred Signed Message:\n")
+	wire.WriteVarString(&buf, 0, msg)
+	return ecdsa.SignCompact(privKey, chainhash.HashB(buf.Bytes()), true), nil
+}
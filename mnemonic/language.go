@@ -0,0 +1,161 @@
+package mnemonic
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Language is the language of a BIP-0039 word list.
+type Language int
+
+const (
+	English Language = iota
+	Spanish
+	French
+	Italian
+	Japanese
+	Korean
+	Czech
+	Portuguese
+	ChineseSimplified
+	ChineseTraditional
+)
+
+// Languages are the supported languages in the order they are tried when
+// detecting the language of a mnemonic.
+var Languages = []Language{
+	English,
+	Spanish,
+	French,
+	Italian,
+	Japanese,
+	Korean,
+	Czech,
+	Portuguese,
+	ChineseSimplified,
+	ChineseTraditional,
+}
+
+// String returns the name of the language.
+func (l Language) String() string {
+	switch l {
+	case English:
+		return "english"
+	case Spanish:
+		return "spanish"
+	case French:
+		return "french"
+	case Italian:
+		return "italian"
+	case Japanese:
+		return "japanese"
+	case Korean:
+		return "korean"
+	case Czech:
+		return "czech"
+	case Portuguese:
+		return "portuguese"
+	case ChineseSimplified:
+		return "chinese_simplified"
+	case ChineseTraditional:
+		return "chinese_traditional"
+	}
+	return fmt.Sprintf("unknown language %d", int(l))
+}
+
+// ParseLanguage returns the language with the name returned by
+// Language.String.
+func ParseLanguage(name string) (Language, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for _, l := range Languages {
+		if l.String() == name {
+			return l, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown language %q", name)
+}
+
+// wordList returns the word list of the language.
+func (l Language) wordList() ([]string, error) {
+	switch l {
+	case English:
+		return wordList, nil
+	case Spanish:
+		return spanishWordList, nil
+	case French:
+		return frenchWordList, nil
+	case Italian:
+		return italianWordList, nil
+	case Japanese:
+		return japaneseWordList, nil
+	case Korean:
+		return koreanWordList, nil
+	case Czech:
+		return czechWordList, nil
+	case Portuguese:
+		return portugueseWordList, nil
+	case ChineseSimplified:
+		return chineseSimplifiedWordList, nil
+	case ChineseTraditional:
+		return chineseTraditionalWordList, nil
+	}
+	return nil, fmt.Errorf("unknown language %d", int(l))
+}
+
+// separator returns the string between the words of a mnemonic. Japanese
+// mnemonics are separated by an ideographic space.
+func (l Language) separator() string {
+	if l == Japanese {
+		return "　"
+	}
+	return " "
+}
+
+var (
+	wordIndexesOnce sync.Once
+	// wordIndexes maps the NFKD normalized words of every language except
+	// English, which is sorted, to their index.
+	wordIndexes map[Language]map[string]uint16
+)
+
+// indexes returns the word list indexes of the NFKD normalized words.
+func (l Language) indexes(words []string) ([]uint16, error) {
+	idxs := make([]uint16, len(words))
+	if l == English {
+		for i, word := range words {
+			idx, err := wordIndex(word)
+			if err != nil {
+				return nil, err
+			}
+			idxs[i] = idx
+		}
+		return idxs, nil
+	}
+
+	wordIndexesOnce.Do(func() {
+		wordIndexes = make(map[Language]map[string]uint16)
+		for lang := Spanish; lang <= ChineseTraditional; lang++ {
+			list, _ := lang.wordList()
+			m := make(map[string]uint16, len(list))
+			for i, word := range list {
+				m[norm.NFKD.String(word)] = uint16(i)
+			}
+			wordIndexes[lang] = m
+		}
+	})
+	m, ok := wordIndexes[l]
+	if !ok {
+		return nil, fmt.Errorf("unknown language %d", int(l))
+	}
+	for i, word := range words {
+		idx, ok := m[word]
+		if !ok {
+			return nil, fmt.Errorf("word %q not in the %s word list", word, l)
+		}
+		idxs[i] = idx
+	}
+	return idxs, nil
+}
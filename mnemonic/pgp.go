@@ -0,0 +1,56 @@
+package mnemonic
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"strings"
+
+	"decred.org/dcrwallet/v5/pgpwordlist"
+)
+
+const (
+	// PGPSeedBytes is the length of seeds encoded with the PGP word list.
+	PGPSeedBytes = 32
+	// PGPSeedWords is the number of words in a PGP word list mnemonic. The
+	// last word encodes a checksum byte.
+	PGPSeedWords = PGPSeedBytes + 1
+)
+
+// pgpChecksumByte returns the first byte of the double sha256 of seed.
+func pgpChecksumByte(seed []byte) byte {
+	h := sha256.Sum256(seed)
+	return sha256.Sum256(h[:])[0]
+}
+
+// EncodePGPMnemonic encodes a 32 byte seed as a 33 word PGP word list mnemonic
+// as used by dcrwallet and Decrediton.
+func EncodePGPMnemonic(seed []byte) (string, error) {
+	if len(seed) != PGPSeedBytes {
+		return "", fmt.Errorf("seed wrong length, must be %d bytes got %d", PGPSeedBytes, len(seed))
+	}
+	words := make([]string, PGPSeedWords)
+	for i, b := range seed {
+		words[i] = pgpwordlist.ByteToMnemonic(b, i)
+	}
+	words[PGPSeedBytes] = pgpwordlist.ByteToMnemonic(pgpChecksumByte(seed), PGPSeedBytes)
+	return strings.Join(words, " "), nil
+}
+
+// DecodePGPMnemonic decodes a 33 word PGP word list mnemonic and validates its
+// checksum.
+func DecodePGPMnemonic(mnemonic string) ([]byte, error) {
+	words := strings.Fields(mnemonic)
+	if len(words) != PGPSeedWords {
+		return nil, fmt.Errorf("mnemonic wrong size, must be %d got %d", PGPSeedWords, len(words))
+	}
+	decoded, err := pgpwordlist.DecodeMnemonics(words)
+	if err != nil {
+		return nil, err
+	}
+	seed := decoded[:PGPSeedBytes]
+	if pgpChecksumByte(seed) != decoded[PGPSeedBytes] {
+		return nil, errors.New("checksum mismatch")
+	}
+	return seed, nil
+}
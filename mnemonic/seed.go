@@ -14,23 +14,64 @@ import (
 )
 
 // DecodeMnemonic decodes the entropy and checksum from mnemonic and validates
-// the checksum.
+// the checksum. The language of the mnemonic is detected. See DetectLanguage.
 func DecodeMnemonic(mnemonic string) ([]byte, error) {
-	words := strings.Fields(mnemonic)
+	entropy, _, err := decodeMnemonic(mnemonic)
+	return entropy, err
+}
+
+// DetectLanguage returns the language of mnemonic. The mnemonic is NFKD
+// normalized, so words may be composed or decomposed and Japanese words may be
+// separated by ideographic spaces. Languages are tried in the order of
+// Languages and the first whose word list contains every word and whose
+// checksum is valid is returned.
+func DetectLanguage(mnemonic string) (Language, error) {
+	_, lang, err := decodeMnemonic(mnemonic)
+	return lang, err
+}
+
+func decodeMnemonic(mnemonic string) ([]byte, Language, error) {
+	words := strings.Fields(norm.NFKD.String(mnemonic))
 	nWords := len(words)
 	if nWords < 12 || nWords > 24 {
-		return nil, fmt.Errorf("mnemonic wrong size, must be between 12 and 24 got %d", nWords)
+		return nil, 0, fmt.Errorf("mnemonic wrong size, must be between 12 and 24 got %d", nWords)
 	}
+	// Report the English error unless the words are found in another
+	// language's list.
+	var wordErr, checksumErr error
+	for _, lang := range Languages {
+		idxs, err := lang.indexes(words)
+		if err != nil {
+			if lang == English {
+				wordErr = err
+			}
+			continue
+		}
+		entropy, err := entropyFromIndexes(idxs)
+		if err != nil {
+			if checksumErr == nil {
+				checksumErr = err
+			}
+			continue
+		}
+		return entropy, lang, nil
+	}
+	if checksumErr != nil {
+		return nil, 0, checksumErr
+	}
+	return nil, 0, wordErr
+}
+
+// entropyFromIndexes decodes the entropy and checksum from the word indexes of
+// a mnemonic and validates the checksum.
+func entropyFromIndexes(idxs []uint16) ([]byte, error) {
+	nWords := len(idxs)
 	totalBits := nWords * 11
 	entropyBytes := (totalBits - 8 + totalBits%8) / 8
 	checksumBits := totalBits - entropyBytes*8
 	buf := make([]byte, entropyBytes+1)
 	var cursor int
-	for i := range words {
-		v, err := wordIndex(words[i])
-		if err != nil {
-			return nil, err
-		}
+	for _, v := range idxs {
 		bs := make([]byte, 2)
 		binary.BigEndian.PutUint16(bs, v)
 		b0, b1 := bs[0], bs[1]
@@ -71,8 +112,31 @@ func DecodeMnemonic(mnemonic string) ([]byte, error) {
 	return entropy, nil
 }
 
+// GenerateOption is an option for GenerateMnemonic.
+type GenerateOption func(*generateOptions)
+
+type generateOptions struct {
+	lang Language
+}
+
+// WithLanguage sets the language of the generated mnemonic. The default is
+// English.
+func WithLanguage(lang Language) GenerateOption {
+	return func(o *generateOptions) {
+		o.lang = lang
+	}
+}
+
 // GenerateMnemonic generates a mnemonic seed from entropy.
-func GenerateMnemonic(entropy []byte) (string, error) {
+func GenerateMnemonic(entropy []byte, opts ...GenerateOption) (string, error) {
+	o := &generateOptions{lang: English}
+	for _, opt := range opts {
+		opt(o)
+	}
+	list, err := o.lang.wordList()
+	if err != nil {
+		return "", err
+	}
 	entropyBytes := len(entropy)
 	entropyBits := entropyBytes * 8
 	if entropyBits < 128 || entropyBits > 256 {
@@ -119,9 +183,9 @@ func GenerateMnemonic(entropy []byte) (string, error) {
 			cursor += n
 		}
 		idx := binary.BigEndian.Uint16(idxB)
-		words[i] = wordList[idx]
+		words[i] = list[idx]
 	}
-	return strings.Join(words, " "), nil
+	return strings.Join(words, o.lang.separator()), nil
 }
 
 func wordIndex(word string) (uint16, error) {
@@ -139,12 +203,13 @@ func wordIndex(word string) (uint16, error) {
 
 // ApplyPassphrase returns the seed after applying passphrase. Follows
 // https://github.com/bitcoin/bips/blob/master/bip-0039.mediawiki#from-mnemonic-to-seed
+// so both words and pass are NFKD normalized.
 func ApplyPassphrase(seed, pass []byte, words string) []byte {
 	const (
 		iterations = 2048
 		keyLen     = 64
 	)
-	password := norm.NFC.String(words)
-	salt := "mnemonic" + norm.NFC.String(string(pass))
+	password := norm.NFKD.String(words)
+	salt := "mnemonic" + norm.NFKD.String(string(pass))
 	return pbkdf2.Key([]byte(password), []byte(salt), iterations, keyLen, sha512.New)
 }
@@ -3,9 +3,14 @@ package mnemonic
 import (
 	"bytes"
 	"encoding/hex"
+	"slices"
+	"strings"
 	"testing"
 
 	"decred.org/dcrdex/dex/encode"
+	"decred.org/dcrwallet/v5/pgpwordlist"
+	"decred.org/dcrwallet/v5/walletseed"
+	"golang.org/x/text/unicode/norm"
 )
 
 func TestFindWordIndex(t *testing.T) {
@@ -211,3 +216,354 @@ func TestBip39Vectors(t *testing.T) {
 		})
 	}
 }
+
+func TestPGPEncodeDecode(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		ogSeed := encode.RandomBytes(PGPSeedBytes)
+		mnemonic, err := EncodePGPMnemonic(ogSeed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Must match the dcrwallet encoding.
+		if want := walletseed.EncodeMnemonic(ogSeed); mnemonic != want {
+			t.Fatalf("expected mnemonic %q but got %q", want, mnemonic)
+		}
+		reSeed, err := DecodePGPMnemonic(mnemonic)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(reSeed, ogSeed) {
+			t.Fatal("failed to recover seed")
+		}
+	}
+
+	seed := make([]byte, PGPSeedBytes)
+	mnemonic, err := EncodePGPMnemonic(seed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	words := strings.Fields(mnemonic)
+	badChecksum := pgpwordlist.ByteToMnemonic(pgpChecksumByte(seed)+1, PGPSeedBytes)
+
+	tests := []struct {
+		name, mnemonic string
+	}{{
+		name:     "wrong checksum",
+		mnemonic: strings.Join(append(words[:PGPSeedBytes:PGPSeedBytes], badChecksum), " "),
+	}, {
+		name:     "missing word",
+		mnemonic: strings.Join(words[1:], " "),
+	}, {
+		name:     "swapped words",
+		mnemonic: strings.Join(append([]string{words[1], words[0]}, words[2:]...), " "),
+	}, {
+		name:     "unknown word",
+		mnemonic: strings.Join(append([]string{"blah"}, words[1:]...), " "),
+	}}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := DecodePGPMnemonic(test.mnemonic); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
+
+func TestCompleteWord(t *testing.T) {
+	tests := []struct {
+		name, prefix string
+		want         []string
+	}{{
+		name:   "unique",
+		prefix: "aban",
+		want:   []string{"abandon"},
+	}, {
+		name:   "several",
+		prefix: "zo",
+		want:   []string{"zone", "zoo"},
+	}, {
+		name:   "whole word",
+		prefix: " Zoo ",
+		want:   []string{"zoo"},
+	}, {
+		name:   "no match",
+		prefix: "zz",
+	}, {
+		name: "empty",
+	}}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := CompleteWord(test.prefix)
+			if strings.Join(got, " ") != strings.Join(test.want, " ") {
+				t.Fatalf("expected %v but got %v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestSuggestWords(t *testing.T) {
+	tests := []struct {
+		name, word  string
+		maxDistance int
+		wantFirst   string
+		wantNone    bool
+	}{{
+		name:        "deletion",
+		word:        "abandn",
+		maxDistance: 1,
+		wantFirst:   "abandon",
+	}, {
+		name:        "substitution",
+		word:        "zpo",
+		maxDistance: 1,
+		wantFirst:   "zoo",
+	}, {
+		name:        "exact match first",
+		word:        "cat",
+		maxDistance: 2,
+		wantFirst:   "cat",
+	}, {
+		name:        "too far",
+		word:        "qqqqqqqqqq",
+		maxDistance: 2,
+		wantNone:    true,
+	}}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := SuggestWords(test.word, test.maxDistance)
+			if test.wantNone {
+				if len(got) != 0 {
+					t.Fatalf("expected no suggestions but got %v", got)
+				}
+				return
+			}
+			if len(got) == 0 || got[0] != test.wantFirst {
+				t.Fatalf("expected %q first but got %v", test.wantFirst, got)
+			}
+			for _, w := range got {
+				if d := editDistance(test.word, w); d > test.maxDistance {
+					t.Fatalf("suggestion %q is %d edits away", w, d)
+				}
+			}
+		})
+	}
+}
+
+func TestRepairMnemonic(t *testing.T) {
+	const valid = "hamster diagram private dutch cause delay private meat slide toddler razor book happy fancy gospel tennis maple dilemma loan word shrug inflict delay length"
+	words := strings.Fields(valid)
+	with := func(i int, w string) string {
+		ws := append([]string(nil), words...)
+		ws[i] = w
+		return strings.Join(ws, " ")
+	}
+	swapped := append([]string(nil), words...)
+	swapped[3], swapped[4] = swapped[4], swapped[3]
+
+	tests := []struct {
+		name, mnemonic string
+		wantErr        bool
+	}{{
+		name:     "swapped words",
+		mnemonic: strings.Join(swapped, " "),
+	}, {
+		name:     "wrong word",
+		mnemonic: with(5, "abandon"),
+	}, {
+		name:     "misspelled word",
+		mnemonic: with(9, "todler"),
+	}, {
+		name:     "valid",
+		mnemonic: valid,
+		wantErr:  true,
+	}, {
+		name:     "two unknown words",
+		mnemonic: "blah " + with(9, "todler"),
+		wantErr:  true,
+	}, {
+		name:    "empty",
+		wantErr: true,
+	}}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if !test.wantErr {
+				if _, err := DecodeMnemonic(test.mnemonic); err == nil {
+					t.Fatal("test mnemonic unexpectedly valid")
+				}
+			}
+			candidates, err := RepairMnemonic(test.mnemonic, 0)
+			if test.wantErr {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var found bool
+			for _, c := range candidates {
+				if _, err := DecodeMnemonic(c); err != nil {
+					t.Fatalf("candidate %q is invalid: %v", c, err)
+				}
+				found = found || c == valid
+			}
+			if !found {
+				t.Fatalf("original mnemonic not in %d candidates", len(candidates))
+			}
+		})
+	}
+
+	// The misspelled word's closest replacement is tried first.
+	candidates, err := RepairMnemonic(with(9, "todler"), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(candidates) != 1 || candidates[0] != valid {
+		t.Fatalf("expected only the original mnemonic but got %v", candidates)
+	}
+
+	// A wrong last word is found within a limited number of results even
+	// though every position has many replacements that pass the checksum.
+	const valid12 = "legal winner thank year wave sausage worth useful legal winner thank yellow"
+	typo := strings.TrimSuffix(valid12, "yellow") + "hollow"
+	if _, err := DecodeMnemonic(typo); err == nil {
+		t.Fatal("test mnemonic unexpectedly valid")
+	}
+	candidates, err = RepairMnemonic(typo, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Contains(candidates, valid12) {
+		t.Fatalf("original mnemonic not in %d candidates", len(candidates))
+	}
+}
+
+func TestLanguages(t *testing.T) {
+	for _, lang := range Languages {
+		t.Run(lang.String(), func(t *testing.T) {
+			list, err := lang.wordList()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(list) != 2048 {
+				t.Fatalf("expected 2048 words but got %d", len(list))
+			}
+			if parsed, err := ParseLanguage(lang.String()); err != nil || parsed != lang {
+				t.Fatalf("unable to parse language name: %v", err)
+			}
+			for i, entropy := range [][]byte{
+				bytes.Repeat([]byte{0x5a}, 16),
+				bytes.Repeat([]byte{0xc3}, 24),
+				bytes.Repeat([]byte{0x7f}, 32),
+			} {
+				mnemonic, err := GenerateMnemonic(entropy, WithLanguage(lang))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if lang == English {
+					if english, _ := GenerateMnemonic(entropy); english != mnemonic {
+						t.Fatalf("expected english by default")
+					}
+				}
+				detected, err := DetectLanguage(mnemonic)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if detected != lang {
+					t.Fatalf("%d: detected %s", i, detected)
+				}
+				reEntropy, err := DecodeMnemonic(mnemonic)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(reEntropy, entropy) {
+					t.Fatalf("%d: failed to recover entropy", i)
+				}
+			}
+		})
+	}
+	if _, err := GenerateMnemonic(make([]byte, 16), WithLanguage(Language(100))); err == nil {
+		t.Fatal("expected error for unknown language")
+	}
+	if _, err := ParseLanguage("klingon"); err == nil {
+		t.Fatal("expected error for unknown language name")
+	}
+}
+
+func TestNormalization(t *testing.T) {
+	entropy := make([]byte, 16)
+	// From https://github.com/bip32JP/bip32JP.github.io/blob/master/test_JP_BIP39.json
+	const (
+		jaMnemonic = "あいこくしん　あいこくしん　あいこくしん　あいこくしん　あいこくしん　あいこくしん　あいこくしん　あいこくしん　あいこくしん　あいこくしん　あいこくしん　あおぞら"
+		jaPass     = "㍍ガバヴァぱばぐゞちぢ十人十色"
+		jaSeed     = "a262d6fb6122ecf45be09c50492b31f92e9beb7d9a845987a02cefda57a15f9c467a17872029a9e92299b5cbdf306e3a0ee620245cbd508959b6cb7ca637bd55"
+	)
+	mnemonic, err := GenerateMnemonic(entropy, WithLanguage(Japanese))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The word lists are decomposed.
+	if mnemonic != norm.NFD.String(jaMnemonic) {
+		t.Fatalf("expected mnemonic %q but got %q", jaMnemonic, mnemonic)
+	}
+	wantSeed, _ := hex.DecodeString(jaSeed)
+	if seed := ApplyPassphrase(entropy, []byte(jaPass), mnemonic); !bytes.Equal(seed, wantSeed) {
+		t.Fatalf("expected seed %x but got %x", wantSeed, seed)
+	}
+
+	esMnemonic, err := GenerateMnemonic(entropy, WithLanguage(Spanish))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name, mnemonic string
+		lang           Language
+	}{{
+		name:     "japanese with ideographic spaces",
+		mnemonic: jaMnemonic,
+		lang:     Japanese,
+	}, {
+		name:     "japanese with ascii spaces",
+		mnemonic: strings.ReplaceAll(jaMnemonic, "　", " "),
+		lang:     Japanese,
+	}, {
+		name:     "japanese composed",
+		mnemonic: norm.NFC.String(jaMnemonic),
+		lang:     Japanese,
+	}, {
+		name:     "spanish composed",
+		mnemonic: norm.NFC.String(esMnemonic),
+		lang:     Spanish,
+	}, {
+		name:     "spanish decomposed",
+		mnemonic: norm.NFD.String(esMnemonic),
+		lang:     Spanish,
+	}}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			lang, err := DetectLanguage(test.mnemonic)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if lang != test.lang {
+				t.Fatalf("expected %s but got %s", test.lang, lang)
+			}
+			seed, err := DecodeMnemonic(test.mnemonic)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(seed, entropy) {
+				t.Fatal("failed to recover entropy")
+			}
+		})
+	}
+
+	// Errors for English words are kept.
+	if _, err := DecodeMnemonic(strings.Repeat("abandon ", 12)); err == nil || err.Error() != "checksum mismatch" {
+		t.Fatalf("expected checksum mismatch but got %v", err)
+	}
+	if _, err := DecodeMnemonic(strings.Repeat("abandn ", 12)); err == nil || !strings.Contains(err.Error(), "closest match") {
+		t.Fatalf("expected unknown word but got %v", err)
+	}
+}
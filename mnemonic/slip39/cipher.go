@@ -0,0 +1,52 @@
+package slip39
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+const (
+	baseIterationCount = 10000
+	roundCount         = 4
+)
+
+// feistelSalt returns the salt of the round function. Extendable backups do not
+// salt with the identifier so that new shares can be added with a new one.
+func feistelSalt(identifier uint16, extendable bool) []byte {
+	if extendable {
+		return nil
+	}
+	salt := make([]byte, len(customizationOrig)+2)
+	copy(salt, customizationOrig)
+	binary.BigEndian.PutUint16(salt[len(customizationOrig):], identifier)
+	return salt
+}
+
+func roundFunction(i int, passphrase []byte, e uint8, salt, r []byte) []byte {
+	pass := append([]byte{byte(i)}, passphrase...)
+	iterations := (baseIterationCount << e) / roundCount
+	return pbkdf2.Key(pass, append(append([]byte(nil), salt...), r...), iterations, len(r), sha256.New)
+}
+
+// feistel runs the four round Feistel network over secret. Rounds are run in
+// reverse to decrypt.
+func feistel(secret, passphrase []byte, e uint8, identifier uint16, extendable, decrypt bool) []byte {
+	half := len(secret) / 2
+	l := append([]byte(nil), secret[:half]...)
+	r := append([]byte(nil), secret[half:]...)
+	salt := feistelSalt(identifier, extendable)
+	for n := 0; n < roundCount; n++ {
+		i := n
+		if decrypt {
+			i = roundCount - 1 - n
+		}
+		f := roundFunction(i, passphrase, e, salt, r)
+		for j := range l {
+			l[j] ^= f[j]
+		}
+		l, r = r, l
+	}
+	return append(r, l...)
+}
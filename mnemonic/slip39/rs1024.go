@@ -0,0 +1,54 @@
+package slip39
+
+// checksumWords is the length of the RS1024 checksum in words.
+const checksumWords = 3
+
+var rs1024Gen = [10]uint32{
+	0xE0E040,
+	0x1C1C080,
+	0x3838100,
+	0x7070200,
+	0xE0E0009,
+	0x1C0C2412,
+	0x38086C24,
+	0x3090FC48,
+	0x21B1F890,
+	0x3F3F120,
+}
+
+func rs1024Polymod(customization string, data []int) uint32 {
+	chk := uint32(1)
+	step := func(v uint32) {
+		b := chk >> 20
+		chk = (chk&0xFFFFF)<<10 ^ v
+		for i := range rs1024Gen {
+			if (b>>i)&1 != 0 {
+				chk ^= rs1024Gen[i]
+			}
+		}
+	}
+	for i := 0; i < len(customization); i++ {
+		step(uint32(customization[i]))
+	}
+	for _, v := range data {
+		step(uint32(v))
+	}
+	return chk
+}
+
+// rs1024Checksum returns the checksum words for data.
+func rs1024Checksum(customization string, data []int) []int {
+	padded := make([]int, len(data)+checksumWords)
+	copy(padded, data)
+	polymod := rs1024Polymod(customization, padded) ^ 1
+	checksum := make([]int, checksumWords)
+	for i := range checksum {
+		checksum[i] = int(polymod>>(10*(checksumWords-1-i))) & 1023
+	}
+	return checksum
+}
+
+// rs1024Verify returns whether data ends with a valid checksum.
+func rs1024Verify(customization string, data []int) bool {
+	return rs1024Polymod(customization, data) == 1
+}
@@ -0,0 +1,157 @@
+package slip39
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+)
+
+const (
+	maxShareCount = 16
+	digestBytes   = 4
+	digestIndex   = 254
+	secretIndex   = 255
+)
+
+// expTable and logTable are used for arithmetic in GF(256) with the Rijndael
+// polynomial x^8 + x^4 + x^3 + x + 1.
+var expTable, logTable = func() (exp [255]byte, log [256]int) {
+	poly := 1
+	for i := range exp {
+		exp[i] = byte(poly)
+		log[poly] = i
+		// Multiply by x + 1 and reduce.
+		poly = (poly << 1) ^ poly
+		if poly&0x100 != 0 {
+			poly ^= 0x11B
+		}
+	}
+	return exp, log
+}()
+
+type rawShare struct {
+	x    byte
+	data []byte
+}
+
+// interpolate returns f(x) given the points of the polynomials f.
+func interpolate(shares []rawShare, x byte) ([]byte, error) {
+	if len(shares) == 0 {
+		return nil, errors.New("no shares to interpolate")
+	}
+	seen := make(map[byte]bool, len(shares))
+	for _, s := range shares {
+		if seen[s.x] {
+			return nil, errors.New("share indices must be unique")
+		}
+		seen[s.x] = true
+		if len(s.data) != len(shares[0].data) {
+			return nil, errors.New("all share values must have the same length")
+		}
+	}
+	for _, s := range shares {
+		if s.x == x {
+			return append([]byte(nil), s.data...), nil
+		}
+	}
+
+	// The logarithm of the product of (x_i - x).
+	var logProd int
+	for _, s := range shares {
+		logProd += logTable[s.x^x]
+	}
+	result := make([]byte, len(shares[0].data))
+	for _, s := range shares {
+		// The logarithm of the Lagrange basis polynomial at x.
+		logBasis := logProd - logTable[s.x^x]
+		for _, other := range shares {
+			if other.x != s.x {
+				logBasis -= logTable[s.x^other.x]
+			}
+		}
+		logBasis = ((logBasis % 255) + 255) % 255
+		for i, v := range s.data {
+			if v != 0 {
+				result[i] ^= expTable[(logTable[v]+logBasis)%255]
+			}
+		}
+	}
+	return result, nil
+}
+
+func secretDigest(randomPart, secret []byte) []byte {
+	mac := hmac.New(sha256.New, randomPart)
+	mac.Write(secret)
+	return mac.Sum(nil)[:digestBytes]
+}
+
+// splitSecret splits secret into count shares, threshold of which are needed to
+// recover it.
+func splitSecret(threshold, count int, secret []byte) ([]rawShare, error) {
+	if threshold < 1 {
+		return nil, errors.New("threshold must be positive")
+	}
+	if threshold > count {
+		return nil, errors.New("threshold must not exceed the number of shares")
+	}
+	if count > maxShareCount {
+		return nil, fmt.Errorf("number of shares must not exceed %d", maxShareCount)
+	}
+
+	shares := make([]rawShare, 0, count)
+	// The digest is not used if the threshold is one.
+	if threshold == 1 {
+		for i := 0; i < count; i++ {
+			shares = append(shares, rawShare{byte(i), append([]byte(nil), secret...)})
+		}
+		return shares, nil
+	}
+
+	randomShareCount := threshold - 2
+	for i := 0; i < randomShareCount; i++ {
+		b := make([]byte, len(secret))
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		shares = append(shares, rawShare{byte(i), b})
+	}
+	randomPart := make([]byte, len(secret)-digestBytes)
+	if _, err := rand.Read(randomPart); err != nil {
+		return nil, err
+	}
+	digest := append(secretDigest(randomPart, secret), randomPart...)
+	base := append(append([]rawShare(nil), shares...),
+		rawShare{digestIndex, digest},
+		rawShare{secretIndex, secret},
+	)
+	for i := randomShareCount; i < count; i++ {
+		data, err := interpolate(base, byte(i))
+		if err != nil {
+			return nil, err
+		}
+		shares = append(shares, rawShare{byte(i), data})
+	}
+	return shares, nil
+}
+
+// recoverSecret recovers the secret from threshold shares and checks its
+// digest.
+func recoverSecret(threshold int, shares []rawShare) ([]byte, error) {
+	if threshold == 1 {
+		return append([]byte(nil), shares[0].data...), nil
+	}
+	secret, err := interpolate(shares, secretIndex)
+	if err != nil {
+		return nil, err
+	}
+	digestShare, err := interpolate(shares, digestIndex)
+	if err != nil {
+		return nil, err
+	}
+	if !hmac.Equal(digestShare[:digestBytes], secretDigest(digestShare[digestBytes:], secret)) {
+		return nil, errors.New("invalid digest of the shared secret")
+	}
+	return secret, nil
+}
@@ -0,0 +1,163 @@
+package slip39
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+)
+
+const (
+	radixBits = 10
+	// idExpWords is the length of the identifier, extendable flag and
+	// iteration exponent in words.
+	idExpWords = 2
+	// metadataWords is the length of a share without its value in words.
+	metadataWords = idExpWords + 2 + checksumWords
+	// minSecretBytes is the minimum length of the master secret.
+	minSecretBytes = 16
+	// minMnemonicWords is the minimum length of a share in words.
+	minMnemonicWords = metadataWords + (minSecretBytes*8+radixBits-1)/radixBits
+
+	customizationOrig       = "shamir"
+	customizationExtendable = "shamir_extendable"
+)
+
+// Share is a single share of a master secret.
+type Share struct {
+	// Identifier is a random value common to all shares of a master secret.
+	Identifier uint16
+	// Extendable is set if more shares can be added to the backup later.
+	Extendable bool
+	// IterationExponent sets the number of PBKDF2 iterations used to
+	// encrypt the master secret.
+	IterationExponent uint8
+	GroupIndex        int
+	GroupThreshold    int
+	GroupCount        int
+	MemberIndex       int
+	MemberThreshold   int
+	// Value is the share of the encrypted master secret.
+	Value []byte
+}
+
+func customization(extendable bool) string {
+	if extendable {
+		return customizationExtendable
+	}
+	return customizationOrig
+}
+
+// intToIndices returns the big endian radix indices of v.
+func intToIndices(v uint64, length, bits int) []int {
+	indices := make([]int, length)
+	mask := uint64(1)<<bits - 1
+	for i := range indices {
+		indices[i] = int(v >> (uint(length-1-i) * uint(bits)) & mask)
+	}
+	return indices
+}
+
+func indicesToInt(indices []int) uint64 {
+	var v uint64
+	for _, i := range indices {
+		v = v<<radixBits | uint64(i)
+	}
+	return v
+}
+
+// Mnemonic returns the mnemonic of the share.
+func (s *Share) Mnemonic() string {
+	var extendable uint64
+	if s.Extendable {
+		extendable = 1
+	}
+	idExp := uint64(s.Identifier)<<5 | extendable<<4 | uint64(s.IterationExponent)
+	data := intToIndices(idExp, idExpWords, radixBits)
+
+	params := uint64(s.GroupIndex)<<16 | uint64(s.GroupThreshold-1)<<12 |
+		uint64(s.GroupCount-1)<<8 | uint64(s.MemberIndex)<<4 | uint64(s.MemberThreshold-1)
+	data = append(data, intToIndices(params, 2, radixBits)...)
+
+	valueWords := (len(s.Value)*8 + radixBits - 1) / radixBits
+	value := new(big.Int).SetBytes(s.Value)
+	mask := big.NewInt(1<<radixBits - 1)
+	valueData := make([]int, valueWords)
+	for i := valueWords - 1; i >= 0; i-- {
+		valueData[i] = int(new(big.Int).And(value, mask).Int64())
+		value.Rsh(value, radixBits)
+	}
+	data = append(data, valueData...)
+	data = append(data, rs1024Checksum(customization(s.Extendable), data)...)
+
+	words := make([]string, len(data))
+	for i, idx := range data {
+		words[i] = wordList[idx]
+	}
+	return strings.Join(words, " ")
+}
+
+// wordIndex returns the index of word in the word list.
+func wordIndex(word string) (int, error) {
+	i := sort.SearchStrings(wordList, word)
+	if i == len(wordList) || wordList[i] != word {
+		return 0, fmt.Errorf("invalid mnemonic word %q", word)
+	}
+	return i, nil
+}
+
+// DecodeShare decodes a share mnemonic and validates its checksum.
+func DecodeShare(mnemonic string) (*Share, error) {
+	words := strings.Fields(strings.ToLower(mnemonic))
+	if len(words) < minMnemonicWords {
+		return nil, fmt.Errorf("invalid mnemonic length. each mnemonic must be at least %d words, got %d",
+			minMnemonicWords, len(words))
+	}
+	data := make([]int, len(words))
+	for i, word := range words {
+		idx, err := wordIndex(word)
+		if err != nil {
+			return nil, err
+		}
+		data[i] = idx
+	}
+
+	paddingBits := (radixBits * (len(data) - metadataWords)) % 16
+	if paddingBits > 8 {
+		return nil, fmt.Errorf("invalid mnemonic length %d", len(words))
+	}
+
+	idExp := indicesToInt(data[:idExpWords])
+	s := &Share{
+		Identifier:        uint16(idExp >> 5),
+		Extendable:        (idExp>>4)&1 == 1,
+		IterationExponent: uint8(idExp & 0xF),
+	}
+	prefix := strings.Join(words[:idExpWords+2], " ")
+	if !rs1024Verify(customization(s.Extendable), data) {
+		return nil, fmt.Errorf("invalid mnemonic checksum for %q", prefix)
+	}
+
+	params := intToIndices(indicesToInt(data[idExpWords:idExpWords+2]), 5, 4)
+	s.GroupIndex = params[0]
+	s.GroupThreshold = params[1] + 1
+	s.GroupCount = params[2] + 1
+	s.MemberIndex = params[3]
+	s.MemberThreshold = params[4] + 1
+	if s.GroupThreshold > s.GroupCount {
+		return nil, fmt.Errorf("invalid mnemonic %q. group threshold cannot be greater than group count", prefix)
+	}
+
+	valueData := data[idExpWords+2 : len(data)-checksumWords]
+	valueBits := radixBits*len(valueData) - paddingBits
+	value := new(big.Int)
+	for _, idx := range valueData {
+		value.Lsh(value, radixBits)
+		value.Or(value, big.NewInt(int64(idx)))
+	}
+	if value.BitLen() > valueBits {
+		return nil, fmt.Errorf("invalid mnemonic padding for %q", prefix)
+	}
+	s.Value = value.FillBytes(make([]byte, (valueBits+7)/8))
+	return s, nil
+}
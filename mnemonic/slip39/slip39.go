@@ -0,0 +1,166 @@
+// Package slip39 implements SLIP-0039 Shamir's secret sharing of a master
+// secret into mnemonic shares. See
+// https://github.com/satoshilabs/slips/blob/master/slip-0039.md
+package slip39
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// iterationExponent is the PBKDF2 iteration exponent of new shares.
+const iterationExponent = 1
+
+// Group is the member threshold and count of a group of shares.
+type Group struct {
+	// MemberThreshold is the number of shares in the group needed to
+	// recover the group's part of the secret.
+	MemberThreshold int
+	// MemberCount is the number of shares in the group.
+	MemberCount int
+}
+
+// checkPassphrase returns an error if passphrase is not printable ASCII.
+func checkPassphrase(passphrase []byte) error {
+	for _, c := range passphrase {
+		if c < 32 || c > 126 {
+			return errors.New("passphrase must contain only printable ASCII characters")
+		}
+	}
+	return nil
+}
+
+// SplitSecret encrypts masterSecret with passphrase and splits it into groups
+// of share mnemonics. The shares of groupThreshold groups, each with at least
+// the group's member threshold of shares, are needed to recover the master
+// secret with CombineMnemonics. The master secret must be at least 16 bytes
+// long and have an even length.
+func SplitSecret(groupThreshold int, groups []Group, masterSecret, passphrase []byte) ([][]string, error) {
+	if len(masterSecret) < minSecretBytes || len(masterSecret)%2 != 0 {
+		return nil, fmt.Errorf("master secret must be an even number of bytes and at least %d bytes, got %d",
+			minSecretBytes, len(masterSecret))
+	}
+	if err := checkPassphrase(passphrase); err != nil {
+		return nil, err
+	}
+	if groupThreshold > len(groups) {
+		return nil, errors.New("group threshold must not exceed the number of groups")
+	}
+	for _, g := range groups {
+		if g.MemberThreshold == 1 && g.MemberCount > 1 {
+			return nil, errors.New("multiple member shares with member threshold 1 are not allowed")
+		}
+	}
+
+	var b [2]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return nil, err
+	}
+	identifier := binary.BigEndian.Uint16(b[:]) & (1<<15 - 1)
+	const extendable = true
+	ems := feistel(masterSecret, passphrase, iterationExponent, identifier, extendable, false)
+
+	groupShares, err := splitSecret(groupThreshold, len(groups), ems)
+	if err != nil {
+		return nil, err
+	}
+	mnemonics := make([][]string, len(groups))
+	for i, g := range groups {
+		memberShares, err := splitSecret(g.MemberThreshold, g.MemberCount, groupShares[i].data)
+		if err != nil {
+			return nil, fmt.Errorf("group %d: %w", i, err)
+		}
+		for _, ms := range memberShares {
+			share := &Share{
+				Identifier:        identifier,
+				Extendable:        extendable,
+				IterationExponent: iterationExponent,
+				GroupIndex:        int(groupShares[i].x),
+				GroupThreshold:    groupThreshold,
+				GroupCount:        len(groups),
+				MemberIndex:       int(ms.x),
+				MemberThreshold:   g.MemberThreshold,
+				Value:             ms.data,
+			}
+			mnemonics[i] = append(mnemonics[i], share.Mnemonic())
+		}
+	}
+	return mnemonics, nil
+}
+
+// CombineMnemonics recovers the master secret from share mnemonics created by
+// SplitSecret. Exactly the group threshold of groups must be given, each with
+// exactly its member threshold of shares.
+func CombineMnemonics(mnemonics []string, passphrase []byte) ([]byte, error) {
+	if len(mnemonics) == 0 {
+		return nil, errors.New("no mnemonics")
+	}
+	if err := checkPassphrase(passphrase); err != nil {
+		return nil, err
+	}
+
+	groups := make(map[int][]*Share)
+	var first *Share
+	for _, m := range mnemonics {
+		s, err := DecodeShare(m)
+		if err != nil {
+			return nil, err
+		}
+		if first == nil {
+			first = s
+		}
+		if s.Identifier != first.Identifier || s.Extendable != first.Extendable ||
+			s.IterationExponent != first.IterationExponent ||
+			s.GroupThreshold != first.GroupThreshold || s.GroupCount != first.GroupCount {
+			return nil, errors.New("all mnemonics must begin with the same 2 words and have the same group threshold and count")
+		}
+		if g := groups[s.GroupIndex]; len(g) > 0 && g[0].MemberThreshold != s.MemberThreshold {
+			return nil, fmt.Errorf("mismatching member thresholds in group %d", s.GroupIndex)
+		}
+		groups[s.GroupIndex] = append(groups[s.GroupIndex], s)
+	}
+
+	if len(groups) < first.GroupThreshold {
+		return nil, fmt.Errorf("insufficient number of mnemonic groups. %d groups are required", first.GroupThreshold)
+	}
+	if len(groups) != first.GroupThreshold {
+		return nil, fmt.Errorf("wrong number of mnemonic groups. expected %d groups but got %d",
+			first.GroupThreshold, len(groups))
+	}
+
+	groupIndexes := make([]int, 0, len(groups))
+	for gi := range groups {
+		groupIndexes = append(groupIndexes, gi)
+	}
+	sort.Ints(groupIndexes)
+	groupShares := make([]rawShare, 0, len(groups))
+	for _, gi := range groupIndexes {
+		shares := groups[gi]
+		threshold := shares[0].MemberThreshold
+		if len(shares) != threshold {
+			return nil, fmt.Errorf("wrong number of mnemonics in group %d. expected %d but got %d",
+				gi, threshold, len(shares))
+		}
+		raw := make([]rawShare, len(shares))
+		for i, s := range shares {
+			raw[i] = rawShare{byte(s.MemberIndex), s.Value}
+		}
+		secret, err := recoverSecret(threshold, raw)
+		if err != nil {
+			return nil, fmt.Errorf("group %d: %w", gi, err)
+		}
+		groupShares = append(groupShares, rawShare{byte(gi), secret})
+	}
+
+	ems, err := recoverSecret(first.GroupThreshold, groupShares)
+	if err != nil {
+		return nil, err
+	}
+	if len(ems) < minSecretBytes || len(ems)%2 != 0 {
+		return nil, fmt.Errorf("invalid master secret length %d", len(ems))
+	}
+	return feistel(ems, passphrase, first.IterationExponent, first.Identifier, first.Extendable, true), nil
+}
@@ -0,0 +1,163 @@
+package mnemonic
+
+import (
+	"errors"
+	"sort"
+	"strings"
+)
+
+// CompleteWord returns the words in the word list that start with prefix.
+func CompleteWord(prefix string) []string {
+	prefix = strings.ToLower(strings.TrimSpace(prefix))
+	if prefix == "" {
+		return nil
+	}
+	i := sort.SearchStrings(wordList, prefix)
+	var words []string
+	for ; i < len(wordList) && strings.HasPrefix(wordList[i], prefix); i++ {
+		words = append(words, wordList[i])
+	}
+	return words
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
+
+// SuggestWords returns the words in the word list within maxDistance edits of
+// word, closest first.
+func SuggestWords(word string, maxDistance int) []string {
+	word = strings.ToLower(strings.TrimSpace(word))
+	type match struct {
+		word string
+		dist int
+	}
+	var matches []match
+	for _, w := range wordList {
+		if d := editDistance(word, w); d <= maxDistance {
+			matches = append(matches, match{w, d})
+		}
+	}
+	// The word list is sorted, so a stable sort keeps words with the same
+	// distance in alphabetical order.
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].dist < matches[j].dist
+	})
+	words := make([]string, len(matches))
+	for i, m := range matches {
+		words[i] = m.word
+	}
+	return words
+}
+
+// RepairMnemonic returns candidate phrases for a mnemonic that fails to decode
+// because of its checksum. See RepairMnemonicFunc.
+func RepairMnemonic(mnemonic string, maxResults int) ([]string, error) {
+	return RepairMnemonicFunc(mnemonic, func(m string) error {
+		_, err := DecodeMnemonic(m)
+		return err
+	}, maxResults)
+}
+
+// RepairMnemonicFunc returns candidate phrases that differ from mnemonic by two
+// swapped adjacent words or by one word and that decode without error. Swaps
+// are listed first, followed by replacements at any position ordered by how
+// close the new word is to the replaced one. If a single word is not in the
+// word list, only that word is replaced. At most maxResults candidates are
+// returned, or all of them if maxResults is zero. decode must return an error
+// for invalid phrases.
+func RepairMnemonicFunc(mnemonic string, decode func(string) error, maxResults int) ([]string, error) {
+	words := strings.Fields(strings.ToLower(mnemonic))
+	if len(words) == 0 {
+		return nil, errors.New("empty mnemonic")
+	}
+
+	unknown := -1
+	for i, w := range words {
+		if _, err := wordIndex(w); err != nil {
+			if unknown != -1 {
+				return nil, errors.New("more than one word is not in the word list")
+			}
+			unknown = i
+		}
+	}
+	if unknown == -1 && decode(strings.Join(words, " ")) == nil {
+		return nil, errors.New("mnemonic is valid")
+	}
+
+	var candidates []string
+	done := func() bool {
+		return maxResults > 0 && len(candidates) >= maxResults
+	}
+	try := func(ws []string) {
+		if m := strings.Join(ws, " "); decode(m) == nil {
+			candidates = append(candidates, m)
+		}
+	}
+
+	positions := make([]int, 0, len(words))
+	if unknown != -1 {
+		positions = append(positions, unknown)
+	} else {
+		for i := 0; i+1 < len(words) && !done(); i++ {
+			if words[i] == words[i+1] {
+				continue
+			}
+			ws := append([]string(nil), words...)
+			ws[i], ws[i+1] = ws[i+1], ws[i]
+			try(ws)
+		}
+		for i := range words {
+			positions = append(positions, i)
+		}
+	}
+
+	// Try the closest replacements at every position first so that a
+	// limited number of results is not filled by the first positions.
+	type replacement struct {
+		pos  int
+		word string
+		dist int
+	}
+	replacements := make([]replacement, 0, len(positions)*len(wordList))
+	for _, i := range positions {
+		for _, w := range wordList {
+			if w != words[i] {
+				replacements = append(replacements, replacement{i, w, editDistance(words[i], w)})
+			}
+		}
+	}
+	// Replacements with the same distance stay in position and then
+	// alphabetical order.
+	sort.SliceStable(replacements, func(a, b int) bool {
+		return replacements[a].dist < replacements[b].dist
+	})
+	ws := append([]string(nil), words...)
+	for _, r := range replacements {
+		if done() {
+			break
+		}
+		ws[r.pos] = r.word
+		try(ws)
+		ws[r.pos] = words[r.pos]
+	}
+	return candidates, nil
+}